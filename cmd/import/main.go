@@ -0,0 +1,76 @@
+// Command import loads movies or schedules from a CSV or JSON file into the
+// database, the same way the POST /imports/{kind} endpoint does.
+//
+//	go run ./cmd/import -kind schedules [-dry-run] [-format csv] schedules.csv
+//
+// It reads the database settings from .env and prints the result of every
+// row as JSON. It exits with status 1 when any row fails, in which case
+// nothing is written.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"tix-id/config"
+	"tix-id/controller"
+	"tix-id/models"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	kind := flag.String("kind", "", "what the file holds: movies or schedules")
+	format := flag.String("format", "", "csv or json, by default from the file extension")
+	dryRun := flag.Bool("dry-run", false, "check the rows without writing them")
+	envFile := flag.String("env", ".env", "file with the database settings")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: import -kind movies|schedules [-dry-run] [-format csv|json] file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || !models.ImportKind(*kind).Valid() {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(*envFile); err != nil {
+		log.Fatal("Error loading " + *envFile + " file")
+	}
+
+	var file io.Reader = os.Stdin
+	path := flag.Arg(0)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		file = f
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	result, err := controller.Import(db, models.ImportKind(*kind), *format, file, *dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(output))
+	if result.Failed > 0 {
+		db.Close()
+		os.Exit(1)
+	}
+}
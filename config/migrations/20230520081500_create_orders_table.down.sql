@@ -0,0 +1,6 @@
+ALTER TABLE `ticket`
+  DROP FOREIGN KEY `ticket_ibfk_5`,
+  DROP KEY `order_id`,
+  DROP COLUMN `order_id`;
+
+DROP TABLE IF EXISTS `orders`;
@@ -0,0 +1,19 @@
+CREATE TABLE `orders` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `customer_id` int(11) NOT NULL,
+  `schedule_id` int(11) NOT NULL,
+  `payment_id` int(11) NOT NULL,
+  `created_at` timestamp NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  KEY `customer_id` (`customer_id`),
+  KEY `schedule_id` (`schedule_id`),
+  KEY `payment_id` (`payment_id`),
+  CONSTRAINT `orders_ibfk_1` FOREIGN KEY (`customer_id`) REFERENCES `customer` (`id`),
+  CONSTRAINT `orders_ibfk_2` FOREIGN KEY (`schedule_id`) REFERENCES `schedule` (`id`),
+  CONSTRAINT `orders_ibfk_3` FOREIGN KEY (`payment_id`) REFERENCES `payment` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+ALTER TABLE `ticket`
+  ADD COLUMN `order_id` int(11) DEFAULT NULL,
+  ADD KEY `order_id` (`order_id`),
+  ADD CONSTRAINT `ticket_ibfk_5` FOREIGN KEY (`order_id`) REFERENCES `orders` (`id`);
@@ -0,0 +1,3 @@
+ALTER TABLE `ticket`
+  DROP KEY `active_seat`,
+  DROP COLUMN `active`;
@@ -0,0 +1,17 @@
+-- active is 1 while the ticket holds its seat and NULL once it is released,
+-- so the unique key only applies to active bookings
+ALTER TABLE `ticket`
+  ADD COLUMN `active` tinyint(1) DEFAULT 1;
+
+UPDATE `ticket` t
+  JOIN `payment` p ON p.id = t.payment_id
+  SET t.active = NULL
+  WHERE p.payment_status = 'failed';
+
+UPDATE `ticket` t
+  JOIN `ticket` older ON older.schedule_id = t.schedule_id AND older.seat_id = t.seat_id AND older.id < t.id AND older.active = 1
+  SET t.active = NULL
+  WHERE t.active = 1;
+
+ALTER TABLE `ticket`
+  ADD UNIQUE KEY `active_seat` (`schedule_id`, `seat_id`, `active`);
@@ -0,0 +1,5 @@
+ALTER TABLE `seat`
+  DROP COLUMN `blocked`,
+  DROP COLUMN `position`;
+
+DROP TABLE IF EXISTS `theatre_seat`;
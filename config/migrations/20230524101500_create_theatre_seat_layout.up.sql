@@ -0,0 +1,15 @@
+CREATE TABLE `theatre_seat` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `theatre_id` int(11) NOT NULL,
+  `row` char(1) NOT NULL,
+  `seat_number` int(11) NOT NULL,
+  `position` int(11) NOT NULL,
+  `seat_type` varchar(32) NOT NULL DEFAULT 'regular',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `theatre_row_position` (`theatre_id`, `row`, `position`),
+  CONSTRAINT `theatre_seat_ibfk_1` FOREIGN KEY (`theatre_id`) REFERENCES `theatre` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+ALTER TABLE `seat`
+  ADD COLUMN `position` int(11) DEFAULT NULL,
+  ADD COLUMN `blocked` tinyint(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `schedule_price`;
+
+ALTER TABLE `seat`
+  DROP COLUMN `seat_type`;
@@ -0,0 +1,10 @@
+ALTER TABLE `seat`
+  ADD COLUMN `seat_type` varchar(32) NOT NULL DEFAULT 'regular';
+
+CREATE TABLE `schedule_price` (
+  `schedule_id` int(11) NOT NULL,
+  `seat_type` varchar(32) NOT NULL,
+  `price` double NOT NULL,
+  PRIMARY KEY (`schedule_id`, `seat_type`),
+  CONSTRAINT `schedule_price_ibfk_1` FOREIGN KEY (`schedule_id`) REFERENCES `schedule` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS `refund`;
+
+ALTER TABLE `ticket`
+  DROP COLUMN `cancelled_at`,
+  DROP COLUMN `price`;
+
+UPDATE `payment` SET `payment_status` = 'failed' WHERE `payment_status` IN ('refunded', 'cancelled');
+
+ALTER TABLE `payment`
+  MODIFY `payment_status` enum('pending','completed','failed') DEFAULT NULL;
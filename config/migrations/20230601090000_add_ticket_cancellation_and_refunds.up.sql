@@ -0,0 +1,24 @@
+ALTER TABLE `payment`
+  MODIFY `payment_status` enum('pending','completed','failed','refunded','cancelled') DEFAULT NULL;
+
+ALTER TABLE `ticket`
+  ADD COLUMN `price` double DEFAULT NULL,
+  ADD COLUMN `cancelled_at` timestamp NULL DEFAULT NULL;
+
+UPDATE `ticket` t
+  JOIN `schedule` s ON s.id = t.schedule_id
+  SET t.price = s.price;
+
+CREATE TABLE `refund` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `payment_id` int(11) NOT NULL,
+  `ticket_id` int(11) NOT NULL,
+  `amount` decimal(10,2) NOT NULL,
+  `percentage` int(11) NOT NULL,
+  `created_at` timestamp NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  KEY `payment_id` (`payment_id`),
+  UNIQUE KEY `ticket_id` (`ticket_id`),
+  CONSTRAINT `refund_ibfk_1` FOREIGN KEY (`payment_id`) REFERENCES `payment` (`id`),
+  CONSTRAINT `refund_ibfk_2` FOREIGN KEY (`ticket_id`) REFERENCES `ticket` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
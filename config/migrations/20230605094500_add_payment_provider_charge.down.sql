@@ -0,0 +1,4 @@
+ALTER TABLE `payment`
+  DROP KEY `charge_id`,
+  DROP COLUMN `charge_id`,
+  DROP COLUMN `provider`;
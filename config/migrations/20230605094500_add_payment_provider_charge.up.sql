@@ -0,0 +1,4 @@
+ALTER TABLE `payment`
+  ADD COLUMN `provider` varchar(32) DEFAULT NULL,
+  ADD COLUMN `charge_id` varchar(64) DEFAULT NULL,
+  ADD UNIQUE KEY `charge_id` (`charge_id`);
@@ -0,0 +1,9 @@
+CREATE TABLE `payment_event` (
+  `id` varchar(64) NOT NULL,
+  `payment_id` int(11) NOT NULL,
+  `type` varchar(32) NOT NULL,
+  `created_at` timestamp NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  KEY `payment_id` (`payment_id`),
+  CONSTRAINT `payment_event_ibfk_1` FOREIGN KEY (`payment_id`) REFERENCES `payment` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
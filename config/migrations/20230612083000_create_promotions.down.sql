@@ -0,0 +1,7 @@
+ALTER TABLE `payment`
+  DROP COLUMN `discount`;
+
+DROP TABLE IF EXISTS `promotion_usage`;
+DROP TABLE IF EXISTS `promotion_branch`;
+DROP TABLE IF EXISTS `promotion_movie`;
+DROP TABLE IF EXISTS `promotion`;
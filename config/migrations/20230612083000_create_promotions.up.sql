@@ -0,0 +1,53 @@
+CREATE TABLE `promotion` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `code` varchar(32) NOT NULL,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `type` enum('percentage','fixed','bogo') NOT NULL,
+  `value` double NOT NULL DEFAULT 0,
+  `min_seats` int(11) NOT NULL DEFAULT 1,
+  `days` set('sunday','monday','tuesday','wednesday','thursday','friday','saturday') DEFAULT NULL,
+  `usage_limit` int(11) DEFAULT NULL,
+  `customer_limit` int(11) DEFAULT NULL,
+  `valid_from` datetime DEFAULT NULL,
+  `valid_until` datetime DEFAULT NULL,
+  `active` tinyint(1) NOT NULL DEFAULT 1,
+  `created_at` timestamp NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `code` (`code`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE `promotion_movie` (
+  `promotion_id` int(11) NOT NULL,
+  `movie_id` int(11) NOT NULL,
+  PRIMARY KEY (`promotion_id`, `movie_id`),
+  KEY `movie_id` (`movie_id`),
+  CONSTRAINT `promotion_movie_ibfk_1` FOREIGN KEY (`promotion_id`) REFERENCES `promotion` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `promotion_movie_ibfk_2` FOREIGN KEY (`movie_id`) REFERENCES `movie` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE `promotion_branch` (
+  `promotion_id` int(11) NOT NULL,
+  `branch_id` int(11) NOT NULL,
+  PRIMARY KEY (`promotion_id`, `branch_id`),
+  KEY `branch_id` (`branch_id`),
+  CONSTRAINT `promotion_branch_ibfk_1` FOREIGN KEY (`promotion_id`) REFERENCES `promotion` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `promotion_branch_ibfk_2` FOREIGN KEY (`branch_id`) REFERENCES `branch` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE `promotion_usage` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `promotion_id` int(11) NOT NULL,
+  `customer_id` int(11) NOT NULL,
+  `payment_id` int(11) NOT NULL,
+  `discount` double NOT NULL,
+  `created_at` timestamp NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `payment_id` (`payment_id`),
+  KEY `promotion_customer` (`promotion_id`, `customer_id`),
+  CONSTRAINT `promotion_usage_ibfk_1` FOREIGN KEY (`promotion_id`) REFERENCES `promotion` (`id`),
+  CONSTRAINT `promotion_usage_ibfk_2` FOREIGN KEY (`customer_id`) REFERENCES `customer` (`id`),
+  CONSTRAINT `promotion_usage_ibfk_3` FOREIGN KEY (`payment_id`) REFERENCES `payment` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+ALTER TABLE `payment`
+  ADD COLUMN `discount` double NOT NULL DEFAULT 0;
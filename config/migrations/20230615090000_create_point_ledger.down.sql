@@ -0,0 +1,4 @@
+ALTER TABLE `payment`
+  DROP COLUMN `points_discount`;
+
+DROP TABLE IF EXISTS `point_ledger`;
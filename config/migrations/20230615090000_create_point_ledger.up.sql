@@ -0,0 +1,20 @@
+CREATE TABLE `point_ledger` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `customer_id` int(11) NOT NULL,
+  `payment_id` int(11) DEFAULT NULL,
+  `type` enum('earn','redeem','reverse','restore','expire') NOT NULL,
+  `points` int(11) NOT NULL,
+  `remaining` int(11) NOT NULL DEFAULT 0,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `expires_at` datetime DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  KEY `customer_remaining` (`customer_id`, `remaining`),
+  KEY `payment_id` (`payment_id`),
+  KEY `expires_at` (`expires_at`),
+  CONSTRAINT `point_ledger_ibfk_1` FOREIGN KEY (`customer_id`) REFERENCES `customer` (`id`),
+  CONSTRAINT `point_ledger_ibfk_2` FOREIGN KEY (`payment_id`) REFERENCES `payment` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+ALTER TABLE `payment`
+  ADD COLUMN `points_discount` double NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `wallet_topup`;
+DROP TABLE IF EXISTS `wallet_entry`;
+DROP TABLE IF EXISTS `wallet_transaction`;
+DROP TABLE IF EXISTS `wallet_account`;
@@ -0,0 +1,48 @@
+CREATE TABLE `wallet_account` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL,
+  `customer_id` int(11) DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `name` (`name`),
+  UNIQUE KEY `customer_id` (`customer_id`),
+  CONSTRAINT `wallet_account_ibfk_1` FOREIGN KEY (`customer_id`) REFERENCES `customer` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+INSERT INTO `wallet_account` (`name`) VALUES ('gateway'), ('sales');
+
+CREATE TABLE `wallet_transaction` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `type` enum('topup','payment','refund') NOT NULL,
+  `payment_id` int(11) DEFAULT NULL,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `created_at` timestamp NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  KEY `payment_id` (`payment_id`),
+  CONSTRAINT `wallet_transaction_ibfk_1` FOREIGN KEY (`payment_id`) REFERENCES `payment` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE `wallet_entry` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `transaction_id` int(11) NOT NULL,
+  `account_id` int(11) NOT NULL,
+  `amount` decimal(12,2) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `transaction_id` (`transaction_id`),
+  KEY `account_id` (`account_id`),
+  CONSTRAINT `wallet_entry_ibfk_1` FOREIGN KEY (`transaction_id`) REFERENCES `wallet_transaction` (`id`),
+  CONSTRAINT `wallet_entry_ibfk_2` FOREIGN KEY (`account_id`) REFERENCES `wallet_account` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE `wallet_topup` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `customer_id` int(11) NOT NULL,
+  `payment_id` int(11) NOT NULL,
+  `amount` decimal(12,2) NOT NULL,
+  `created_at` timestamp NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `payment_id` (`payment_id`),
+  KEY `customer_id` (`customer_id`),
+  CONSTRAINT `wallet_topup_ibfk_1` FOREIGN KEY (`customer_id`) REFERENCES `customer` (`id`),
+  CONSTRAINT `wallet_topup_ibfk_2` FOREIGN KEY (`payment_id`) REFERENCES `payment` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1,5 @@
+ALTER TABLE `payment`
+  DROP COLUMN `gift_card_amount`;
+
+DROP TABLE IF EXISTS `gift_card_redemption`;
+DROP TABLE IF EXISTS `gift_card`;
@@ -0,0 +1,36 @@
+CREATE TABLE `gift_card` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `code` varchar(19) NOT NULL,
+  `amount` decimal(12,2) NOT NULL,
+  `status` enum('pending','active','inactive') NOT NULL DEFAULT 'pending',
+  `recipient_name` varchar(255) DEFAULT NULL,
+  `recipient_email` varchar(255) DEFAULT NULL,
+  `message` text DEFAULT NULL,
+  `batch` varchar(255) DEFAULT NULL,
+  `expires_at` datetime DEFAULT NULL,
+  `purchaser_id` int(11) DEFAULT NULL,
+  `payment_id` int(11) DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `code` (`code`),
+  UNIQUE KEY `payment_id` (`payment_id`),
+  KEY `batch` (`batch`),
+  CONSTRAINT `gift_card_ibfk_1` FOREIGN KEY (`purchaser_id`) REFERENCES `customer` (`id`),
+  CONSTRAINT `gift_card_ibfk_2` FOREIGN KEY (`payment_id`) REFERENCES `payment` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE `gift_card_redemption` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `gift_card_id` int(11) NOT NULL,
+  `payment_id` int(11) NOT NULL,
+  `amount` decimal(12,2) NOT NULL,
+  `created_at` timestamp NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  KEY `gift_card_id` (`gift_card_id`),
+  KEY `payment_id` (`payment_id`),
+  CONSTRAINT `gift_card_redemption_ibfk_1` FOREIGN KEY (`gift_card_id`) REFERENCES `gift_card` (`id`),
+  CONSTRAINT `gift_card_redemption_ibfk_2` FOREIGN KEY (`payment_id`) REFERENCES `payment` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+ALTER TABLE `payment`
+  ADD COLUMN `gift_card_amount` double NOT NULL DEFAULT 0;
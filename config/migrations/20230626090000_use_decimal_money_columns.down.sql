@@ -0,0 +1,23 @@
+ALTER TABLE `promotion_usage`
+  MODIFY COLUMN `discount` double NOT NULL;
+
+ALTER TABLE `promotion`
+  MODIFY COLUMN `value` double NOT NULL DEFAULT 0;
+
+ALTER TABLE `refund`
+  MODIFY COLUMN `amount` decimal(10,2) NOT NULL;
+
+ALTER TABLE `payment`
+  MODIFY COLUMN `amount` decimal(10,2) NOT NULL,
+  MODIFY COLUMN `discount` double NOT NULL DEFAULT 0,
+  MODIFY COLUMN `points_discount` double NOT NULL DEFAULT 0,
+  MODIFY COLUMN `gift_card_amount` double NOT NULL DEFAULT 0;
+
+ALTER TABLE `ticket`
+  MODIFY COLUMN `price` double DEFAULT NULL;
+
+ALTER TABLE `schedule_price`
+  MODIFY COLUMN `price` double NOT NULL;
+
+ALTER TABLE `schedule`
+  MODIFY COLUMN `price` double DEFAULT NULL;
@@ -0,0 +1,23 @@
+ALTER TABLE `schedule`
+  MODIFY COLUMN `price` decimal(12,2) DEFAULT NULL;
+
+ALTER TABLE `schedule_price`
+  MODIFY COLUMN `price` decimal(12,2) NOT NULL;
+
+ALTER TABLE `ticket`
+  MODIFY COLUMN `price` decimal(12,2) DEFAULT NULL;
+
+ALTER TABLE `payment`
+  MODIFY COLUMN `amount` decimal(12,2) NOT NULL,
+  MODIFY COLUMN `discount` decimal(12,2) NOT NULL DEFAULT 0,
+  MODIFY COLUMN `points_discount` decimal(12,2) NOT NULL DEFAULT 0,
+  MODIFY COLUMN `gift_card_amount` decimal(12,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE `refund`
+  MODIFY COLUMN `amount` decimal(12,2) NOT NULL;
+
+ALTER TABLE `promotion`
+  MODIFY COLUMN `value` decimal(12,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE `promotion_usage`
+  MODIFY COLUMN `discount` decimal(12,2) NOT NULL;
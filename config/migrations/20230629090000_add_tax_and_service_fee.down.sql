@@ -0,0 +1,13 @@
+ALTER TABLE `payment`
+  DROP COLUMN `tax_jurisdiction`,
+  DROP COLUMN `tax_rate`,
+  DROP COLUMN `tax`,
+  DROP COLUMN `service_fee`,
+  DROP COLUMN `base_price`;
+
+ALTER TABLE `branch`
+  DROP FOREIGN KEY `branch_ibfk_1`,
+  DROP KEY `tax_jurisdiction_id`,
+  DROP COLUMN `tax_jurisdiction_id`;
+
+DROP TABLE IF EXISTS `tax_jurisdiction`;
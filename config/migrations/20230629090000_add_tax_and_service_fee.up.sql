@@ -0,0 +1,23 @@
+CREATE TABLE `tax_jurisdiction` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `name` varchar(255) NOT NULL,
+  `tax_rate` decimal(5,2) NOT NULL,
+  `service_fee` decimal(12,2) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+INSERT INTO `tax_jurisdiction` (`id`, `name`, `tax_rate`, `service_fee`) VALUES
+(1, 'Default', 10.00, 4000.00);
+
+ALTER TABLE `branch`
+  ADD COLUMN `tax_jurisdiction_id` int(11) NOT NULL DEFAULT 1,
+  ADD KEY `tax_jurisdiction_id` (`tax_jurisdiction_id`),
+  ADD CONSTRAINT `branch_ibfk_1` FOREIGN KEY (`tax_jurisdiction_id`) REFERENCES `tax_jurisdiction` (`id`);
+
+ALTER TABLE `payment`
+  ADD COLUMN `base_price` decimal(12,2) DEFAULT NULL,
+  ADD COLUMN `service_fee` decimal(12,2) NOT NULL DEFAULT 0,
+  ADD COLUMN `tax` decimal(12,2) NOT NULL DEFAULT 0,
+  ADD COLUMN `tax_rate` decimal(5,2) NOT NULL DEFAULT 0,
+  ADD COLUMN `tax_jurisdiction` varchar(255) DEFAULT NULL;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS `public_holiday`;
+DROP TABLE IF EXISTS `pricing_rule`;
+
+ALTER TABLE `schedule`
+  DROP COLUMN `format`;
@@ -0,0 +1,24 @@
+ALTER TABLE `schedule`
+  ADD COLUMN `format` varchar(32) DEFAULT NULL;
+
+CREATE TABLE `pricing_rule` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `name` varchar(255) NOT NULL,
+  `priority` int(11) NOT NULL DEFAULT 0,
+  `day_type` enum('weekday','weekend','holiday') DEFAULT NULL,
+  `start_time` time DEFAULT NULL,
+  `end_time` time DEFAULT NULL,
+  `format` varchar(32) DEFAULT NULL,
+  `min_occupancy` decimal(5,2) DEFAULT NULL,
+  `adjustment_type` enum('percentage','fixed') NOT NULL,
+  `adjustment` decimal(12,2) NOT NULL,
+  `active` tinyint(1) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  KEY `active_priority` (`active`, `priority`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE `public_holiday` (
+  `date` date NOT NULL,
+  `name` varchar(255) NOT NULL,
+  PRIMARY KEY (`date`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1,5 @@
+ALTER TABLE `schedule`
+  DROP KEY `theatre_show_time`;
+
+ALTER TABLE `theatre`
+  DROP COLUMN `cleanup_minutes`;
@@ -0,0 +1,5 @@
+ALTER TABLE `theatre`
+  ADD COLUMN `cleanup_minutes` int(11) NOT NULL DEFAULT 15;
+
+ALTER TABLE `schedule`
+  ADD KEY `theatre_show_time` (`theatre_id`, `show_time`);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `schedule_template_price`;
+DROP TABLE IF EXISTS `schedule_template`;
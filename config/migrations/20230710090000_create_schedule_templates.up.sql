@@ -0,0 +1,25 @@
+CREATE TABLE `schedule_template` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `movie_id` int(11) NOT NULL,
+  `theatre_id` int(11) NOT NULL,
+  `days` set('sunday','monday','tuesday','wednesday','thursday','friday','saturday') NOT NULL,
+  `start_times` varchar(255) NOT NULL,
+  `start_date` date NOT NULL,
+  `end_date` date NOT NULL,
+  `price` decimal(12,2) NOT NULL,
+  `format` varchar(32) DEFAULT NULL,
+  `created_at` timestamp NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  KEY `movie_id` (`movie_id`),
+  KEY `theatre_id` (`theatre_id`),
+  CONSTRAINT `schedule_template_ibfk_1` FOREIGN KEY (`movie_id`) REFERENCES `movie` (`id`),
+  CONSTRAINT `schedule_template_ibfk_2` FOREIGN KEY (`theatre_id`) REFERENCES `theatre` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE `schedule_template_price` (
+  `template_id` int(11) NOT NULL,
+  `seat_type` varchar(32) NOT NULL,
+  `price` decimal(12,2) NOT NULL,
+  PRIMARY KEY (`template_id`, `seat_type`),
+  CONSTRAINT `schedule_template_price_ibfk_1` FOREIGN KEY (`template_id`) REFERENCES `schedule_template` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1,2 @@
+ALTER TABLE `movie`
+  DROP COLUMN `run_end_date`;
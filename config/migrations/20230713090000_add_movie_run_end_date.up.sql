@@ -0,0 +1,2 @@
+ALTER TABLE `movie`
+  ADD COLUMN `run_end_date` date DEFAULT NULL;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS `movie_credit`;
+DROP TABLE IF EXISTS `movie_genre`;
+DROP TABLE IF EXISTS `genre`;
+
+ALTER TABLE `movie`
+  DROP COLUMN `classification`,
+  DROP COLUMN `subtitles`,
+  DROP COLUMN `language`;
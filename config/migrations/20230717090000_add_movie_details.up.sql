@@ -0,0 +1,33 @@
+ALTER TABLE `movie`
+  ADD COLUMN `language` varchar(64) DEFAULT NULL,
+  ADD COLUMN `subtitles` varchar(255) DEFAULT NULL,
+  ADD COLUMN `classification` enum('SU','13+','17+','21+') NOT NULL DEFAULT 'SU';
+
+CREATE TABLE `genre` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE `movie_genre` (
+  `movie_id` int(11) NOT NULL,
+  `genre_id` int(11) NOT NULL,
+  PRIMARY KEY (`movie_id`, `genre_id`),
+  KEY `genre_id` (`genre_id`),
+  CONSTRAINT `movie_genre_ibfk_1` FOREIGN KEY (`movie_id`) REFERENCES `movie` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `movie_genre_ibfk_2` FOREIGN KEY (`genre_id`) REFERENCES `genre` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE `movie_credit` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `movie_id` int(11) NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `role` enum('cast','director','writer','producer') NOT NULL,
+  `character_name` varchar(255) DEFAULT NULL,
+  `position` int(11) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  KEY `movie_position` (`movie_id`, `position`),
+  KEY `name` (`name`),
+  CONSTRAINT `movie_credit_ibfk_1` FOREIGN KEY (`movie_id`) REFERENCES `movie` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
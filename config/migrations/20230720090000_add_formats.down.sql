@@ -0,0 +1,14 @@
+ALTER TABLE `schedule`
+  DROP KEY `movie_format`;
+
+ALTER TABLE `movie`
+  DROP COLUMN `formats`;
+
+ALTER TABLE `theatre`
+  DROP COLUMN `formats`;
+
+ALTER TABLE `schedule_template`
+  MODIFY `format` varchar(32) DEFAULT NULL;
+
+ALTER TABLE `schedule`
+  MODIFY `format` varchar(32) DEFAULT NULL;
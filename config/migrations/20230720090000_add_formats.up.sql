@@ -0,0 +1,40 @@
+-- formats were free text until now, so they are normalized first and every
+-- theatre and movie keeps the formats it is already scheduled in
+UPDATE `schedule`
+  SET `format` = CASE upper(replace(trim(`format`), ' ', '_'))
+    WHEN '3D' THEN '3D'
+    WHEN 'IMAX' THEN 'IMAX'
+    WHEN '4DX' THEN '4DX'
+    WHEN 'DOLBY_ATMOS' THEN 'DOLBY_ATMOS'
+    ELSE '2D'
+  END;
+ALTER TABLE `schedule`
+  MODIFY `format` varchar(32) NOT NULL DEFAULT '2D';
+
+UPDATE `schedule_template`
+  SET `format` = CASE upper(replace(trim(`format`), ' ', '_'))
+    WHEN '3D' THEN '3D'
+    WHEN 'IMAX' THEN 'IMAX'
+    WHEN '4DX' THEN '4DX'
+    WHEN 'DOLBY_ATMOS' THEN 'DOLBY_ATMOS'
+    ELSE '2D'
+  END;
+ALTER TABLE `schedule_template`
+  MODIFY `format` varchar(32) NOT NULL DEFAULT '2D';
+
+UPDATE `pricing_rule`
+  SET `format` = upper(replace(trim(`format`), ' ', '_'))
+  WHERE `format` IS NOT NULL;
+
+ALTER TABLE `theatre`
+  ADD COLUMN `formats` set('2D','3D','IMAX','4DX','DOLBY_ATMOS') NOT NULL DEFAULT '2D';
+UPDATE `theatre` t
+  SET t.`formats` = concat_ws(',', '2D', (SELECT group_concat(DISTINCT s.`format`) FROM `schedule` s WHERE s.`theatre_id` = t.`id`));
+
+ALTER TABLE `movie`
+  ADD COLUMN `formats` set('2D','3D','IMAX','4DX','DOLBY_ATMOS') NOT NULL DEFAULT '2D';
+UPDATE `movie` m
+  SET m.`formats` = concat_ws(',', '2D', (SELECT group_concat(DISTINCT s.`format`) FROM `schedule` s WHERE s.`movie_id` = m.`id`));
+
+ALTER TABLE `schedule`
+  ADD KEY `movie_format` (`movie_id`, `format`);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `movie_media`;
+
+ALTER TABLE `movie`
+  DROP COLUMN `trailer_url`;
@@ -0,0 +1,15 @@
+ALTER TABLE `movie`
+  ADD COLUMN `trailer_url` varchar(512) DEFAULT NULL;
+
+CREATE TABLE `movie_media` (
+  `movie_id` int(11) NOT NULL,
+  `kind` enum('poster','backdrop') NOT NULL,
+  `variant` varchar(16) NOT NULL,
+  `storage_key` varchar(255) NOT NULL,
+  `width` int(11) NOT NULL,
+  `height` int(11) NOT NULL,
+  `content_type` varchar(32) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`movie_id`, `kind`, `variant`),
+  CONSTRAINT `movie_media_ibfk_1` FOREIGN KEY (`movie_id`) REFERENCES `movie` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
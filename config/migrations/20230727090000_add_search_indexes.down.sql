@@ -0,0 +1,9 @@
+ALTER TABLE `genre`
+  DROP KEY `name_search`;
+
+ALTER TABLE `movie_credit`
+  DROP KEY `name_search`;
+
+ALTER TABLE `movie`
+  DROP KEY `description_search`,
+  DROP KEY `title_search`;
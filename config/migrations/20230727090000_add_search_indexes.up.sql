@@ -0,0 +1,12 @@
+-- InnoDB adds one FULLTEXT index at a time.
+ALTER TABLE `movie`
+  ADD FULLTEXT KEY `title_search` (`title`);
+
+ALTER TABLE `movie`
+  ADD FULLTEXT KEY `description_search` (`description`);
+
+ALTER TABLE `movie_credit`
+  ADD FULLTEXT KEY `name_search` (`name`);
+
+ALTER TABLE `genre`
+  ADD FULLTEXT KEY `name_search` (`name`);
@@ -0,0 +1,6 @@
+ALTER TABLE `branch`
+  DROP KEY `city`,
+  DROP COLUMN `city`;
+
+ALTER TABLE `movie`
+  DROP COLUMN `run_start_date`;
@@ -0,0 +1,6 @@
+ALTER TABLE `movie`
+  ADD COLUMN `run_start_date` date DEFAULT NULL AFTER `release_date`;
+
+ALTER TABLE `branch`
+  ADD COLUMN `city` varchar(100) DEFAULT NULL AFTER `address`,
+  ADD KEY `city` (`city`);
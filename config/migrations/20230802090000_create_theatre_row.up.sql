@@ -0,0 +1,8 @@
+CREATE TABLE `theatre_row` (
+  `theatre_id` int(11) NOT NULL,
+  `row` char(1) NOT NULL,
+  `width` int(11) NOT NULL,
+  `seat_type` varchar(32) DEFAULT NULL,
+  PRIMARY KEY (`theatre_id`, `row`),
+  CONSTRAINT `theatre_row_ibfk_1` FOREIGN KEY (`theatre_id`) REFERENCES `theatre` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1,14 @@
+ALTER TABLE `wallet_account`
+  DROP COLUMN `currency`;
+
+ALTER TABLE `gift_card`
+  DROP COLUMN `currency`;
+
+ALTER TABLE `refund`
+  DROP COLUMN `currency`;
+
+ALTER TABLE `payment`
+  DROP COLUMN `currency`;
+
+ALTER TABLE `schedule`
+  DROP COLUMN `currency`;
@@ -0,0 +1,14 @@
+ALTER TABLE `schedule`
+  ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'IDR' AFTER `price`;
+
+ALTER TABLE `payment`
+  ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'IDR' AFTER `amount`;
+
+ALTER TABLE `refund`
+  ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'IDR' AFTER `amount`;
+
+ALTER TABLE `gift_card`
+  ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'IDR' AFTER `amount`;
+
+ALTER TABLE `wallet_account`
+  ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'IDR';
@@ -0,0 +1,8 @@
+ALTER TABLE `pricing_rule`
+  ADD COLUMN `adjustment` decimal(12,2) NOT NULL DEFAULT 0 AFTER `adjustment_type`;
+
+UPDATE `pricing_rule` SET `adjustment` = if(`adjustment_type` = 'percentage', `percentage`, `amount`);
+
+ALTER TABLE `pricing_rule`
+  DROP COLUMN `amount`,
+  DROP COLUMN `percentage`;
@@ -0,0 +1,9 @@
+ALTER TABLE `pricing_rule`
+  ADD COLUMN `percentage` decimal(6,2) NOT NULL DEFAULT 0 AFTER `adjustment_type`,
+  ADD COLUMN `amount` decimal(12,2) NOT NULL DEFAULT 0 AFTER `percentage`;
+
+UPDATE `pricing_rule` SET `percentage` = `adjustment` WHERE `adjustment_type` = 'percentage';
+UPDATE `pricing_rule` SET `amount` = `adjustment` WHERE `adjustment_type` = 'fixed';
+
+ALTER TABLE `pricing_rule`
+  DROP COLUMN `adjustment`;
@@ -0,0 +1,9 @@
+ALTER TABLE `promotion`
+  ADD COLUMN `value` double NOT NULL DEFAULT 0 AFTER `type`;
+
+UPDATE `promotion` SET `value` = if(`type` = 'percentage', `percentage`, `amount`);
+
+ALTER TABLE `promotion`
+  DROP COLUMN `currency`,
+  DROP COLUMN `amount`,
+  DROP COLUMN `percentage`;
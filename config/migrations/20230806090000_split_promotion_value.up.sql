@@ -0,0 +1,10 @@
+ALTER TABLE `promotion`
+  ADD COLUMN `percentage` decimal(5,2) NOT NULL DEFAULT 0 AFTER `type`,
+  ADD COLUMN `amount` decimal(12,2) NOT NULL DEFAULT 0 AFTER `percentage`,
+  ADD COLUMN `currency` char(3) NOT NULL DEFAULT 'IDR' AFTER `amount`;
+
+UPDATE `promotion` SET `percentage` = `value` WHERE `type` = 'percentage';
+UPDATE `promotion` SET `amount` = `value` WHERE `type` = 'fixed';
+
+ALTER TABLE `promotion`
+  DROP COLUMN `value`;
@@ -0,0 +1,473 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/go-redis/redis"
+	"github.com/go-sql-driver/mysql"
+)
+
+const mysqlDuplicateEntry = 1062
+
+var (
+	errNoSeats      = errors.New("no seats to book")
+	errSeatNotFound = errors.New("the seat is not match with the schedule")
+	errSeatTaken    = errors.New("the seat is taken")
+	errSeatHeld     = errors.New("the seat is held by another customer")
+	errSeatBlocked  = errors.New("the seat is not available for this schedule")
+
+	errCompanionAlone     = errors.New("a companion seat can only be booked with the wheelchair seat next to it")
+	errWheelchairReserved = errors.New("wheelchair seats are kept for customers who need one until shortly before the show")
+
+	errTicketNotCancellable = errors.New("the ticket is already cancelled or its payment has failed")
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// placeholders returns "?,?,...,?" for an IN clause with n values.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// uniqueSeatIds removes duplicates and sorts the ids, so rows are always
+// locked in the same order.
+func uniqueSeatIds(seatIds []int) []int {
+	seen := map[int]bool{}
+	var ids []int
+	for _, id := range seatIds {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// checkSeatHolds makes sure none of the seats is held by someone else. Seats
+// held under the customer's own hold token are allowed, and the prices that
+// hold locked in are returned.
+func checkSeatHolds(redisClient *redis.Client, customerId, scheduleId int, seatIds []int, holdToken string) (map[int]models.Money, error) {
+	holders, err := tool.SeatHolders(redisClient, scheduleId, seatIds)
+	if err != nil {
+		return nil, err
+	}
+	if len(holders) == 0 {
+		return nil, nil
+	}
+	hold, err := tool.GetHold(redisClient, holdToken)
+	if err != nil || hold.CustomerID != customerId {
+		return nil, errSeatHeld
+	}
+	for _, token := range holders {
+		if token != holdToken {
+			return nil, errSeatHeld
+		}
+	}
+	prices := map[int]models.Money{}
+	for seatId := range holders {
+		if price, ok := hold.Prices[seatId]; ok {
+			prices[seatId] = price
+		}
+	}
+	return prices, nil
+}
+
+// getScheduleTicket loads the schedule together with its movie and branch.
+func getScheduleTicket(q queryer, scheduleId int) (models.ScheduleTicket, error) {
+	var schedule models.ScheduleTicket
+	var movie models.Movie
+	var theatre models.Theatre
+	var branch models.BranchTheatre
+	var price models.Money
+	var showtime time.Time
+	err := q.QueryRow("select s.id, s.price, s.currency, s.show_time, m.id, m.title, m.description, m.duration, m.rating, m.release_date, m.classification, b.id, b.name, b.address, t.id, t.name from schedule s join movie m on m.id = s.movie_id join theatre t on t.id = s.theatre_id join branch b on b.id = t.branch_id where s.id = ?", scheduleId).Scan(&schedule.ID, &price, &schedule.Currency, &showtime, &movie.ID, &movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate, &movie.Classification, &branch.ID, &branch.Name, &branch.Address, &theatre.ID, &theatre.Name)
+	if err != nil {
+		return schedule, err
+	}
+	branch.Theatre = theatre
+	schedule.Price = &price
+	schedule.Showtime = &showtime
+	schedule.Movie = &movie
+	schedule.Branch = &branch
+	return schedule, nil
+}
+
+// checkAccessibleSeats enforces the rules of the wheelchair spaces among the
+// seats taken together: a companion seat only goes with the wheelchair seat
+// next to it in the row, and until tool.WheelchairRelease before the show a
+// wheelchair seat is only for a customer who needs the space. Seats the
+// customer already holds were checked when they were held.
+func checkAccessibleSeats(seats []models.Seat, accessible bool, held map[int]models.Money, showTime, now time.Time) error {
+	type place struct {
+		row      string
+		position int
+	}
+	wheelchairs := map[place]bool{}
+	for _, seat := range seats {
+		if seat.Type != models.Wheelchair {
+			continue
+		}
+		if _, ok := held[seat.ID]; !ok && !accessible && now.Add(tool.WheelchairRelease()).Before(showTime) {
+			return errWheelchairReserved
+		}
+		wheelchairs[place{seat.Row, seat.Position}] = true
+	}
+	for _, seat := range seats {
+		if seat.Type == models.Companion && !wheelchairs[place{seat.Row, seat.Position - 1}] && !wheelchairs[place{seat.Row, seat.Position + 1}] {
+			return errCompanionAlone
+		}
+	}
+	return nil
+}
+
+// scheduleSeats returns the seats of the schedule with the given ids, the
+// ones that don't belong to it are left out.
+func scheduleSeats(q queryer, scheduleId int, seatIds []int) ([]models.Seat, error) {
+	args := []interface{}{scheduleId}
+	for _, id := range seatIds {
+		args = append(args, id)
+	}
+	rows, err := q.Query("select id, row, seat_number, IFNULL(position, 0), seat_type from seat where schedule_id = ? and id in ("+placeholders(len(seatIds))+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var seats []models.Seat
+	for rows.Next() {
+		var seat models.Seat
+		if err := rows.Scan(&seat.ID, &seat.Row, &seat.Number, &seat.Position, &seat.Type); err != nil {
+			return nil, err
+		}
+		seats = append(seats, seat)
+	}
+	return seats, rows.Err()
+}
+
+// bookingDiscounts is what the customer uses to lower the price of a
+// booking, applied in this order: the promo code, the points, then the gift card.
+type bookingDiscounts struct {
+	PromoCode    string
+	Points       int
+	GiftCardCode string
+}
+
+// bookSeats books every seat of the order under a single pending payment, or
+// none of them. Seats are priced by the pricing rules, unless the customer's
+// hold locked in their price. Every discount given must apply too, or nothing
+// is booked. Wheelchair and companion seats follow checkAccessibleSeats.
+// Points and gift cards never cover more than what is left to pay. It must
+// run inside a transaction: the seat rows are locked with SELECT ... FOR
+// UPDATE until the transaction ends, and the active_seat unique key on ticket
+// is the last line of defence against double booking.
+func bookSeats(tx *sql.Tx, customerId int, schedule models.ScheduleTicket, seatIds []int, heldPrices map[int]models.Money, accessible bool, discounts bookingDiscounts) (models.Order, error) {
+	var order models.Order
+	seatIds = uniqueSeatIds(seatIds)
+	if len(seatIds) == 0 {
+		return order, errNoSeats
+	}
+
+	args := []interface{}{schedule.ID}
+	for _, id := range seatIds {
+		args = append(args, id)
+	}
+
+	// lock the seats and verify they belong to the schedule
+	rows, err := tx.Query("select id, row, seat_number, IFNULL(position, 0), seat_type, blocked from seat where schedule_id = ? and id in ("+placeholders(len(seatIds))+") order by id for update", args...)
+	if err != nil {
+		return order, err
+	}
+	var seats []models.Seat
+	for rows.Next() {
+		var seat models.Seat
+		if err := rows.Scan(&seat.ID, &seat.Row, &seat.Number, &seat.Position, &seat.Type, &seat.Blocked); err != nil {
+			rows.Close()
+			return order, err
+		}
+		if seat.Blocked {
+			rows.Close()
+			return order, errSeatBlocked
+		}
+		seats = append(seats, seat)
+	}
+	rows.Close()
+	if len(seats) != len(seatIds) {
+		return order, errSeatNotFound
+	}
+	if schedule.Showtime != nil {
+		if err := checkAccessibleSeats(seats, accessible, heldPrices, *schedule.Showtime, time.Now()); err != nil {
+			return order, err
+		}
+	}
+
+	// verify none of the seats is taken yet
+	var count int
+	err = tx.QueryRow("select count(*) from ticket where schedule_id = ? and seat_id in ("+placeholders(len(seatIds))+") and active = 1", args...).Scan(&count)
+	if err != nil {
+		return order, err
+	}
+	if count > 0 {
+		return order, errSeatTaken
+	}
+
+	// make a single payment for all seats, each seat priced by its type
+	pricing, err := loadSchedulePricing(tx, schedule.ID)
+	if err != nil {
+		return order, err
+	}
+	var seatPrices []models.Money
+	for i := range seats {
+		price, held := heldPrices[seats[i].ID]
+		if !held {
+			price = pricing.seatPrice(seats[i].Type)
+		}
+		seats[i].Price = &price
+		seatPrices = append(seatPrices, price)
+	}
+
+	// a promo code takes its discount off the whole payment
+	var promo models.Promotion
+	var discount models.Money
+	if discounts.PromoCode != "" {
+		promo, err = loadPromotion(tx, "code", strings.ToUpper(strings.TrimSpace(discounts.PromoCode)), true)
+		if err == sql.ErrNoRows {
+			return order, errPromoNotFound
+		} else if err != nil {
+			return order, err
+		}
+		discount, err = evaluatePromotion(tx, promo, customerId, schedule, seatPrices, time.Now())
+		if err != nil {
+			return order, err
+		}
+	}
+
+	// fees and tax are added before points and gift cards, which only pay for the total
+	jurisdiction, err := scheduleJurisdiction(tx, schedule.ID)
+	if err != nil {
+		return order, err
+	}
+	breakdown := priceBreakdown(jurisdiction, seatPrices, discount)
+	amount := breakdown.Total
+
+	points := discounts.Points
+	var pointsDiscount models.Money
+	if points > 0 {
+		policy := tool.LoadPointsPolicy()
+		// no more points than it takes to cover the amount, rounding up
+		if maxPoints := int((amount + policy.PointValue - 1) / policy.PointValue); points > maxPoints {
+			points = maxPoints
+		}
+		pointsDiscount = policy.Value(points).Min(amount)
+		amount -= pointsDiscount
+	}
+
+	res, err := tx.Exec("insert into payment(amount, currency, discount, points_discount, base_price, service_fee, tax, tax_rate, tax_jurisdiction, payment_status) values (?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending')", amount, schedule.Currency, breakdown.Discount, pointsDiscount, breakdown.BasePrice, breakdown.ServiceFee, breakdown.Tax, breakdown.TaxRate, breakdown.Jurisdiction)
+	if err != nil {
+		return order, err
+	}
+	paymentId, err := res.LastInsertId()
+	if err != nil {
+		return order, err
+	}
+	order.Payment = models.Payment{ID: int(paymentId), Amount: amount, Currency: schedule.Currency, Discount: breakdown.Discount, PointsUsed: points, PointsDiscount: pointsDiscount, Breakdown: &breakdown, Status: models.Pending}
+	if points > 0 {
+		if err := tool.RedeemPoints(tx, customerId, int(paymentId), points, time.Now()); err != nil {
+			return order, err
+		}
+	}
+	// the gift card pays from what is left, so it needs the payment to exist first
+	if discounts.GiftCardCode != "" {
+		used, err := redeemGiftCard(tx, discounts.GiftCardCode, int(paymentId), amount, schedule.Currency, time.Now())
+		if err != nil {
+			return order, err
+		}
+		amount -= used
+		if _, err := tx.Exec("update payment set amount = ?, gift_card_amount = ? where id = ?", amount, used, paymentId); err != nil {
+			return order, err
+		}
+		order.Payment.Amount = amount
+		order.Payment.GiftCardAmount = used
+	}
+	if discounts.PromoCode != "" {
+		if _, err := tx.Exec("insert into promotion_usage (promotion_id, customer_id, payment_id, discount) values (?, ?, ?, ?)", promo.ID, customerId, paymentId, breakdown.Discount); err != nil {
+			return order, err
+		}
+		order.Payment.PromoCode = promo.Code
+	}
+
+	res, err = tx.Exec("insert into orders(customer_id, schedule_id, payment_id) values (?, ?, ?)", customerId, schedule.ID, paymentId)
+	if err != nil {
+		return order, err
+	}
+	orderId, err := res.LastInsertId()
+	if err != nil {
+		return order, err
+	}
+	order.ID = int(orderId)
+
+	for _, seat := range seats {
+		res, err := tx.Exec("insert into ticket(customer_id, schedule_id, seat_id, payment_id, order_id, price) values (?,?,?,?,?,?)", customerId, schedule.ID, seat.ID, paymentId, orderId, *seat.Price)
+		if err != nil {
+			// the active_seat unique key rejects a second active booking of the seat
+			if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+				return order, errSeatTaken
+			}
+			return order, err
+		}
+		ticketId, err := res.LastInsertId()
+		if err != nil {
+			return order, err
+		}
+		order.Tickets = append(order.Tickets, models.Ticket{
+			ID:       int(ticketId),
+			Seat:     seat,
+			Schedule: models.ScheduleTicket{ID: schedule.ID},
+			Payment:  order.Payment,
+		})
+	}
+
+	order.Schedule = schedule
+	order.Amount = amount
+	return order, nil
+}
+
+// ticketShare returns the part of the amount paid that goes with a ticket.
+// The last ticket of the payment gets what the rounded shares of the others
+// leave, so the shares add up to the amount.
+func ticketShare(tx *sql.Tx, paymentId, ticketId int, price, amount, allTotal models.Money, last bool) (models.Money, error) {
+	if allTotal <= 0 {
+		return amount, nil
+	}
+	if !last {
+		return amount.Share(price, allTotal), nil
+	}
+	rows, err := tx.Query("select price from ticket where payment_id = ? and id <> ?", paymentId, ticketId)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	share := amount
+	for rows.Next() {
+		var other models.Money
+		if err := rows.Scan(&other); err != nil {
+			return 0, err
+		}
+		share -= amount.Share(other, allTotal)
+	}
+	return share, rows.Err()
+}
+
+// cancelBooking cancels a ticket of the customer inside the transaction. The
+// seat is released right away and the ticket's share of a completed payment
+// is refunded according to the policy. A pending payment is reduced instead,
+// or cancelled once none of its tickets is left.
+func cancelBooking(tx *sql.Tx, ticketId, customerId int, policy tool.RefundPolicy, now time.Time) (models.Refund, models.PaymentStatus, error) {
+	refund := models.Refund{TicketID: ticketId, CreatedAt: now}
+	var active sql.NullBool
+	var price models.Money
+	var cancelledAt sql.NullTime
+	var amount models.Money
+	var status models.PaymentStatus
+	var showTime time.Time
+	err := tx.QueryRow("select tc.payment_id, tc.active, tc.price, tc.cancelled_at, p.amount, p.currency, p.payment_status, s.show_time from ticket tc join payment p on p.id = tc.payment_id join schedule s on s.id = tc.schedule_id where tc.id = ? and tc.customer_id = ? for update", ticketId, customerId).Scan(&refund.PaymentID, &active, &price, &cancelledAt, &amount, &refund.Currency, &status, &showTime)
+	if err != nil {
+		return refund, status, err
+	}
+	if cancelledAt.Valid || !active.Valid || (status != models.Pending && status != models.Completed) {
+		return refund, status, errTicketNotCancellable
+	}
+
+	// the payment row is locked above, so concurrent cancellations of the same order wait for each other
+	var activeTotal, allTotal models.Money
+	var activeCount int
+	err = tx.QueryRow("select coalesce(sum(if(active = 1, price, 0)), 0), coalesce(sum(price), 0), coalesce(sum(active = 1), 0) from ticket where payment_id = ?", refund.PaymentID).Scan(&activeTotal, &allTotal, &activeCount)
+	if err != nil {
+		return refund, status, err
+	}
+
+	if _, err := tx.Exec("update ticket set active = NULL, cancelled_at = ? where id = ?", now, ticketId); err != nil {
+		return refund, status, err
+	}
+
+	if status == models.Pending {
+		if activeCount <= 1 {
+			if _, err := transitionPayment(tx, refund.PaymentID, models.Cancelled); err != nil {
+				return refund, status, err
+			}
+			return refund, status, tool.RestorePaymentCredits(tx, refund.PaymentID, now)
+		}
+		breakdown, err := loadPriceBreakdown(tx, refund.PaymentID)
+		if err != nil {
+			return refund, status, err
+		}
+		if breakdown == nil {
+			share := amount
+			if activeTotal > 0 {
+				share = amount.Share(price, activeTotal)
+			}
+			_, err = tx.Exec("update payment set amount = amount - ? where id = ?", share, refund.PaymentID)
+			return refund, status, err
+		}
+		// the breakdown is redone for the seats left, and the points and
+		// gift card used stay on the order, so the amount due is the new
+		// total less all of them
+		remaining := withoutSeat(*breakdown, price, activeTotal, activeCount)
+		due := remaining.Total - (breakdown.Total - amount)
+		if due < 0 {
+			due = 0
+		}
+		_, err = tx.Exec("update payment set amount = ?, base_price = ?, discount = ?, service_fee = ?, tax = ? where id = ?",
+			due, remaining.BasePrice, remaining.Discount, remaining.ServiceFee, remaining.Tax, refund.PaymentID)
+		return refund, status, err
+	}
+
+	share, err := ticketShare(tx, refund.PaymentID, ticketId, price, amount, allTotal, activeCount <= 1)
+	if err != nil {
+		return refund, status, err
+	}
+	var refunded models.Money
+	if err := tx.QueryRow("select coalesce(sum(amount), 0) from refund where payment_id = ?", refund.PaymentID).Scan(&refunded); err != nil {
+		return refund, status, err
+	}
+	refund.Percentage = policy.Percentage(showTime, now)
+	refund.Amount = share.Percent(float64(refund.Percentage)).Min(amount - refunded)
+	res, err := tx.Exec("insert into refund (payment_id, ticket_id, amount, currency, percentage, created_at) values (?, ?, ?, ?, ?, ?)", refund.PaymentID, ticketId, refund.Amount, refund.Currency, refund.Percentage, now)
+	if err != nil {
+		return refund, status, err
+	}
+	refundId, err := res.LastInsertId()
+	if err != nil {
+		return refund, status, err
+	}
+	refund.ID = int(refundId)
+
+	// the points earned with the ticket go back with it, and the gift card
+	// part of its price is refunded to the card like the rest
+	if allTotal > 0 {
+		ratio := price.Float64() / allTotal.Float64()
+		if err := tool.ReverseEarnedPoints(tx, refund.PaymentID, ratio, now); err != nil {
+			return refund, status, err
+		}
+		if err := tool.RestoreGiftCards(tx, refund.PaymentID, ratio*float64(refund.Percentage)/100); err != nil {
+			return refund, status, err
+		}
+	}
+
+	if activeCount <= 1 {
+		if _, err := transitionPayment(tx, refund.PaymentID, models.Refunded); err != nil {
+			return refund, status, err
+		}
+	}
+	return refund, status, nil
+}
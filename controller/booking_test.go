@@ -0,0 +1,409 @@
+package controller
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+	"tix-id/config"
+	"tix-id/middleware"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+)
+
+// testDB connects to the migrated MySQL database given by the TEST_DB_*
+// variables, which is written to, so it must not be one in use.
+func testDB(t *testing.T) *sql.DB {
+	if os.Getenv("TEST_DB_NAME") == "" {
+		t.Skip("set TEST_DB_HOST, TEST_DB_PORT, TEST_DB_USER, TEST_DB_PASSWORD and TEST_DB_NAME to a migrated test database")
+	}
+	for _, name := range []string{"HOST", "PORT", "USER", "PASSWORD", "NAME"} {
+		t.Setenv("DB_"+name, os.Getenv("TEST_DB_"+name))
+	}
+	db := config.ConnectDB()
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// testRedis connects to the Redis given by TEST_REDIS_ADDR, which the
+// handlers use too.
+func testRedis(t *testing.T) *redis.Client {
+	if os.Getenv("TEST_REDIS_ADDR") == "" {
+		t.Skip("set TEST_REDIS_ADDR to a Redis for the seat holds")
+	}
+	t.Setenv("REDIS_ADDR", os.Getenv("TEST_REDIS_ADDR"))
+	client := tool.NewRedisClient()
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping().Err(); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// insertID runs the insert and returns the id of the new row.
+func insertID(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
+
+// bookingFixture is a schedule tomorrow with a row of seats, and the
+// customers who book them.
+type bookingFixture struct {
+	suffix                                   string
+	movieID, branchID, theatreID, scheduleID int
+	seatIDs                                  []int
+	customerIDs                              []int
+}
+
+// newBookingFixture makes a schedule with a seat of each type in row A, a
+// regular one when no type is given.
+func newBookingFixture(t *testing.T, db *sql.DB, seatTypes ...models.SeatType) *bookingFixture {
+	if len(seatTypes) == 0 {
+		seatTypes = []models.SeatType{models.Regular}
+	}
+	f := &bookingFixture{suffix: fmt.Sprint(time.Now().UnixNano())}
+	t.Cleanup(func() { f.delete(t, db) })
+	f.movieID = insertID(t, db, "insert into movie (title, description, duration, rating, release_date, classification) values (?, 'A movie to book in tests', 120, 8.5, ?, 'SU')",
+		"Booking Test "+f.suffix, time.Now().AddDate(0, 0, -7))
+	f.branchID = insertID(t, db, "insert into branch (name, address) values (?, 'Jl. Test No. 1')", "Booking Test "+f.suffix)
+	f.theatreID = insertID(t, db, "insert into theatre (name, branch_id) values ('1', ?)", f.branchID)
+	f.scheduleID = insertID(t, db, "insert into schedule (movie_id, theatre_id, show_time, price) values (?, ?, ?, ?)",
+		f.movieID, f.theatreID, time.Now().Add(24*time.Hour), models.NewMoney(50000))
+	for i, seatType := range seatTypes {
+		f.seatIDs = append(f.seatIDs, insertID(t, db, "insert into seat (row, seat_number, position, seat_type, schedule_id) values ('A', ?, ?, ?, ?)", i+1, i+1, seatType, f.scheduleID))
+	}
+	return f
+}
+
+// newCustomer adds a customer who can book seats of the fixture.
+func (f *bookingFixture) newCustomer(t *testing.T, db *sql.DB) int {
+	name := fmt.Sprintf("booking-test-%s-%d", f.suffix, len(f.customerIDs))
+	id := insertID(t, db, "insert into customer (username, password, name, email, phone) values (?, '', 'Booking Test', ?, '')", name, name+"@example.com")
+	f.customerIDs = append(f.customerIDs, id)
+	return id
+}
+
+// delete removes the fixture and everything booked on it.
+func (f *bookingFixture) delete(t *testing.T, db *sql.DB) {
+	rows, err := db.Query("select payment_id from orders where schedule_id = ?", f.scheduleID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var paymentIds []interface{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Error(err)
+		}
+		paymentIds = append(paymentIds, id)
+	}
+	rows.Close()
+
+	if _, err := db.Exec("delete from ticket where schedule_id = ?", f.scheduleID); err != nil {
+		t.Error(err)
+	}
+	if _, err := db.Exec("delete from orders where schedule_id = ?", f.scheduleID); err != nil {
+		t.Error(err)
+	}
+	if len(paymentIds) > 0 {
+		if _, err := db.Exec("delete from payment where id in ("+placeholders(len(paymentIds))+")", paymentIds...); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, row := range []struct {
+		query string
+		id    int
+	}{
+		{"delete from seat where schedule_id = ?", f.scheduleID},
+		{"delete from schedule where id = ?", f.scheduleID},
+		{"delete from theatre where id = ?", f.theatreID},
+		{"delete from branch where id = ?", f.branchID},
+		{"delete from movie where id = ?", f.movieID},
+	} {
+		if _, err := db.Exec(row.query, row.id); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, id := range f.customerIDs {
+		if _, err := db.Exec("delete from customer where id = ?", id); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// activeTickets counts the active tickets of the seat.
+func (f *bookingFixture) activeTickets(t *testing.T, db *sql.DB, seatId int) int {
+	var tickets int
+	if err := db.QueryRow("select count(*) from ticket where schedule_id = ? and seat_id = ? and active = 1", f.scheduleID, seatId).Scan(&tickets); err != nil {
+		t.Fatal(err)
+	}
+	return tickets
+}
+
+// bookSeat books the seat in a transaction of its own, like CreateOrder.
+func bookSeat(db *sql.DB, f *bookingFixture, customerId, seatId int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	schedule, err := getScheduleTicket(tx, f.scheduleID)
+	if err != nil {
+		return err
+	}
+	if _, err := bookSeats(tx, customerId, schedule, []int{seatId}, nil, false, bookingDiscounts{}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// concurrently runs n calls at once and returns their results.
+func concurrently[T any](n int, call func(i int) T) []T {
+	start := make(chan struct{})
+	results := make([]T, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i] = call(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return results
+}
+
+func TestBookSeatsConcurrently(t *testing.T) {
+	db := testDB(t)
+	f := newBookingFixture(t, db)
+	customerId := f.newCustomer(t, db)
+
+	const bookings = 20
+	errs := concurrently(bookings, func(int) error {
+		return bookSeat(db, f, customerId, f.seatIDs[0])
+	})
+
+	var booked, taken int
+	for _, err := range errs {
+		switch err {
+		case nil:
+			booked++
+		case errSeatTaken:
+			taken++
+		default:
+			t.Errorf("bookSeats = %v, want nil or %v", err, errSeatTaken)
+		}
+	}
+	if booked != 1 || taken != bookings-1 {
+		t.Errorf("%d bookings succeeded and %d found the seat taken, want 1 and %d", booked, taken, bookings-1)
+	}
+	if tickets := f.activeTickets(t, db, f.seatIDs[0]); tickets != 1 {
+		t.Errorf("the seat has %d active tickets, want 1", tickets)
+	}
+}
+
+func bookingRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/customer/:customerId/orders", CreateOrder)
+	router.POST("/customer/:customerId/holds", CreateSeatHold)
+	router.PUT("/customer/:customerId/holds/:holdToken", ExtendSeatHold)
+	return router
+}
+
+// customerRequest sends the body as JSON with the login cookie of the
+// customer, like AuthMiddleware would have let through. It can be called
+// from other goroutines than the test's.
+func customerRequest(t *testing.T, router *gin.Engine, method string, customerId int, path string, body interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, middleware.CustomClaims{UserId: uint(customerId), Role: "customer"}).SignedString([]byte(os.Getenv("JWT_KEY")))
+	if err != nil {
+		t.Error(err)
+	}
+	request := httptest.NewRequest(method, fmt.Sprintf("/customer/%d%s", customerId, path), bytes.NewReader(payload))
+	request.Header.Set("Content-Type", "application/json")
+	request.AddCookie(&http.Cookie{Name: "token", Value: token})
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// holdSeats holds the seats for the customer and returns the hold, which is
+// released when the test ends.
+func holdSeats(t *testing.T, client *redis.Client, router *gin.Engine, f *bookingFixture, customerId int, accessible bool, seatIds ...int) (*httptest.ResponseRecorder, models.SeatHold) {
+	response := customerRequest(t, router, http.MethodPost, customerId, "/holds", models.SeatHoldRequest{ScheduleID: f.scheduleID, SeatIDs: seatIds, Accessible: accessible})
+	var held models.SeatHoldResponse
+	if response.Code == http.StatusOK {
+		if err := json.Unmarshal(response.Body.Bytes(), &held); err != nil {
+			t.Error(err)
+		}
+		t.Cleanup(func() { tool.ReleaseHold(client, held.Hold) })
+	}
+	return response, held.Hold
+}
+
+func statusCounts(responses []*httptest.ResponseRecorder) map[int]int {
+	counts := map[int]int{}
+	for _, response := range responses {
+		counts[response.Code]++
+	}
+	return counts
+}
+
+func TestCreateOrderConcurrently(t *testing.T) {
+	db := testDB(t)
+	testRedis(t)
+	f := newBookingFixture(t, db)
+	router := bookingRouter()
+
+	const bookings = 10
+	customers := make([]int, bookings)
+	for i := range customers {
+		customers[i] = f.newCustomer(t, db)
+	}
+	responses := concurrently(bookings, func(i int) *httptest.ResponseRecorder {
+		return customerRequest(t, router, http.MethodPost, customers[i], "/orders", models.OrderRequest{ScheduleID: f.scheduleID, SeatIDs: []int{f.seatIDs[0]}})
+	})
+
+	counts := statusCounts(responses)
+	if counts[http.StatusOK] != 1 || counts[http.StatusConflict] != bookings-1 {
+		t.Errorf("orders got %v, want one 200 and %d 409", counts, bookings-1)
+	}
+	if tickets := f.activeTickets(t, db, f.seatIDs[0]); tickets != 1 {
+		t.Errorf("the seat has %d active tickets, want 1", tickets)
+	}
+}
+
+func TestSeatHoldKeepsSeatForHolder(t *testing.T) {
+	db := testDB(t)
+	client := testRedis(t)
+	f := newBookingFixture(t, db)
+	router := bookingRouter()
+
+	const holds = 10
+	customers := make([]int, holds)
+	for i := range customers {
+		customers[i] = f.newCustomer(t, db)
+	}
+	type result struct {
+		response *httptest.ResponseRecorder
+		hold     models.SeatHold
+	}
+	results := concurrently(holds, func(i int) result {
+		response, hold := holdSeats(t, client, router, f, customers[i], false, f.seatIDs[0])
+		return result{response, hold}
+	})
+
+	var holder int
+	var hold models.SeatHold
+	var responses []*httptest.ResponseRecorder
+	for i, result := range results {
+		responses = append(responses, result.response)
+		if result.response.Code == http.StatusOK {
+			holder, hold = customers[i], result.hold
+		}
+	}
+	if counts := statusCounts(responses); counts[http.StatusOK] != 1 || counts[http.StatusConflict] != holds-1 {
+		t.Fatalf("holds got %v, want one 200 and %d 409", counts, holds-1)
+	}
+
+	other := customers[0]
+	if other == holder {
+		other = customers[1]
+	}
+	order := models.OrderRequest{ScheduleID: f.scheduleID, SeatIDs: []int{f.seatIDs[0]}}
+	if response := customerRequest(t, router, http.MethodPost, other, "/orders", order); response.Code != http.StatusConflict {
+		t.Errorf("booking a seat held by someone else got %d, want 409", response.Code)
+	}
+	order.HoldToken = hold.Token
+	if response := customerRequest(t, router, http.MethodPost, holder, "/orders", order); response.Code != http.StatusOK {
+		t.Errorf("booking the held seat got %d %s, want 200", response.Code, response.Body)
+	}
+	if tickets := f.activeTickets(t, db, f.seatIDs[0]); tickets != 1 {
+		t.Errorf("the seat has %d active tickets, want 1", tickets)
+	}
+}
+
+func TestCheckAccessibleSeats(t *testing.T) {
+	t.Setenv("WHEELCHAIR_RELEASE_HOURS", "2")
+	now := time.Now()
+	wheelchair := models.Seat{ID: 1, Row: "A", Position: 1, Type: models.Wheelchair}
+	companion := models.Seat{ID: 2, Row: "A", Position: 2, Type: models.Companion}
+	farCompanion := models.Seat{ID: 3, Row: "A", Position: 3, Type: models.Companion}
+	otherRow := models.Seat{ID: 4, Row: "B", Position: 2, Type: models.Companion}
+	regular := models.Seat{ID: 5, Row: "A", Position: 4, Type: models.Regular}
+
+	tests := []struct {
+		name       string
+		seats      []models.Seat
+		accessible bool
+		held       map[int]models.Money
+		showTime   time.Time
+		want       error
+	}{
+		{"regular seat", []models.Seat{regular}, false, nil, now.Add(24 * time.Hour), nil},
+		{"wheelchair seat for who needs it", []models.Seat{wheelchair}, true, nil, now.Add(24 * time.Hour), nil},
+		{"wheelchair seat for anyone before the cut-off", []models.Seat{wheelchair}, false, nil, now.Add(24 * time.Hour), errWheelchairReserved},
+		{"wheelchair seat for anyone after the cut-off", []models.Seat{wheelchair}, false, nil, now.Add(time.Hour), nil},
+		{"wheelchair seat already held", []models.Seat{wheelchair}, false, map[int]models.Money{1: 0}, now.Add(24 * time.Hour), nil},
+		{"companion with its wheelchair seat", []models.Seat{wheelchair, companion}, true, nil, now.Add(24 * time.Hour), nil},
+		{"companion alone", []models.Seat{companion}, true, nil, now.Add(24 * time.Hour), errCompanionAlone},
+		{"companion away from the wheelchair seat", []models.Seat{wheelchair, farCompanion}, true, nil, now.Add(24 * time.Hour), errCompanionAlone},
+		{"companion in another row", []models.Seat{wheelchair, otherRow}, true, nil, now.Add(24 * time.Hour), errCompanionAlone},
+		{"companion alone after the cut-off", []models.Seat{companion}, false, nil, now.Add(time.Hour), errCompanionAlone},
+	}
+	for _, test := range tests {
+		if err := checkAccessibleSeats(test.seats, test.accessible, test.held, test.showTime, now); err != test.want {
+			t.Errorf("%s: checkAccessibleSeats = %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestSeatHoldWheelchairSeats(t *testing.T) {
+	db := testDB(t)
+	client := testRedis(t)
+	t.Setenv("WHEELCHAIR_RELEASE_HOURS", "2")
+	f := newBookingFixture(t, db, models.Wheelchair, models.Companion)
+	router := bookingRouter()
+	customerId := f.newCustomer(t, db)
+	wheelchair, companion := f.seatIDs[0], f.seatIDs[1]
+
+	if response := customerRequest(t, router, http.MethodPost, customerId, "/holds", models.SeatHoldRequest{ScheduleID: f.scheduleID, SeatIDs: []int{companion}, Accessible: true}); response.Code != http.StatusBadRequest {
+		t.Errorf("holding the companion seat alone got %d, want 400", response.Code)
+	}
+	if response := customerRequest(t, router, http.MethodPost, customerId, "/holds", models.SeatHoldRequest{ScheduleID: f.scheduleID, SeatIDs: []int{wheelchair, companion}}); response.Code != http.StatusConflict {
+		t.Errorf("holding the wheelchair seat without needing it got %d, want 409", response.Code)
+	}
+	response, hold := holdSeats(t, client, router, f, customerId, true, wheelchair, companion)
+	if response.Code != http.StatusOK {
+		t.Fatalf("holding the wheelchair seat with its companion got %d %s, want 200", response.Code, response.Body)
+	}
+	order := models.OrderRequest{ScheduleID: f.scheduleID, SeatIDs: []int{wheelchair, companion}, HoldToken: hold.Token}
+	if response := customerRequest(t, router, http.MethodPost, customerId, "/orders", order); response.Code != http.StatusOK {
+		t.Errorf("booking the held seats got %d %s, want 200", response.Code, response.Body)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"tix-id/config"
 	"tix-id/models"
 
@@ -34,7 +35,8 @@ func CreateBranch(c *gin.Context) {
 	defer db.Close()
 
 	// Insert the movie into the database
-	result, err := db.Exec("INSERT INTO branch (name, address) VALUES (?, ?)", branch.Name, branch.Address)
+	// a branch without a jurisdiction is taxed in the default one
+	result, err := db.Exec("INSERT INTO branch (name, address, city, tax_jurisdiction_id) VALUES (?, ?, nullif(?, ''), coalesce(?, default(tax_jurisdiction_id)))", branch.Name, branch.Address, strings.TrimSpace(branch.City), branch.TaxJurisdictionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
@@ -57,6 +59,7 @@ func CreateBranch(c *gin.Context) {
 		},
 		Branch: branch,
 	}
+	invalidateCatalog()
 	c.JSON(http.StatusOK, responseData)
 }
 
@@ -75,7 +78,7 @@ func GetBranches(c *gin.Context) {
 	defer db.Close()
 
 	// Execute a SELECT query to retrieve all branches from the database
-	rows, err := db.Query("SELECT * FROM branch")
+	rows, err := db.Query("SELECT id, name, address, coalesce(city, ''), tax_jurisdiction_id FROM branch")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve branches from database"})
 		return
@@ -85,14 +88,14 @@ func GetBranches(c *gin.Context) {
 	var branches []models.Branch
 	for rows.Next() {
 		var branch models.Branch
-		err := rows.Scan(&branch.ID, &branch.Name, &branch.Address)
+		err := rows.Scan(&branch.ID, &branch.Name, &branch.Address, &branch.City, &branch.TaxJurisdictionID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve branches from database"})
 			return
 		}
 
 		// Execute a SELECT query to retrieve all theatres for the current branch
-		theatreRows, err := db.Query("SELECT id, name FROM theatre WHERE branch_id = ?", branch.ID)
+		theatreRows, err := db.Query("SELECT id, name, formats FROM theatre WHERE branch_id = ?", branch.ID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error1": err})
 			return
@@ -104,7 +107,9 @@ func GetBranches(c *gin.Context) {
 		// Iterate over the theatre rows returned from the query and store them in the theatres slice
 		for theatreRows.Next() {
 			var theatre models.Theatre
-			err := theatreRows.Scan(&theatre.ID, &theatre.Name)
+			var formats string
+			err := theatreRows.Scan(&theatre.ID, &theatre.Name, &formats)
+			theatre.Formats = parseFormatSet(formats)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error2": fmt.Sprintf("%v", err)})
 				return
@@ -149,7 +154,7 @@ func GetBranch(c *gin.Context) {
 	}
 
 	var branch models.Branch
-	err = db.QueryRow("Select id,name,address from branch where id =?", branchId).Scan(&branch.ID, &branch.Name, &branch.Address)
+	err = db.QueryRow("Select id,name,address,coalesce(city, ''),tax_jurisdiction_id from branch where id =?", branchId).Scan(&branch.ID, &branch.Name, &branch.Address, &branch.City, &branch.TaxJurisdictionID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			response := models.Response{
@@ -166,7 +171,7 @@ func GetBranch(c *gin.Context) {
 	//get theatre
 
 	var theatres []models.Theatre
-	theatreId, err := db.Query("SELECT id, name FROM theatre WHERE branch_id = ?", branch.ID)
+	theatreId, err := db.Query("SELECT id, name, formats FROM theatre WHERE branch_id = ?", branch.ID)
 	if err != nil {
 		log.Println(err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -175,7 +180,9 @@ func GetBranch(c *gin.Context) {
 
 	for theatreId.Next() {
 		var theatre models.Theatre
-		err := theatreId.Scan(&theatre.ID, &theatre.Name)
+		var formats string
+		err := theatreId.Scan(&theatre.ID, &theatre.Name, &formats)
+		theatre.Formats = parseFormatSet(formats)
 		if err != nil {
 			log.Println(err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -251,6 +258,7 @@ func DeleteBranch(c *gin.Context) {
 		Status:  200,
 		Message: message,
 	}
+	invalidateCatalog()
 	c.JSON(http.StatusOK, responseData)
 }
 
@@ -285,7 +293,7 @@ func UpdateBranch(c *gin.Context) {
 	//id branch
 	branch.ID = branchId
 
-	result, err := db.Exec("UPDATE branch SET name=?, address=? WHERE id=?", branch.Name, branch.Address, branch.ID)
+	result, err := db.Exec("UPDATE branch SET name=?, address=?, city=nullif(?, ''), tax_jurisdiction_id=coalesce(?, tax_jurisdiction_id) WHERE id=?", branch.Name, branch.Address, strings.TrimSpace(branch.City), branch.TaxJurisdictionID, branch.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -309,5 +317,6 @@ func UpdateBranch(c *gin.Context) {
 		},
 		Branch: branch,
 	}
+	invalidateCatalog()
 	c.JSON(http.StatusOK, responseData)
 }
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	// catalogVersionKey is bumped whenever a movie, a schedule or a branch
+	// changes. It is part of every cached list key, so the lists cached
+	// before the change are never read again and just expire.
+	catalogVersionKey = "catalog:version"
+	// catalogCacheTTL also bounds how long a list shows a showtime that has
+	// started, as time passing changes the lists without any write.
+	catalogCacheTTL = 5 * time.Minute
+)
+
+type catalogList string
+
+const (
+	nowShowing catalogList = "now-showing"
+	comingSoon catalogList = "coming-soon"
+	presale    catalogList = "presale"
+)
+
+// catalogFilter picks the branch or the city whose showtimes count, and the
+// page of the list.
+type catalogFilter struct {
+	BranchID int
+	City     string
+	Paging   models.Paging
+}
+
+// runStart is the first day a movie is shown.
+const runStart = "coalesce(m.run_start_date, date(m.release_date))"
+
+// catalogQuery makes the condition and the order of a list. A movie is now
+// showing from the first day of its run until the last while it has
+// showtimes left, and coming soon before its run starts. Presale movies are
+// the coming soon ones whose showtimes can already be booked.
+func catalogQuery(list catalogList, filter catalogFilter, now time.Time) (string, []interface{}, string) {
+	today := now.In(showLocation).Format("2006-01-02")
+	upcoming := "exists (select 1 from schedule sc join theatre t on t.id = sc.theatre_id join branch b on b.id = t.branch_id where sc.movie_id = m.id and sc.show_time > ?"
+	upcomingArgs := []interface{}{now}
+	if filter.BranchID != 0 {
+		upcoming += " and b.id = ?"
+		upcomingArgs = append(upcomingArgs, filter.BranchID)
+	}
+	if filter.City != "" {
+		upcoming += " and b.city = ?"
+		upcomingArgs = append(upcomingArgs, filter.City)
+	}
+	upcoming += ")"
+
+	switch list {
+	case nowShowing:
+		return runStart + " <= ? and (m.run_end_date is null or m.run_end_date >= ?) and " + upcoming,
+			append([]interface{}{today, today}, upcomingArgs...), "m.release_date desc, m.id"
+	case presale:
+		return runStart + " > ? and " + upcoming, append([]interface{}{today}, upcomingArgs...), runStart + ", m.id"
+	}
+	return runStart + " > ?", []interface{}{today}, runStart + ", m.id"
+}
+
+// catalogMovies reads a page of a list and the number of movies on it.
+func catalogMovies(q queryer, list catalogList, filter catalogFilter, now time.Time) ([]models.Movie, int, error) {
+	where, args, order := catalogQuery(list, filter, now)
+	var total int
+	if err := q.QueryRow("select count(*) from movie m where "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := q.Query("select "+movieColumns+" from movie m where "+where+" order by "+order+" limit ? offset ?",
+		append(args, filter.Paging.Limit, filter.Paging.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	movies := []models.Movie{}
+	for rows.Next() {
+		movie, err := scanMovie(rows)
+		if err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		movies = append(movies, movie)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return movies, total, loadMovieDetails(q, movies)
+}
+
+// catalogCacheKey names a cached page of a list, for the current version of
+// the catalog and the current day.
+func catalogCacheKey(client *redis.Client, list catalogList, filter catalogFilter, now time.Time) string {
+	version, err := tool.GetRedisValue(client, catalogVersionKey)
+	if err != nil {
+		version = "0"
+	}
+	return strings.Join([]string{"catalog", version, string(list), now.In(showLocation).Format("2006-01-02"),
+		strconv.Itoa(filter.BranchID), strings.ToLower(filter.City), strconv.Itoa(filter.Paging.Limit), strconv.Itoa(filter.Paging.Offset)}, ":")
+}
+
+type cachedCatalogPage struct {
+	Movies []models.Movie `json:"movies"`
+	Total  int            `json:"total"`
+}
+
+// cachedCatalogMovies reads a page of a list from the cache, or from the
+// database when it isn't cached. The lists are still served when Redis is
+// down, just not cached.
+func cachedCatalogMovies(q queryer, list catalogList, filter catalogFilter) ([]models.Movie, int, error) {
+	now := time.Now()
+	client := tool.NewRedisClient()
+	defer client.Close()
+
+	key := catalogCacheKey(client, list, filter, now)
+	if cached, err := tool.GetRedisValue(client, key); err == nil {
+		var page cachedCatalogPage
+		if err := json.Unmarshal([]byte(cached), &page); err == nil {
+			return page.Movies, page.Total, nil
+		}
+	}
+
+	movies, total, err := catalogMovies(q, list, filter, now)
+	if err != nil {
+		return nil, 0, err
+	}
+	if page, err := json.Marshal(cachedCatalogPage{movies, total}); err == nil {
+		if err := tool.SetRedisValue(client, key, string(page), catalogCacheTTL); err != nil {
+			log.Println(err)
+		}
+	}
+	return movies, total, nil
+}
+
+// invalidateCatalog drops the cached lists after a movie, a schedule or a
+// branch changed.
+func invalidateCatalog() {
+	client := tool.NewRedisClient()
+	defer client.Close()
+	if err := tool.IncrRedisValue(client, catalogVersionKey); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"tix-id/config"
+	"tix-id/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getCatalog(c *gin.Context, list catalogList) {
+	filter := catalogFilter{Paging: models.Paging{Limit: 20}}
+	// the movies coming soon are the same everywhere
+	if list != comingSoon {
+		filter.City = strings.TrimSpace(c.Query("city"))
+	}
+	if branchId := c.Query("branchId"); branchId != "" && list != comingSoon {
+		id, err := strconv.Atoi(branchId)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+			return
+		}
+		filter.BranchID = id
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= 100 {
+		filter.Paging.Limit = limit
+	}
+	if offset, err := strconv.Atoi(c.Query("offset")); err == nil && offset > 0 {
+		filter.Paging.Offset = offset
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	movies, total, err := cachedCatalogMovies(db, list, filter)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve movies from database"})
+		return
+	}
+	filter.Paging.Total = total
+
+	responseData := models.MoviePageResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Movies retrieved successfully",
+		},
+		Movies: movies,
+		Paging: filter.Paging,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// GetNowShowing godoc
+// @Summary Get Movies Now Showing
+// @Description Get the movies in their run that have showtimes left, at the branch or in the city when one is given, newest releases first.
+// @Tags Guest
+// @Produce json
+// @Param branchId query int false "Only showtimes at this branch"
+// @Param city query string false "Only showtimes in this city"
+// @Param limit query int false "Movies per page, 20 by default"
+// @Param offset query int false "Movies to skip"
+// @Success 200 {object} models.MoviePageResponse
+// @Router /movies/now-showing [get]
+func GetNowShowing(c *gin.Context) {
+	getCatalog(c, nowShowing)
+}
+
+// GetComingSoon godoc
+// @Summary Get Movies Coming Soon
+// @Description Get the movies whose run hasn't started, soonest first.
+// @Tags Guest
+// @Produce json
+// @Param limit query int false "Movies per page, 20 by default"
+// @Param offset query int false "Movies to skip"
+// @Success 200 {object} models.MoviePageResponse
+// @Router /movies/coming-soon [get]
+func GetComingSoon(c *gin.Context) {
+	getCatalog(c, comingSoon)
+}
+
+// GetPresale godoc
+// @Summary Get Movies On Presale
+// @Description Get the movies whose run hasn't started but whose showtimes can already be booked, at the branch or in the city when one is given, soonest first.
+// @Tags Guest
+// @Produce json
+// @Param branchId query int false "Only showtimes at this branch"
+// @Param city query string false "Only showtimes in this city"
+// @Param limit query int false "Movies per page, 20 by default"
+// @Param offset query int false "Movies to skip"
+// @Success 200 {object} models.MoviePageResponse
+// @Router /movies/presale [get]
+func GetPresale(c *gin.Context) {
+	getCatalog(c, presale)
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"tix-id/models"
+)
+
+var (
+	errTheatreFormat = errors.New("the theatre can't project the movie in this format")
+	errMovieFormat   = errors.New("the movie isn't released in this format")
+)
+
+// validFormats normalizes a list of formats, e.g. of a theatre, and checks
+// every one of them is known.
+func validFormats(formats []models.Format) error {
+	for i, format := range formats {
+		parsed, ok := models.ParseFormat(string(format))
+		if !ok || strings.TrimSpace(string(format)) == "" {
+			return fmt.Errorf("unknown format %q", format)
+		}
+		formats[i] = parsed
+	}
+	return nil
+}
+
+// formatSet writes formats the way a SET column takes them.
+func formatSet(formats []models.Format) string {
+	values := make([]string, len(formats))
+	for i, format := range formats {
+		values[i] = string(format)
+	}
+	return strings.Join(values, ",")
+}
+
+// parseFormatSet reads the formats of a SET column.
+func parseFormatSet(set string) []models.Format {
+	var formats []models.Format
+	for _, format := range strings.Split(set, ",") {
+		if format != "" {
+			formats = append(formats, models.Format(format))
+		}
+	}
+	return formats
+}
+
+// checkScheduleFormat verifies the theatre can project the format and the
+// movie is released in it.
+func checkScheduleFormat(q queryer, movieId, theatreId int, format models.Format) error {
+	var theatreCan, movieHas bool
+	err := q.QueryRow("select find_in_set(?, t.formats) > 0, find_in_set(?, m.formats) > 0 from theatre t join movie m on m.id = ? where t.id = ?", format, format, movieId, theatreId).Scan(&theatreCan, &movieHas)
+	if err != nil {
+		return err
+	}
+	if !theatreCan {
+		return errTheatreFormat
+	}
+	if !movieHas {
+		return errMovieFormat
+	}
+	return nil
+}
+
+// formatError tells if a schedule was refused for its format.
+func formatError(err error) bool {
+	return err == errTheatreFormat || err == errMovieFormat
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+	"tix-id/models"
+	"tix-id/tool"
+)
+
+var (
+	errGiftCardNotFound = errors.New("the gift card is not found or not active")
+	errGiftCardEmpty    = errors.New("the gift card has no balance left")
+)
+
+const giftCardColumns = "g.id, g.code, g.amount, g.amount + coalesce((select sum(r.amount) from gift_card_redemption r where r.gift_card_id = g.id), 0), g.currency, g.status, g.recipient_name, g.recipient_email, g.message, g.batch, g.expires_at, g.created_at"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanGiftCard(row scanner) (models.GiftCard, error) {
+	var card models.GiftCard
+	var recipientName, recipientEmail, message, batch sql.NullString
+	var expiresAt sql.NullTime
+	err := row.Scan(&card.ID, &card.Code, &card.Amount, &card.Balance, &card.Currency, &card.Status, &recipientName, &recipientEmail, &message, &batch, &expiresAt, &card.CreatedAt)
+	if err != nil {
+		return card, err
+	}
+	card.RecipientName = recipientName.String
+	card.RecipientEmail = recipientEmail.String
+	card.Message = message.String
+	card.Batch = batch.String
+	if expiresAt.Valid {
+		card.ExpiresAt = &expiresAt.Time
+	}
+	return card, nil
+}
+
+// redeemGiftCard takes up to the amount from the gift card for the payment
+// and returns how much it took. The card only pays in its own currency. The
+// card row is locked until the transaction ends, so two bookings can't spend
+// the same balance.
+func redeemGiftCard(tx *sql.Tx, code string, paymentId int, amount models.Money, currency models.Currency, now time.Time) (models.Money, error) {
+	card, err := scanGiftCard(tx.QueryRow("select "+giftCardColumns+" from gift_card g where g.code = ? for update", tool.NormalizeGiftCardCode(code)))
+	if err == sql.ErrNoRows {
+		return 0, errGiftCardNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	if card.Status != models.GiftCardActive || (card.ExpiresAt != nil && !now.Before(*card.ExpiresAt)) {
+		return 0, errGiftCardNotFound
+	}
+	if card.Balance <= 0 {
+		return 0, errGiftCardEmpty
+	}
+	if card.Currency != currency {
+		return 0, errCurrencyMismatch
+	}
+
+	used := card.Balance.Min(amount)
+	if used <= 0 {
+		return 0, nil
+	}
+	if _, err := tx.Exec("insert into gift_card_redemption (gift_card_id, payment_id, amount) values (?, ?, ?)", card.ID, paymentId, -used); err != nil {
+		return 0, err
+	}
+	return used, nil
+}
+
+// maskGiftCardCode hides all but the last group of a code, for everyone but
+// the recipient and admins.
+func maskGiftCardCode(code string) string {
+	if len(code) <= 4 {
+		return code
+	}
+	return "XXXX-XXXX-XXXX-" + code[len(code)-4:]
+}
+
+// sendGiftCard emails a bought gift card to its recipient, once it is paid.
+func sendGiftCard(db *sql.DB, paymentId int) error {
+	card, err := scanGiftCard(db.QueryRow("select "+giftCardColumns+" from gift_card g where g.payment_id = ? and g.status = 'active'", paymentId))
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var sender models.Customer
+	if err := db.QueryRow("select c.name, c.email from customer c join gift_card g on g.purchaser_id = c.id where g.id = ?", card.ID).Scan(&sender.Name, &sender.Email); err != nil {
+		return err
+	}
+	content := tool.GenerateGiftCardEmail(sender, card)
+	go tool.SendEmail(content, card.RecipientEmail, "[TIX-ID] You received a gift card")
+	return nil
+}
@@ -0,0 +1,376 @@
+package controller
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"tix-id/config"
+	"tix-id/middleware"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+)
+
+const maxGiftCardBatch = 1000
+
+// insertGiftCard stores the card under a fresh code, drawing again in the
+// unlikely case the code is already taken.
+func insertGiftCard(q queryer, card *models.GiftCard, purchaserId, paymentId interface{}) error {
+	for attempt := 0; ; attempt++ {
+		code, err := tool.NewGiftCardCode()
+		if err != nil {
+			return err
+		}
+		res, err := q.Exec("insert into gift_card (code, amount, currency, status, recipient_name, recipient_email, message, batch, expires_at, purchaser_id, payment_id) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", code, card.Amount, card.Currency, card.Status, card.RecipientName, card.RecipientEmail, card.Message, card.Batch, card.ExpiresAt, purchaserId, paymentId)
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		card.ID = int(id)
+		card.Code = code
+		card.Balance = card.Amount
+		return nil
+	}
+}
+
+// PurchaseGiftCard godoc
+// @Summary Buy Gift Card
+// @Description Buy a gift card through the payment provider. Its code is emailed to the recipient once the payment completes.
+// @Tags Customer
+// @Accept json
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param body body models.GiftCardPurchaseRequest true "Gift card"
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried"
+// @Success 200 {object} models.GiftCardPurchaseResponse
+// @Router /customer/{customerId}/giftcards [post]
+func PurchaseGiftCard(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	var request models.GiftCardPurchaseRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	card := models.GiftCard{
+		Amount:         request.Amount,
+		Currency:       request.Currency,
+		Status:         models.GiftCardPending,
+		RecipientName:  strings.TrimSpace(request.RecipientName),
+		RecipientEmail: strings.TrimSpace(request.RecipientEmail),
+		Message:        strings.TrimSpace(request.Message),
+	}
+	if card.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the gift card amount must be positive"})
+		return
+	}
+	if card.Currency == "" {
+		card.Currency = models.DefaultCurrency
+	}
+	if !card.Currency.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported currency " + string(card.Currency)})
+		return
+	}
+	if card.RecipientName == "" || !strings.Contains(card.RecipientEmail, "@") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the recipient needs a name and a valid email"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("insert into payment(amount, currency, payment_status) values (?, ?, 'pending')", card.Amount, card.Currency)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	paymentId, err := res.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := insertGiftCard(tx, &card, customerId, paymentId); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// only the recipient gets to see the whole code
+	card.Code = maskGiftCardCode(card.Code)
+	purchase := models.GiftCardPurchase{GiftCard: card}
+	provider, err := tool.NewPaymentProvider()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	purchase.Payment, err = chargePayment(db, provider, int(paymentId))
+	if err != nil {
+		switch err {
+		case errPaymentDeclined:
+			c.JSON(http.StatusPaymentRequired, models.Response{Status: 402, Message: err.Error()})
+		case errPaymentProcessing:
+			c.JSON(http.StatusAccepted, models.GiftCardPurchaseResponse{
+				Response: models.Response{Status: 202, Message: err.Error()},
+				Purchase: purchase,
+			})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	purchase.GiftCard.Status = models.GiftCardActive
+
+	responseData := models.GiftCardPurchaseResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Gift card sent to " + card.RecipientEmail,
+		},
+		Purchase: purchase,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// IssueGiftCards godoc
+// @Summary Issue Gift Cards
+// @Description Issue a batch of active gift cards, e.g. for a corporate client
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.GiftCardBatchRequest true "Batch"
+// @Success 200 {object} models.GiftCardsResponse
+// @Router /giftcards/batches [post]
+func IssueGiftCards(c *gin.Context) {
+	var request models.GiftCardBatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	amount := request.Amount
+	if request.Count < 1 || request.Count > maxGiftCardBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the count must be between 1 and " + strconv.Itoa(maxGiftCardBatch)})
+		return
+	}
+	if amount <= 0 || strings.TrimSpace(request.Batch) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a batch needs a positive amount and a name"})
+		return
+	}
+	currency := request.Currency
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	if !currency.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported currency " + string(currency)})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	cards := []models.GiftCard{}
+	for i := 0; i < request.Count; i++ {
+		card := models.GiftCard{
+			Amount:    amount,
+			Currency:  currency,
+			Status:    models.GiftCardActive,
+			Batch:     strings.TrimSpace(request.Batch),
+			ExpiresAt: request.ExpiresAt,
+		}
+		if err := insertGiftCard(tx, &card, nil, nil); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		cards = append(cards, card)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.GiftCardsResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: strconv.Itoa(len(cards)) + " gift cards issued successfully",
+		},
+		GiftCards: cards,
+		Paging:    models.Paging{Limit: len(cards), Total: len(cards)},
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// GetGiftCards godoc
+// @Summary Get Gift Cards
+// @Description Get gift cards with their balance, newest first
+// @Tags Admin
+// @Produce json
+// @Param batch query string false "Only cards of this batch"
+// @Param code query string false "Only the card with this code"
+// @Param status query string false "pending, active or inactive"
+// @Param limit query int false "Cards per page, 20 by default"
+// @Param offset query int false "Cards to skip"
+// @Success 200 {object} models.GiftCardsResponse
+// @Router /giftcards [get]
+func GetGiftCards(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	paging := models.Paging{Limit: 20}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= 100 {
+		paging.Limit = limit
+	}
+	if offset, err := strconv.Atoi(c.Query("offset")); err == nil && offset > 0 {
+		paging.Offset = offset
+	}
+
+	where := " where 1 = 1"
+	var args []interface{}
+	if batch := c.Query("batch"); batch != "" {
+		where += " and g.batch = ?"
+		args = append(args, batch)
+	}
+	if code := c.Query("code"); code != "" {
+		where += " and g.code = ?"
+		args = append(args, tool.NormalizeGiftCardCode(code))
+	}
+	if status := c.Query("status"); status != "" {
+		where += " and g.status = ?"
+		args = append(args, status)
+	}
+
+	if err := db.QueryRow("select count(*) from gift_card g"+where, args...).Scan(&paging.Total); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rows, err := db.Query("select "+giftCardColumns+" from gift_card g"+where+" order by g.id desc limit ? offset ?", append(args, paging.Limit, paging.Offset)...)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	cards := []models.GiftCard{}
+	for rows.Next() {
+		card, err := scanGiftCard(rows)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		cards = append(cards, card)
+	}
+
+	responseData := models.GiftCardsResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Gift cards retrieved successfully",
+		},
+		GiftCards: cards,
+		Paging:    paging,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// GetGiftCard godoc
+// @Summary Get Gift Card
+// @Description Get a gift card with its balance
+// @Tags Admin
+// @Produce json
+// @Param giftCardId path int true "Gift card ID"
+// @Success 200 {object} models.GiftCardResponse
+// @Router /giftcards/{giftCardId} [get]
+func GetGiftCard(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	card, err := scanGiftCard(db.QueryRow("select "+giftCardColumns+" from gift_card g where g.id = ?", c.Param("giftCardId")))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the gift card is not found!"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.GiftCardResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Gift card retrieved successfully",
+		},
+		GiftCard: card,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// DeactivateGiftCard godoc
+// @Summary Deactivate Gift Card
+// @Description Deactivate a compromised gift card, so its balance can no longer be used
+// @Tags Admin
+// @Produce json
+// @Param giftCardId path int true "Gift card ID"
+// @Success 200 {object} models.Response
+// @Router /giftcards/{giftCardId} [delete]
+func DeactivateGiftCard(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	res, err := db.Exec("update gift_card set status = 'inactive' where id = ? and status <> 'inactive'", c.Param("giftCardId"))
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the gift card is not found or already inactive"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: 200, Message: "Gift card deactivated successfully"})
+}
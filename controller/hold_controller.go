@@ -0,0 +1,263 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"tix-id/config"
+	"tix-id/middleware"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/gin-gonic/gin"
+)
+
+// heldSeatPrices prices the seats as they are being held.
+func heldSeatPrices(q queryer, scheduleId int, seatIds []int) (map[int]models.Money, error) {
+	pricing, err := loadSchedulePricing(q, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	args := []interface{}{scheduleId}
+	for _, id := range seatIds {
+		args = append(args, id)
+	}
+	rows, err := q.Query("select id, seat_type from seat where schedule_id = ? and id in ("+placeholders(len(seatIds))+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	prices := map[int]models.Money{}
+	for rows.Next() {
+		var seatId int
+		var seatType models.SeatType
+		if err := rows.Scan(&seatId, &seatType); err != nil {
+			return nil, err
+		}
+		prices[seatId] = pricing.seatPrice(seatType)
+	}
+	return prices, rows.Err()
+}
+
+// CreateSeatHold godoc
+// @Summary Hold Seats
+// @Description Temporarily reserve seats of a schedule before booking them
+// @Tags Customer
+// @Accept json
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param body body models.SeatHoldRequest true "Seats to hold"
+// @Success 200 {object} models.SeatHoldResponse
+// @Router /customer/{customerId}/holds [post]
+func CreateSeatHold(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	var request models.SeatHoldRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seatIds := uniqueSeatIds(request.SeatIDs)
+	if len(seatIds) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no seats to hold"})
+		return
+	}
+
+	// verify every seat belongs to the schedule and is not booked yet
+	for _, seatId := range seatIds {
+		var seatCount, ticketCount int
+		err := db.QueryRow("select count(*) from seat where id = ? and schedule_id = ? and blocked = 0", seatId, request.ScheduleID).Scan(&seatCount)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if seatCount == 0 {
+			response := models.Response{
+				Status:  404,
+				Message: "the seat is not match with the schedule",
+			}
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+
+		err = db.QueryRow("select count(*) from ticket where seat_id = ? and schedule_id = ? and active = 1", seatId, request.ScheduleID).Scan(&ticketCount)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if ticketCount > 0 {
+			c.JSON(http.StatusConflict, models.Response{
+				Status:  409,
+				Message: "the seat is taken",
+			})
+			return
+		}
+	}
+
+	var showTime time.Time
+	if err := db.QueryRow("select show_time from schedule where id = ?", request.ScheduleID).Scan(&showTime); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	seats, err := scheduleSeats(db, request.ScheduleID, seatIds)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkAccessibleSeats(seats, request.Accessible, nil, showTime, time.Now()); err != nil {
+		switch err {
+		case errWheelchairReserved:
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, models.Response{Status: 400, Message: err.Error()})
+		}
+		return
+	}
+
+	// the price is locked in while the seats are held, even if the schedule fills up meanwhile
+	prices, err := heldSeatPrices(db, request.ScheduleID, seatIds)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	redisClient := tool.NewRedisClient()
+	defer redisClient.Close()
+
+	hold, err := tool.HoldSeats(redisClient, int(customerId), request.ScheduleID, seatIds, prices)
+	if err != nil {
+		if err == tool.ErrSeatHeld {
+			c.JSON(http.StatusConflict, models.Response{
+				Status:  409,
+				Message: err.Error(),
+			})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.SeatHoldResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Seats held successfully until " + hold.ExpiresAt.Format(time.RFC3339),
+		},
+		Hold: hold,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// ExtendSeatHold godoc
+// @Summary Extend Seat Hold
+// @Description Extend a seat hold by another hold period, only once per hold
+// @Tags Customer
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param holdToken path string true "Hold token"
+// @Success 200 {object} models.SeatHoldResponse
+// @Router /customer/{customerId}/holds/{holdToken} [put]
+func ExtendSeatHold(c *gin.Context) {
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	redisClient := tool.NewRedisClient()
+	defer redisClient.Close()
+
+	hold, err := tool.ExtendHold(redisClient, int(customerId), c.Param("holdToken"))
+	if err != nil {
+		switch err {
+		case tool.ErrHoldNotFound, tool.ErrHoldNotOwned:
+			c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: tool.ErrHoldNotFound.Error()})
+		case tool.ErrHoldExtended, tool.ErrHoldSeatsLost:
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: err.Error()})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	responseData := models.SeatHoldResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Seat hold extended until " + hold.ExpiresAt.Format(time.RFC3339),
+		},
+		Hold: hold,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// ReleaseSeatHold godoc
+// @Summary Release Seat Hold
+// @Description Release the held seats immediately
+// @Tags Customer
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param holdToken path string true "Hold token"
+// @Success 200 {object} models.Response
+// @Router /customer/{customerId}/holds/{holdToken} [delete]
+func ReleaseSeatHold(c *gin.Context) {
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	redisClient := tool.NewRedisClient()
+	defer redisClient.Close()
+
+	hold, err := tool.GetHold(redisClient, c.Param("holdToken"))
+	if err != nil || hold.CustomerID != int(customerId) {
+		if err != nil && err != tool.ErrHoldNotFound {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: tool.ErrHoldNotFound.Error()})
+		return
+	}
+
+	if err := tool.ReleaseHold(redisClient, hold); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Status:  200,
+		Message: "Seat hold released successfully",
+	})
+}
@@ -0,0 +1,471 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"tix-id/models"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// maxImportRows caps the rows of an import, as they are all written in one
+// transaction.
+const maxImportRows = 5000
+
+var (
+	errImportFile   = errors.New("invalid import file")
+	errImportFormat = errors.New("the import format must be csv or json")
+	errImportSize   = fmt.Errorf("an import holds at most %d rows", maxImportRows)
+)
+
+// csvRecord is a CSV row by column name, with its line number.
+type csvRecord struct {
+	line   int
+	fields map[string]string
+}
+
+// readCSV reads a CSV file with a header row. Column names are matched
+// without case, and price columns of seat types are written price_<type>.
+func readCSV(r io.Reader, columns []string) ([]csvRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the header row: %w", err)
+	}
+	known := map[string]bool{}
+	for _, column := range columns {
+		known[strings.ToLower(column)] = true
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !known[header[i]] && !strings.HasPrefix(header[i], "price_") {
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+	}
+
+	var records []csvRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == maxImportRows {
+			return nil, errImportSize
+		}
+		line, _ := reader.FieldPos(0)
+		record := csvRecord{line: line, fields: map[string]string{}}
+		for i, value := range row {
+			record.fields[header[i]] = strings.TrimSpace(value)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// csvInt reads an optional integer column.
+func csvInt(record csvRecord, column string) (int, error) {
+	value := record.fields[column]
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a whole number", column)
+	}
+	return n, nil
+}
+
+// movieImport is a parsed row of a movie import, or why it couldn't be parsed.
+type movieImport struct {
+	row   int
+	movie models.Movie
+	err   error
+}
+
+func parseMovieImport(format string, r io.Reader) ([]movieImport, error) {
+	var rows []movieImport
+	switch format {
+	case "json":
+		var movies []models.Movie
+		if err := json.NewDecoder(r).Decode(&movies); err != nil {
+			return nil, err
+		}
+		if len(movies) > maxImportRows {
+			return nil, errImportSize
+		}
+		for i, movie := range movies {
+			rows = append(rows, movieImport{row: i + 1, movie: movie})
+		}
+	case "csv":
+		records, err := readCSV(r, []string{"id", "title", "description", "duration", "rating", "releaseDate", "runStartDate", "runEndDate", "classification", "language", "subtitles", "genres", "formats"})
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			row := movieImport{row: record.line}
+			row.movie.Title = record.fields["title"]
+			row.movie.Description = record.fields["description"]
+			row.movie.ReleaseDate = record.fields["releasedate"]
+			if runStartDate := record.fields["runstartdate"]; runStartDate != "" {
+				row.movie.RunStartDate = &runStartDate
+			}
+			if runEndDate := record.fields["runenddate"]; runEndDate != "" {
+				row.movie.RunEndDate = &runEndDate
+			}
+			row.movie.Classification = models.Classification(record.fields["classification"])
+			row.movie.Language = record.fields["language"]
+			// a cell can hold several subtitles, genres or formats, separated by |
+			if subtitles := record.fields["subtitles"]; subtitles != "" {
+				row.movie.Subtitles = strings.Split(subtitles, "|")
+			}
+			if genres := record.fields["genres"]; genres != "" {
+				row.movie.Genres = strings.Split(genres, "|")
+			}
+			for _, format := range strings.Split(record.fields["formats"], "|") {
+				if format != "" {
+					row.movie.Formats = append(row.movie.Formats, models.Format(format))
+				}
+			}
+			if row.movie.ID, err = csvInt(record, "id"); err == nil {
+				row.movie.Duration, err = csvInt(record, "duration")
+			}
+			if rating := record.fields["rating"]; err == nil && rating != "" {
+				var parsed float64
+				if parsed, err = strconv.ParseFloat(rating, 32); err != nil {
+					err = errors.New("rating must be a number")
+				}
+				row.movie.Rating = float32(parsed)
+			}
+			row.err = err
+			rows = append(rows, row)
+		}
+	default:
+		return nil, errImportFormat
+	}
+	return rows, nil
+}
+
+// scheduleImport is a parsed row of a schedule import, or why it couldn't be
+// parsed.
+type scheduleImport struct {
+	row      int
+	schedule models.ScheduleImport
+	err      error
+}
+
+func parseScheduleImport(format string, r io.Reader) ([]scheduleImport, error) {
+	var rows []scheduleImport
+	switch format {
+	case "json":
+		var schedules []models.ScheduleImport
+		if err := json.NewDecoder(r).Decode(&schedules); err != nil {
+			return nil, err
+		}
+		if len(schedules) > maxImportRows {
+			return nil, errImportSize
+		}
+		for i, schedule := range schedules {
+			rows = append(rows, scheduleImport{row: i + 1, schedule: schedule})
+		}
+	case "csv":
+		records, err := readCSV(r, []string{"id", "movieId", "movieTitle", "theatreId", "showtime", "price", "format"})
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			row := scheduleImport{row: record.line}
+			row.schedule.MovieTitle = record.fields["movietitle"]
+			row.schedule.Showtime = record.fields["showtime"]
+			row.schedule.Format = record.fields["format"]
+			if row.schedule.ID, err = csvInt(record, "id"); err == nil {
+				if row.schedule.MovieID, err = csvInt(record, "movieid"); err == nil {
+					row.schedule.TheatreID, err = csvInt(record, "theatreid")
+				}
+			}
+			if err == nil {
+				if row.schedule.Price, err = models.ParseMoney(record.fields["price"]); err != nil {
+					err = errors.New("price must be an amount of money")
+				}
+			}
+			for column, value := range record.fields {
+				if err != nil || !strings.HasPrefix(column, "price_") || value == "" {
+					continue
+				}
+				if row.schedule.Prices == nil {
+					row.schedule.Prices = map[models.SeatType]models.Money{}
+				}
+				seatType := models.SeatType(strings.TrimPrefix(column, "price_"))
+				if row.schedule.Prices[seatType], err = models.ParseMoney(value); err != nil {
+					err = fmt.Errorf("%s must be an amount of money", column)
+				}
+			}
+			row.err = err
+			rows = append(rows, row)
+		}
+	default:
+		return nil, errImportFormat
+	}
+	return rows, nil
+}
+
+// parseShowtime reads the show time of an imported schedule.
+func parseShowtime(value string) (time.Time, error) {
+	if showTime, err := time.Parse(time.RFC3339, value); err == nil {
+		return showTime, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		if showTime, err := time.ParseInLocation(layout, value, showLocation); err == nil {
+			return showTime, nil
+		}
+	}
+	return time.Time{}, errors.New("showtime must be RFC 3339 or YYYY-MM-DD HH:MM")
+}
+
+// rowDataErrors are the errors MySQL rejects the data of a row with. Any
+// other error, such as a deadlock or a lock wait timeout after which InnoDB
+// has rolled back the whole transaction, stops the import.
+var rowDataErrors = map[uint16]bool{
+	1048:                 true, // column cannot be null
+	mysqlDuplicateEntry:  true,
+	1264:                 true, // out of range value
+	1265:                 true, // data truncated
+	1292:                 true, // incorrect date or number
+	1366:                 true, // incorrect value
+	1406:                 true, // data too long
+	1451:                 true, // row is referenced
+	mysqlNoReferencedRow: true,
+	3819:                 true, // check constraint violated
+}
+
+// rowError tells the errors of a row, including those MySQL rejects the row
+// with, from errors that should stop the import.
+func rowError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && rowDataErrors[mysqlErr.Number]
+}
+
+// importMovie creates the movie, or updates it when the row has an id.
+func importMovie(q queryer, movie models.Movie) (models.ImportedRow, error) {
+	if err := validMovie(&movie); err != nil {
+		return models.ImportedRow{Error: err.Error()}, nil
+	}
+	if movie.ID == 0 {
+		id, err := insertMovie(q, movie)
+		return models.ImportedRow{ID: id, Action: "created"}, err
+	}
+	found, err := updateMovie(q, movie)
+	if !found && err == nil {
+		return models.ImportedRow{Error: "the movie is not found"}, nil
+	}
+	return models.ImportedRow{ID: movie.ID, Action: "updated"}, err
+}
+
+// importSchedule creates the schedule, or updates it when the row has an id.
+// The theatre must be locked by the caller.
+func importSchedule(q queryer, row models.ScheduleImport) (models.ImportedRow, error) {
+	showTime, err := parseShowtime(row.Showtime)
+	if err != nil {
+		return models.ImportedRow{Error: err.Error()}, nil
+	}
+	schedule := models.Schedule{Price: row.Price, Prices: row.Prices, Format: models.Format(row.Format), Showtime: showTime}
+	if err := validSchedule(&schedule); err != nil {
+		return models.ImportedRow{Error: err.Error()}, nil
+	}
+
+	movieId := row.MovieID
+	if movieId == 0 {
+		if row.MovieTitle == "" {
+			return models.ImportedRow{Error: "a schedule needs a movieId or movieTitle"}, nil
+		}
+		ids, err := movieIdsByTitle(q, row.MovieTitle)
+		if err != nil {
+			return models.ImportedRow{}, err
+		}
+		switch len(ids) {
+		case 0:
+			return models.ImportedRow{Error: "Movie not found"}, nil
+		case 1:
+			movieId = ids[0]
+		default:
+			return models.ImportedRow{Error: fmt.Sprintf("%d movies are titled %q, give the movieId", len(ids), row.MovieTitle)}, nil
+		}
+	}
+	var count int
+	if err := q.QueryRow("SELECT COUNT(*) FROM movie WHERE id = ?", movieId).Scan(&count); err != nil || count == 0 {
+		return models.ImportedRow{Error: "Movie not found"}, err
+	}
+	if err := q.QueryRow("SELECT COUNT(*) FROM theatre WHERE id = ?", row.TheatreID).Scan(&count); err != nil || count == 0 {
+		return models.ImportedRow{Error: "Theatre not found"}, err
+	}
+
+	if row.ID == 0 {
+		scheduleId, conflicts, err := insertSchedule(q, schedule, movieId, row.TheatreID)
+		if formatError(err) {
+			return models.ImportedRow{Error: err.Error()}, nil
+		}
+		if err != nil || len(conflicts) > 0 {
+			return conflictRow(conflicts), err
+		}
+		return models.ImportedRow{ID: scheduleId, Action: "created"}, nil
+	}
+
+	// the same rule as UpdateMovieSchedule: a sold schedule stays as it is
+	if err := q.QueryRow("SELECT COUNT(*) FROM schedule WHERE id = ?", row.ID).Scan(&count); err != nil || count == 0 {
+		return models.ImportedRow{Error: "Schedule not found"}, err
+	}
+	if err := q.QueryRow("select count(*) from ticket where schedule_id = ?", row.ID).Scan(&count); err != nil || count > 0 {
+		return models.ImportedRow{Error: "Schedule already has tickets, it cannot be changed"}, err
+	}
+	conflicts, err := updateSchedule(q, row.ID, schedule, movieId, row.TheatreID)
+	if formatError(err) {
+		return models.ImportedRow{Error: err.Error()}, nil
+	}
+	if err != nil || len(conflicts) > 0 {
+		return conflictRow(conflicts), err
+	}
+	return models.ImportedRow{ID: row.ID, Action: "updated"}, nil
+}
+
+func conflictRow(conflicts []int) models.ImportedRow {
+	ids := make([]string, len(conflicts))
+	for i, id := range conflicts {
+		ids[i] = strconv.Itoa(id)
+	}
+	return models.ImportedRow{Error: errScheduleConflict.Error() + ": " + strings.Join(ids, ", ")}
+}
+
+// movieIdsByTitle returns the movies with exactly the title, case aside.
+func movieIdsByTitle(q queryer, title string) ([]int, error) {
+	rows, err := q.Query("select id from movie where title = ?", strings.TrimSpace(title))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Import reads movies or schedules from a CSV or JSON file and creates or
+// updates them with the same checks as the admin endpoints, all in one
+// transaction. Every row is reported; nothing is written when any row fails
+// or on a dry run. The returned error is for the file as a whole, and wraps
+// errImportFile when the file can't be read.
+func Import(db *sql.DB, kind models.ImportKind, format string, r io.Reader, dryRun bool) (models.ImportResult, error) {
+	result := models.ImportResult{Kind: kind, DryRun: dryRun, Rows: []models.ImportedRow{}}
+	format = strings.ToLower(format)
+
+	type importRow struct {
+		row   int
+		err   error
+		apply func(q queryer) (models.ImportedRow, error)
+	}
+	var rows []importRow
+	var theatres []int
+	switch kind {
+	case models.ImportMovies:
+		movies, err := parseMovieImport(format, r)
+		if err != nil {
+			return result, fmt.Errorf("%w: %v", errImportFile, err)
+		}
+		for _, movie := range movies {
+			movie := movie
+			rows = append(rows, importRow{row: movie.row, err: movie.err, apply: func(q queryer) (models.ImportedRow, error) {
+				return importMovie(q, movie.movie)
+			}})
+		}
+	case models.ImportSchedules:
+		schedules, err := parseScheduleImport(format, r)
+		if err != nil {
+			return result, fmt.Errorf("%w: %v", errImportFile, err)
+		}
+		seen := map[int]bool{}
+		for _, schedule := range schedules {
+			schedule := schedule
+			rows = append(rows, importRow{row: schedule.row, err: schedule.err, apply: func(q queryer) (models.ImportedRow, error) {
+				return importSchedule(q, schedule.schedule)
+			}})
+			if id := schedule.schedule.TheatreID; id > 0 && !seen[id] {
+				seen[id] = true
+				theatres = append(theatres, id)
+			}
+		}
+	default:
+		return result, fmt.Errorf("%w: unknown kind %q", errImportFile, kind)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	// lock the theatres in order, so two imports can't deadlock each other
+	sort.Ints(theatres)
+	for _, id := range theatres {
+		if err := lockTheatre(tx, id); err != nil && err != sql.ErrNoRows {
+			return result, err
+		}
+	}
+
+	for _, row := range rows {
+		imported := models.ImportedRow{Error: errorString(row.err)}
+		if row.err == nil {
+			if imported, err = row.apply(tx); rowError(err) {
+				imported = models.ImportedRow{Error: err.Error()}
+			} else if err != nil {
+				return result, err
+			}
+		}
+		imported.Row = row.row
+		switch {
+		case imported.Error != "":
+			result.Failed++
+		case imported.Action == "created":
+			result.Created++
+		default:
+			result.Updated++
+		}
+		result.Rows = append(result.Rows, imported)
+	}
+
+	if dryRun || result.Failed > 0 {
+		// the rows created in the transaction are gone with it
+		for i := range result.Rows {
+			if result.Rows[i].Action == "created" {
+				result.Rows[i].ID = 0
+			}
+		}
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+	invalidateCatalog()
+	return result, nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"tix-id/config"
+	"tix-id/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importFormat tells CSV from JSON by the file extension or content type,
+// unless the format query parameter says which.
+func importFormat(c *gin.Context, filename string) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+	if ext := strings.TrimPrefix(filepath.Ext(filename), "."); ext != "" {
+		return ext
+	}
+	if strings.Contains(c.ContentType(), "csv") {
+		return "csv"
+	}
+	return "json"
+}
+
+// ImportData godoc
+// @Summary Import Movies or Schedules
+// @Description Create or update movies or schedules from a CSV or JSON file, sent as the body or as the file field of a form. Rows with an id update, the others are created. Every row is checked like the admin endpoints do and reported; nothing is written if any row fails or with dryRun.
+// @Tags Admin
+// @Accept json,text/csv,multipart/form-data
+// @Produce json
+// @Param kind path string true "movies or schedules"
+// @Param dryRun query bool false "Check the rows without writing them"
+// @Param format query string false "csv or json, by default from the file name or content type"
+// @Success 200 {object} models.ImportResponse
+// @Failure 422 {object} models.ImportResponse
+// @Router /imports/{kind} [post]
+func ImportData(c *gin.Context) {
+	kind := models.ImportKind(c.Param("kind"))
+	if !kind.Valid() {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "imports are of movies or schedules"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+
+	var body io.Reader = c.Request.Body
+	filename := ""
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer file.Close()
+		body, filename = file, header.Filename
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	result, err := Import(db, kind, importFormat(c, filename), body, dryRun)
+	if err != nil {
+		if errors.Is(err, errImportFile) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if result.Failed > 0 {
+		c.JSON(http.StatusUnprocessableEntity, models.ImportResponse{
+			Response: models.Response{Status: 422, Message: strconv.Itoa(result.Failed) + " rows failed, nothing was imported"},
+			Result:   result,
+		})
+		return
+	}
+
+	message := strconv.Itoa(result.Created) + " created, " + strconv.Itoa(result.Updated) + " updated"
+	if dryRun {
+		message = "dry run: " + message
+	}
+	responseData := models.ImportResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: message,
+		},
+		Result: result,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
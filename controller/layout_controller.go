@@ -0,0 +1,432 @@
+package controller
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"tix-id/config"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/gin-gonic/gin"
+)
+
+type layoutSeat struct {
+	Row      string
+	Number   int
+	Position int
+	Type     models.SeatType
+}
+
+// expandLayout validates the layout and turns it into the list of seats it describes.
+func expandLayout(layout models.SeatLayout) ([]layoutSeat, error) {
+	var seats []layoutSeat
+	rows := map[string]bool{}
+	for _, row := range layout.Rows {
+		if len(row.Row) != 1 {
+			return nil, fmt.Errorf("row %q must be a single character", row.Row)
+		}
+		if rows[row.Row] {
+			return nil, fmt.Errorf("row %s is defined twice", row.Row)
+		}
+		rows[row.Row] = true
+		if row.Count < 1 {
+			return nil, fmt.Errorf("row %s must have at least one seat", row.Row)
+		}
+
+		width := row.Count + len(row.Gaps)
+		gaps := map[int]bool{}
+		for _, gap := range row.Gaps {
+			if gap < 1 || gap > width || gaps[gap] {
+				return nil, fmt.Errorf("row %s has an invalid gap at position %d", row.Row, gap)
+			}
+			gaps[gap] = true
+		}
+
+		number := 0
+		for position := 1; position <= width; position++ {
+			if gaps[position] {
+				continue
+			}
+			number++
+			seatType := row.Type
+			if t, ok := row.SeatTypes[number]; ok {
+				seatType = t
+			}
+			if seatType == "" {
+				seatType = models.Regular
+			}
+			if !seatType.Valid() {
+				return nil, fmt.Errorf("row %s seat %d has an unknown seat type %q", row.Row, number, seatType)
+			}
+			seats = append(seats, layoutSeat{Row: row.Row, Number: number, Position: position, Type: seatType})
+		}
+	}
+	return seats, nil
+}
+
+// layoutRowInfo is what a layout says of a row beyond its seats: its width
+// with the aisles, which keeps the aisles after the last seat, and the type
+// of its seats as it was given.
+type layoutRowInfo struct {
+	Width int
+	Type  models.SeatType
+}
+
+// loadLayout reads the seat layout of a theatre back into rows with gaps, as
+// it was saved. A row saved before the rows were kept ends at its last seat,
+// and its type is the one most of its seats have.
+func loadLayout(q queryer, theatreId int) (models.SeatLayout, error) {
+	layout := models.SeatLayout{TheatreID: theatreId, Rows: []models.LayoutRow{}}
+	infos := map[string]layoutRowInfo{}
+	rows, err := q.Query("select row, width, coalesce(seat_type, '') from theatre_row where theatre_id = ?", theatreId)
+	if err != nil {
+		return layout, err
+	}
+	for rows.Next() {
+		var row string
+		var info layoutRowInfo
+		if err := rows.Scan(&row, &info.Width, &info.Type); err != nil {
+			rows.Close()
+			return layout, err
+		}
+		infos[row] = info
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return layout, err
+	}
+
+	rows, err = q.Query("select row, seat_number, position, seat_type from theatre_seat where theatre_id = ? order by row, position", theatreId)
+	if err != nil {
+		return layout, err
+	}
+	defer rows.Close()
+	var seats []layoutSeat
+	for rows.Next() {
+		var seat layoutSeat
+		if err := rows.Scan(&seat.Row, &seat.Number, &seat.Position, &seat.Type); err != nil {
+			return layout, err
+		}
+		if len(seats) > 0 && seats[0].Row != seat.Row {
+			layout.Rows = append(layout.Rows, layoutRow(seats, infos))
+			seats = nil
+		}
+		seats = append(seats, seat)
+	}
+	if len(seats) > 0 {
+		layout.Rows = append(layout.Rows, layoutRow(seats, infos))
+	}
+	return layout, rows.Err()
+}
+
+// layoutRow makes the row of the seats, which are in the order of their positions.
+func layoutRow(seats []layoutSeat, infos map[string]layoutRowInfo) models.LayoutRow {
+	row := models.LayoutRow{Row: seats[0].Row, Count: len(seats), SeatTypes: map[int]models.SeatType{}}
+	info, ok := infos[row.Row]
+	if !ok {
+		info.Width = seats[len(seats)-1].Position
+		counts := map[models.SeatType]int{}
+		for _, seat := range seats {
+			counts[seat.Type]++
+			if counts[seat.Type] > counts[info.Type] {
+				info.Type = seat.Type
+			}
+		}
+	}
+	row.Type = info.Type
+	rowType := info.Type
+	if rowType == "" {
+		rowType = models.Regular
+	}
+
+	position := 1
+	for _, seat := range seats {
+		for ; position < seat.Position; position++ {
+			row.Gaps = append(row.Gaps, position)
+		}
+		position = seat.Position + 1
+		if seat.Type != rowType {
+			row.SeatTypes[seat.Number] = seat.Type
+		}
+	}
+	for ; position <= info.Width; position++ {
+		row.Gaps = append(row.Gaps, position)
+	}
+	return row
+}
+
+// copyTheatreLayout creates the seats of a schedule from the layout of its theatre.
+func copyTheatreLayout(q queryer, scheduleId, theatreId int) error {
+	_, err := q.Exec("insert into seat (row, seat_number, position, seat_type, schedule_id) select row, seat_number, position, seat_type, ? from theatre_seat where theatre_id = ? order by row, position", scheduleId, theatreId)
+	return err
+}
+
+// GetTheatreLayout godoc
+// @Summary Get Theatre Seat Layout
+// @Description Get the seat layout of a Theatre
+// @Tags Admin
+// @Produce json
+// @Param branchId path int true "Branch ID"
+// @Param theatreId path int true "Theatre ID"
+// @Success 200 {object} models.SeatLayoutResponse
+// @Router /branches/{branchId}/theatres/{theatreId}/layout [get]
+func GetTheatreLayout(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	branchId, err := strconv.Atoi(c.Param("branchId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+	theatreId, err := strconv.Atoi(c.Param("theatreId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid theatre ID"})
+		return
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM theatre WHERE id=? && branch_id=?", theatreId, branchId).Scan(&count); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Theatre not found"})
+		return
+	}
+
+	layout, err := loadLayout(db, theatreId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.SeatLayoutResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Seat layout retrieved successfully",
+		},
+		Layout: layout,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// UpdateTheatreLayout godoc
+// @Summary Update Theatre Seat Layout
+// @Description Replace the seat layout of a Theatre. New schedules inherit this layout.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param branchId path int true "Branch ID"
+// @Param theatreId path int true "Theatre ID"
+// @Param body body models.SeatLayout true "Seat layout"
+// @Success 200 {object} models.SeatLayoutResponse
+// @Router /branches/{branchId}/theatres/{theatreId}/layout [put]
+func UpdateTheatreLayout(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	branchId, err := strconv.Atoi(c.Param("branchId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+	theatreId, err := strconv.Atoi(c.Param("theatreId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid theatre ID"})
+		return
+	}
+
+	var layout models.SeatLayout
+	if err := c.ShouldBindJSON(&layout); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seats, err := expandLayout(layout)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM theatre WHERE id=? && branch_id=?", theatreId, branchId).Scan(&count); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Theatre not found"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM theatre_seat WHERE theatre_id = ?", theatreId); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM theatre_row WHERE theatre_id = ?", theatreId); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, row := range layout.Rows {
+		_, err := tx.Exec("INSERT INTO theatre_row (theatre_id, row, width, seat_type) VALUES (?, ?, ?, nullif(?, ''))", theatreId, row.Row, row.Count+len(row.Gaps), row.Type)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	for _, seat := range seats {
+		_, err := tx.Exec("INSERT INTO theatre_seat (theatre_id, row, seat_number, position, seat_type) VALUES (?, ?, ?, ?, ?)", theatreId, seat.Row, seat.Number, seat.Position, seat.Type)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	layout, err = loadLayout(tx, theatreId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.SeatLayoutResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Seat layout updated successfully",
+		},
+		Layout: layout,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// UpdateScheduleSeat godoc
+// @Summary Override a schedule seat
+// @Description Block or unblock a single seat for one showtime, or change its type. A seat that is sold or held can't be blocked or change type.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param movieId path string true "Movie ID"
+// @Param scheduleId path string true "Schedule ID"
+// @Param seatId path string true "Seat ID"
+// @Param body body models.SeatOverride true "Seat override"
+// @Success 200 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Router /movies/{movieId}/schedules/{scheduleId}/seats/{seatId} [put]
+func UpdateScheduleSeat(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	var override models.SeatOverride
+	if err := c.ShouldBindJSON(&override); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if override.Blocked == nil && override.Type == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to change, give blocked or type"})
+		return
+	}
+	if override.Type != "" && !override.Type.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown seat type %q", override.Type)})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// the seat row is locked like a booking locks it, so it can't be sold meanwhile
+	var seatId, scheduleId int
+	err = tx.QueryRow("select se.id, sc.id from seat se join schedule sc on sc.id = se.schedule_id where se.id = ? and sc.id = ? and sc.movie_id = ? for update", c.Param("seatId"), c.Param("scheduleId"), c.Param("movieId")).Scan(&seatId, &scheduleId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response := models.Response{
+				Status:  404,
+				Message: "the seat is not match with the schedule",
+			}
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// unblocking is always safe, anything else would change a seat a customer has
+	if (override.Blocked != nil && *override.Blocked) || override.Type != "" {
+		var sold int
+		if err := tx.QueryRow("select count(*) from ticket where schedule_id = ? and seat_id = ? and active = 1", scheduleId, seatId).Scan(&sold); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if sold > 0 {
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: fmt.Sprintf("Seat %d is sold", seatId)})
+			return
+		}
+		redisClient := tool.NewRedisClient()
+		defer redisClient.Close()
+		holders, err := tool.SeatHolders(redisClient, scheduleId, []int{seatId})
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(holders) > 0 {
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: fmt.Sprintf("Seat %d is held by a customer", seatId)})
+			return
+		}
+	}
+
+	if _, err := tx.Exec("update seat set blocked = coalesce(?, blocked), seat_type = coalesce(nullif(?, ''), seat_type) where id = ?", override.Blocked, override.Type, seatId); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var changes []string
+	if override.Blocked != nil && *override.Blocked {
+		changes = append(changes, "blocked")
+	} else if override.Blocked != nil {
+		changes = append(changes, "unblocked")
+	}
+	if override.Type != "" {
+		changes = append(changes, "a "+string(override.Type)+" seat")
+	}
+	responseData := models.Response{
+		Status:  200,
+		Message: fmt.Sprintf("Seat %d is %s", seatId, strings.Join(changes, " and ")),
+	}
+	c.JSON(http.StatusOK, responseData)
+}
@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"tix-id/models"
+	"tix-id/tool"
+)
+
+// maxMediaUpload is the largest image file an admin can upload.
+const maxMediaUpload = 10 << 20
+
+const originalVariant = "original"
+
+var errMovieNotFound = errors.New("movie not found")
+
+type imageSize struct {
+	Name  string
+	Width int
+}
+
+// mediaRule is what an image of a kind must be, and the sizes it is resized
+// to, largest first.
+type mediaRule struct {
+	MinWidth  int
+	MinHeight int
+	Sizes     []imageSize
+}
+
+var mediaRules = map[models.MediaKind]mediaRule{
+	models.PosterMedia: {
+		MinWidth: 300, MinHeight: 450,
+		Sizes: []imageSize{{"large", 1000}, {"medium", 500}, {"small", 200}},
+	},
+	models.BackdropMedia: {
+		MinWidth: 1280, MinHeight: 720,
+		Sizes: []imageSize{{"large", 1920}, {"medium", 1280}, {"small", 640}},
+	},
+}
+
+type storedImage struct {
+	Variant     string
+	Key         string
+	Width       int
+	Height      int
+	ContentType string
+}
+
+// validationError is an upload the admin has to fix, as opposed to a storage
+// or database failure.
+type validationError struct{ error }
+
+func newMediaKey() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// prepareMovieImage checks the upload and makes the files to store: the
+// original as it was sent, and a JPEG of each size.
+func prepareMovieImage(movieId int, kind models.MediaKind, data []byte) ([]storedImage, map[string][]byte, error) {
+	rule := mediaRules[kind]
+	img, contentType, err := tool.DecodeImage(data)
+	if err != nil {
+		return nil, nil, validationError{err}
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width < rule.MinWidth || height < rule.MinHeight {
+		return nil, nil, validationError{fmt.Errorf("a %s must be at least %dx%d pixels", kind, rule.MinWidth, rule.MinHeight)}
+	}
+
+	suffix, err := newMediaKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	prefix := fmt.Sprintf("movies/%d/%s-%s/", movieId, kind, suffix)
+	original := storedImage{originalVariant, prefix + originalVariant + "." + tool.ImageFormats[contentType], width, height, contentType}
+	images := []storedImage{original}
+	files := map[string][]byte{original.Key: data}
+	flat := tool.FlattenImage(img)
+	for _, size := range rule.Sizes {
+		resized := tool.ResizeImage(flat, size.Width)
+		encoded, err := tool.EncodeJPEG(resized)
+		if err != nil {
+			return nil, nil, err
+		}
+		image := storedImage{size.Name, prefix + size.Name + ".jpg", resized.Bounds().Dx(), resized.Bounds().Dy(), "image/jpeg"}
+		images = append(images, image)
+		files[image.Key] = encoded
+	}
+	return images, files, nil
+}
+
+// saveMovieImage stores an uploaded image of a movie in every size and
+// replaces the image of that kind the movie had. The new files are written
+// before the database is changed and the old ones removed after, so the
+// movie never points at a missing file.
+func saveMovieImage(db *sql.DB, storage tool.Storage, movieId int, kind models.MediaKind, data []byte) (models.MovieMedia, error) {
+	var exists int
+	if err := db.QueryRow("select id from movie where id = ?", movieId).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return models.MovieMedia{}, errMovieNotFound
+		}
+		return models.MovieMedia{}, err
+	}
+
+	images, files, err := prepareMovieImage(movieId, kind, data)
+	if err != nil {
+		return models.MovieMedia{}, err
+	}
+	var written []string
+	for _, image := range images {
+		if err = storage.Put(image.Key, image.ContentType, files[image.Key]); err != nil {
+			break
+		}
+		written = append(written, image.Key)
+	}
+	if err == nil {
+		var old []string
+		old, err = replaceMovieImage(db, movieId, kind, images)
+		if err == nil {
+			deleteStoredFiles(storage, old)
+			media := models.MovieMedia{MovieID: movieId, Kind: kind, Width: images[0].Width, Height: images[0].Height, URLs: models.ImageURLs{}}
+			for _, image := range images {
+				media.URLs[image.Variant] = storage.URL(image.Key)
+			}
+			return media, nil
+		}
+	}
+	deleteStoredFiles(storage, written)
+	return models.MovieMedia{}, err
+}
+
+// replaceMovieImage swaps the rows of the image in one transaction and
+// returns the keys of the files the old image used.
+func replaceMovieImage(db *sql.DB, movieId int, kind models.MediaKind, images []storedImage) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow("select id from movie where id = ? for update", movieId).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errMovieNotFound
+		}
+		return nil, err
+	}
+	old, err := movieImageKeys(tx, movieId, kind)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("delete from movie_media where movie_id = ? and kind = ?", movieId, kind); err != nil {
+		return nil, err
+	}
+	for _, image := range images {
+		if _, err := tx.Exec("insert into movie_media (movie_id, kind, variant, storage_key, width, height, content_type) values (?, ?, ?, ?, ?, ?, ?)",
+			movieId, kind, image.Variant, image.Key, image.Width, image.Height, image.ContentType); err != nil {
+			return nil, err
+		}
+	}
+	return old, tx.Commit()
+}
+
+// deleteMovieImage removes the image of a kind from a movie and reports if
+// it had one.
+func deleteMovieImage(db *sql.DB, storage tool.Storage, movieId int, kind models.MediaKind) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	keys, err := movieImageKeys(tx, movieId, kind)
+	if err != nil || len(keys) == 0 {
+		return false, err
+	}
+	if _, err := tx.Exec("delete from movie_media where movie_id = ? and kind = ?", movieId, kind); err != nil {
+		return true, err
+	}
+	if err := tx.Commit(); err != nil {
+		return true, err
+	}
+	deleteStoredFiles(storage, keys)
+	return true, nil
+}
+
+func movieImageKeys(q queryer, movieId int, kind models.MediaKind) ([]string, error) {
+	rows, err := q.Query("select storage_key from movie_media where movie_id = ? and kind = ? for update", movieId, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// deleteStoredFiles removes files that are no longer used. A file that
+// can't be removed is only logged, it is just left behind in the storage.
+func deleteStoredFiles(storage tool.Storage, keys []string) {
+	for _, key := range keys {
+		if err := storage.Delete(key); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// loadMovieImages fills in the URLs of the posters and backdrops of the
+// movies, indexed by id as loadMovieDetails does. Without a working storage
+// the movies are listed without images.
+func loadMovieImages(q queryer, movies []models.Movie, indexes map[int][]int, args []interface{}) error {
+	storage, err := tool.NewStorage()
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	rows, err := q.Query("select movie_id, kind, variant, storage_key from movie_media where movie_id in ("+placeholders(len(args))+")", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var movieId int
+		var kind models.MediaKind
+		var variant, key string
+		if err := rows.Scan(&movieId, &kind, &variant, &key); err != nil {
+			return err
+		}
+		for _, i := range indexes[movieId] {
+			urls := &movies[i].Poster
+			if kind == models.BackdropMedia {
+				urls = &movies[i].Backdrop
+			}
+			if *urls == nil {
+				*urls = models.ImageURLs{}
+			}
+			(*urls)[variant] = storage.URL(key)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"tix-id/config"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadMovieMedia godoc
+// @Summary Upload Movie Image
+// @Description Upload the poster or backdrop of a movie as the file field of a form. The image must be a JPEG or PNG of at most 10 MiB, and a poster at least 300x450 and a backdrop at least 1280x720 pixels. Large, medium and small JPEGs are made from it, and it replaces the image the movie had.
+// @Tags Admin
+// @Accept multipart/form-data
+// @Produce json
+// @Param movieId path int true "Movie ID"
+// @Param kind path string true "poster or backdrop"
+// @Param file formData file true "The image"
+// @Success 201 {object} models.MovieMediaResponse
+// @Router /movies/{movieId}/media/{kind} [post]
+func UploadMovieMedia(c *gin.Context) {
+	movieId, err := strconv.Atoi(c.Param("movieId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movie ID"})
+		return
+	}
+	kind := models.MediaKind(c.Param("kind"))
+	if !kind.Valid() {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "movie images are posters or backdrops"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxMediaUpload+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if header.Size > maxMediaUpload {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "the image can be at most 10 MiB"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxMediaUpload))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	storage, err := tool.NewStorage()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	media, err := saveMovieImage(db, storage, movieId, kind, data)
+	if err != nil {
+		var invalid validationError
+		switch {
+		case errors.Is(err, errMovieNotFound):
+			c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "Movie not found"})
+		case errors.As(err, &invalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	responseData := models.MovieMediaResponse{
+		Response: models.Response{
+			Status:  201,
+			Message: "Success upload " + string(kind),
+		},
+		Media: media,
+	}
+	invalidateCatalog()
+	c.JSON(http.StatusCreated, responseData)
+}
+
+// DeleteMovieMedia godoc
+// @Summary Delete Movie Image
+// @Description Remove the poster or backdrop of a movie, with all its sizes.
+// @Tags Admin
+// @Produce json
+// @Param movieId path int true "Movie ID"
+// @Param kind path string true "poster or backdrop"
+// @Success 200 {object} models.Response
+// @Router /movies/{movieId}/media/{kind} [delete]
+func DeleteMovieMedia(c *gin.Context) {
+	movieId, err := strconv.Atoi(c.Param("movieId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movie ID"})
+		return
+	}
+	kind := models.MediaKind(c.Param("kind"))
+	if !kind.Valid() {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "movie images are posters or backdrops"})
+		return
+	}
+
+	storage, err := tool.NewStorage()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	found, err := deleteMovieImage(db, storage, movieId, kind)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "The movie has no " + string(kind)})
+		return
+	}
+	invalidateCatalog()
+	c.JSON(http.StatusOK, models.Response{Status: 200, Message: "Success delete " + string(kind)})
+}
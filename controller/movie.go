@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+	"tix-id/models"
+)
+
+var errAgeNotConfirmed = errors.New("the movie is age restricted, confirm every viewer is old enough")
+
+// movieColumns are the columns scanMovie reads, of a movie aliased m.
+const movieColumns = "m.id, m.title, m.description, m.duration, m.rating, m.release_date, date_format(m.run_start_date, '%Y-%m-%d'), date_format(m.run_end_date, '%Y-%m-%d'), coalesce(m.language, ''), coalesce(m.subtitles, ''), m.classification, m.formats, coalesce(m.trailer_url, '')"
+
+func scanMovie(row scanner) (models.Movie, error) {
+	var movie models.Movie
+	var subtitles, formats string
+	err := row.Scan(&movie.ID, &movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate, &movie.RunStartDate, &movie.RunEndDate, &movie.Language, &subtitles, &movie.Classification, &formats, &movie.TrailerURL)
+	if subtitles != "" {
+		movie.Subtitles = strings.Split(subtitles, ",")
+	}
+	movie.Formats = parseFormatSet(formats)
+	return movie, err
+}
+
+// validMovie checks a movie before it is created or updated.
+func validMovie(movie *models.Movie) error {
+	movie.Title = strings.TrimSpace(movie.Title)
+	if movie.Title == "" {
+		return errors.New("a movie needs a title")
+	}
+	if movie.Duration <= 0 {
+		return errors.New("the duration must be a positive number of minutes")
+	}
+	if movie.RunStartDate != nil {
+		if _, err := time.Parse("2006-01-02", *movie.RunStartDate); err != nil {
+			return errors.New("runStartDate must be YYYY-MM-DD")
+		}
+	}
+	if movie.RunEndDate != nil {
+		if _, err := time.Parse("2006-01-02", *movie.RunEndDate); err != nil {
+			return errors.New("runEndDate must be YYYY-MM-DD")
+		}
+		// the dates are YYYY-MM-DD, so they compare as strings
+		if movie.RunStartDate != nil && *movie.RunEndDate < *movie.RunStartDate {
+			return errors.New("the run can't end before it starts")
+		}
+	}
+	if movie.Classification != "" && !movie.Classification.Valid() {
+		return errors.New("the classification must be SU, 13+, 17+ or 21+")
+	}
+	if err := validFormats(movie.Formats); err != nil {
+		return err
+	}
+	movie.TrailerURL = strings.TrimSpace(movie.TrailerURL)
+	if movie.TrailerURL != "" {
+		trailer, err := url.Parse(movie.TrailerURL)
+		if err != nil || (trailer.Scheme != "http" && trailer.Scheme != "https") || trailer.Host == "" {
+			return errors.New("trailerUrl must be an http or https URL")
+		}
+	}
+	movie.Language = strings.TrimSpace(movie.Language)
+	for i, subtitle := range movie.Subtitles {
+		movie.Subtitles[i] = strings.TrimSpace(subtitle)
+		if movie.Subtitles[i] == "" || strings.Contains(subtitle, ",") {
+			return errors.New("invalid subtitle language")
+		}
+	}
+	for i, genre := range movie.Genres {
+		movie.Genres[i] = strings.TrimSpace(genre)
+		if movie.Genres[i] == "" {
+			return errors.New("a genre needs a name")
+		}
+	}
+	for i, credit := range movie.Credits {
+		movie.Credits[i].Name = strings.TrimSpace(credit.Name)
+		if movie.Credits[i].Name == "" || !credit.Role.Valid() {
+			return errors.New("a credit needs a name and a role of cast, director, writer or producer")
+		}
+	}
+	return nil
+}
+
+// insertMovie creates a movie with its genres and credits. A movie without
+// a classification is for all ages, and one without formats plays in 2D.
+func insertMovie(q queryer, movie models.Movie) (int, error) {
+	res, err := q.Exec("INSERT INTO movie (title, description, duration, rating, release_date, run_start_date, run_end_date, language, subtitles, classification, formats, trailer_url) VALUES (?, ?, ?, ?, coalesce(nullif(?, ''), current_timestamp()), ?, ?, nullif(?, ''), nullif(?, ''), coalesce(nullif(?, ''), default(classification)), coalesce(nullif(?, ''), default(formats)), nullif(?, ''))",
+		movie.Title, movie.Description, movie.Duration, movie.Rating, movie.ReleaseDate, movie.RunStartDate, movie.RunEndDate, movie.Language, strings.Join(movie.Subtitles, ","), movie.Classification, formatSet(movie.Formats), movie.TrailerURL)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), saveMovieDetails(q, int(id), movie)
+}
+
+// updateMovie changes a movie and reports if it exists. The release date,
+// classification and formats are kept when they are left empty, and so are
+// the genres and credits when they are nil.
+func updateMovie(q queryer, movie models.Movie) (bool, error) {
+	var id int
+	if err := q.QueryRow("select id from movie where id = ? for update", movie.ID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	_, err := q.Exec("UPDATE movie SET title=?, description=?, duration=?, rating=?, release_date=coalesce(nullif(?, ''), release_date), run_start_date=?, run_end_date=?, language=nullif(?, ''), subtitles=nullif(?, ''), classification=coalesce(nullif(?, ''), classification), formats=coalesce(nullif(?, ''), formats), trailer_url=nullif(?, '') WHERE id=?",
+		movie.Title, movie.Description, movie.Duration, movie.Rating, movie.ReleaseDate, movie.RunStartDate, movie.RunEndDate, movie.Language, strings.Join(movie.Subtitles, ","), movie.Classification, formatSet(movie.Formats), movie.TrailerURL, movie.ID)
+	if err != nil {
+		return true, err
+	}
+	return true, saveMovieDetails(q, movie.ID, movie)
+}
+
+// saveMovieDetails replaces the genres and the credits of a movie, each only
+// when it is given. Genres are created the first time they are used.
+func saveMovieDetails(q queryer, movieId int, movie models.Movie) error {
+	if movie.Genres != nil {
+		if _, err := q.Exec("delete from movie_genre where movie_id = ?", movieId); err != nil {
+			return err
+		}
+		for _, genre := range movie.Genres {
+			if _, err := q.Exec("insert ignore into genre (name) values (?)", genre); err != nil {
+				return err
+			}
+			if _, err := q.Exec("insert ignore into movie_genre (movie_id, genre_id) select ?, id from genre where name = ?", movieId, genre); err != nil {
+				return err
+			}
+		}
+	}
+	if movie.Credits != nil {
+		if _, err := q.Exec("delete from movie_credit where movie_id = ?", movieId); err != nil {
+			return err
+		}
+		for i, credit := range movie.Credits {
+			if _, err := q.Exec("insert into movie_credit (movie_id, name, role, character_name, position) values (?, ?, ?, nullif(?, ''), ?)", movieId, credit.Name, credit.Role, credit.Character, i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadMovieDetails fills in the genres, credits and image URLs of the movies.
+func loadMovieDetails(q queryer, movies []models.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+	indexes := map[int][]int{}
+	var args []interface{}
+	for i, movie := range movies {
+		if _, ok := indexes[movie.ID]; !ok {
+			args = append(args, movie.ID)
+		}
+		indexes[movie.ID] = append(indexes[movie.ID], i)
+	}
+
+	rows, err := q.Query("select mg.movie_id, g.name from movie_genre mg join genre g on g.id = mg.genre_id where mg.movie_id in ("+placeholders(len(args))+") order by g.name", args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var movieId int
+		var genre string
+		if err := rows.Scan(&movieId, &genre); err != nil {
+			rows.Close()
+			return err
+		}
+		for _, i := range indexes[movieId] {
+			movies[i].Genres = append(movies[i].Genres, genre)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = q.Query("select movie_id, name, role, coalesce(character_name, '') from movie_credit where movie_id in ("+placeholders(len(args))+") order by movie_id, position", args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var movieId int
+		var credit models.Credit
+		if err := rows.Scan(&movieId, &credit.Name, &credit.Role, &credit.Character); err != nil {
+			rows.Close()
+			return err
+		}
+		for _, i := range indexes[movieId] {
+			movies[i].Credits = append(movies[i].Credits, credit)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return loadMovieImages(q, movies, indexes, args)
+}
+
+// confirmAge refuses a booking of an age restricted movie unless the customer
+// confirmed every viewer is old enough.
+func confirmAge(movie *models.Movie, confirmed bool) error {
+	if movie != nil && movie.Classification.Restricted() && !confirmed {
+		return errAgeNotConfirmed
+	}
+	return nil
+}
@@ -3,10 +3,10 @@ package controller
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 	"tix-id/config"
 	"tix-id/models"
@@ -33,36 +33,45 @@ func GetMovies(c *gin.Context) {
 
 	var movies []models.Movie
 	params := []interface{}{}
-	redisKey := "movies"
 	redisClient := tool.NewRedisClient()
+	defer redisClient.Close()
 
-	query := "select m.id, m.title, m.description, m.duration, m.rating, m.release_date from movie m join schedule sc on m.id = sc.movie_id join theatre t on sc.theatre_id = t.id join branch b on t.branch_id = b.id where 1 = 1"
+	// the key changes with the catalog version, like the other catalog reads,
+	// so an edited movie or schedule is listed right away
+	version, err := tool.GetRedisValue(redisClient, catalogVersionKey)
+	if err != nil {
+		version = "0"
+	}
+	redisKey := strings.Join([]string{"movies", version, c.Query("show_time"), c.Query("branch"), c.Query("rating")}, ":")
+
+	// movies without schedules are listed too, unless a schedule filter is given
+	query := "select " + movieColumns + " from movie m where 1 = 1"
+	schedules := ""
 	// check if there is params show_time
 	if showTime := c.Query("show_time"); showTime != "" {
-		redisKey += showTime + ":"
-		query += " AND DATE(sc.show_time) = ?"
+		schedules += " AND DATE(sc.show_time) = ?"
 		params = append(params, showTime)
 	}
 
+	// // check if there is params branch
+	if branch := c.Query("branch"); branch != "" {
+		schedules += " AND b.name like ?"
+		params = append(params, "%"+branch+"%")
+	}
+	if schedules != "" {
+		query += " AND exists (select 1 from schedule sc join theatre t on sc.theatre_id = t.id join branch b on t.branch_id = b.id where sc.movie_id = m.id" + schedules + ")"
+	}
+
 	// // check if there is params rating
 	if rating := c.Query("rating"); rating != "" {
-		redisKey += rating + ":"
 		query += " AND m.rating > ?"
 		params = append(params, rating)
 	}
 
-	// // check if there is params branch
-	if branch := c.Query("branch"); branch != "" {
-		redisKey += branch + ":"
-		query += " AND b.name like ?"
-		params = append(params, "%"+branch+"%")
-	}
-
 	query += " ORDER BY m.release_date DESC"
 
 	moviesCache, err := tool.GetRedisValue(redisClient, redisKey)
 	if err == nil {
-		fmt.Println("Mengambil data dari redis")
 		err := json.Unmarshal([]byte(moviesCache), &movies)
 		if err != nil {
 			log.Println(err)
@@ -81,14 +90,20 @@ func GetMovies(c *gin.Context) {
 		noData := true
 		for rows.Next() {
 			noData = false
-			var movie models.Movie
-			if err := rows.Scan(&movie.ID, &movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate); err != nil {
+			movie, err := scanMovie(rows)
+			if err != nil {
 				log.Println(err)
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
 			movies = append(movies, movie)
 		}
+		rows.Close()
+		if err := loadMovieDetails(db, movies); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
 		if noData {
 			response := models.Response{
@@ -122,13 +137,19 @@ func GetMovies(c *gin.Context) {
 
 // SearchMovies godoc
 // @Summary Search movies by title and genre
-// @Description Search movies by title and genre
+// @Description Search movies by title, genre, cast or crew, language, subtitle and classification. At least one of them is needed. With q the movies are found by words of their title, description, cast and crew or genres, most relevant first. When nothing matches as typed, misspelled words are matched with the words they most likely meant.
 // @Tags Guest
 // @Accept json
 // @Produce json
+// @Param q query string false "Words to search for"
+// @Param limit query int false "Most movies found with q, 50 unless given, at most 100"
 // @Param title query string false "Movie title to search"
 // @Param genre query string false "Movie genre to search"
-// @Success 200 {object} models.MoviesResponse
+// @Param person query string false "Name of someone in the cast or crew"
+// @Param language query string false "Spoken language"
+// @Param subtitle query string false "Subtitle language"
+// @Param classification query string false "SU, 13+, 17+ or 21+"
+// @Success 200 {object} models.MovieSearchResponse
 // @Router /movies/search [get]
 func SearchMovies(c *gin.Context) {
 
@@ -136,43 +157,124 @@ func SearchMovies(c *gin.Context) {
 	defer db.Close()
 	params := []interface{}{}
 
-	query := "SELECT id, title, description, duration, rating, release_date FROM movie where 1 = 1"
+	filters := ""
 	if title := c.Query("title"); title != "" {
-		query += " AND title like ?"
+		filters += " AND m.title like ?"
 		params = append(params, "%"+title+"%")
-	} else {
+	}
+	if genre := c.Query("genre"); genre != "" {
+		filters += " AND exists (select 1 from movie_genre mg join genre g on g.id = mg.genre_id where mg.movie_id = m.id and g.name = ?)"
+		params = append(params, genre)
+	}
+	if person := c.Query("person"); person != "" {
+		filters += " AND exists (select 1 from movie_credit mc where mc.movie_id = m.id and mc.name like ?)"
+		params = append(params, "%"+person+"%")
+	}
+	if language := c.Query("language"); language != "" {
+		filters += " AND m.language = ?"
+		params = append(params, language)
+	}
+	if subtitle := c.Query("subtitle"); subtitle != "" {
+		filters += " AND find_in_set(?, m.subtitles)"
+		params = append(params, subtitle)
+	}
+	if classification := c.Query("classification"); classification != "" {
+		filters += " AND m.classification = ?"
+		params = append(params, classification)
+	}
+
+	var movies []models.Movie
+	var corrections map[string][]string
+	var err error
+	search := strings.TrimSpace(c.Query("q"))
+	if search != "" {
+		terms := tool.SearchTerms(search)
+		if len(terms) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q has no words to search for"})
+			return
+		}
+		limit := defaultSearchLimit
+		if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+			limit = l
+		}
+		if limit > maxSearchLimit {
+			limit = maxSearchLimit
+		}
+		// misspelled words are only looked for when nothing matches as typed
+		movies, err = rankedMovies(db, search, terms, nil, filters, params, limit)
+		if err == nil && len(movies) == 0 {
+			if corrections, err = correctTerms(db, terms); err == nil && len(corrections) > 0 {
+				movies, err = rankedMovies(db, search, terms, corrections, filters, params, limit)
+			}
+		}
+	} else if len(params) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no title at params!"})
 		return
+	} else {
+		movies, err = findMovies(db, "SELECT "+movieColumns+" FROM movie m where 1 = 1"+filters, params)
 	}
-
-	// Execute a SELECT query to retrieve all movies from the database
-	rows, err := db.Query(query, params...)
 	if err != nil {
+		log.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve movies from database"})
 		return
 	}
+	if err := loadMovieDetails(db, movies); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve movie from database"})
+		return
+	}
 
-	// Iterate over the rows returned from the query and store them in a slice of movie structs
-	var movies []models.Movie
-	for rows.Next() {
-		var movie models.Movie
-		err := rows.Scan(&movie.ID, &movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve movie from database"})
+	responseData := models.MovieSearchResponse{
+		Response: models.Response{
+			Status:  http.StatusOK,
+			Message: "Movies retrieved successfully",
+		},
+		Movies:      movies,
+		Corrections: corrections,
+	}
+
+	c.JSON(http.StatusOK, responseData)
+}
+
+// SuggestMovies godoc
+// @Summary Suggest searches
+// @Description Complete a search as it is typed, with the movies whose title has its words, the last one as a prefix, then genres and people. Misspelled words are corrected when no title matches.
+// @Tags Guest
+// @Produce json
+// @Param q query string true "What has been typed so far"
+// @Param limit query int false "Most suggestions, 10 unless given, at most 20"
+// @Success 200 {object} models.SuggestionsResponse
+// @Router /movies/suggest [get]
+func SuggestMovies(c *gin.Context) {
+	search := strings.TrimSpace(c.Query("q"))
+	limit := defaultSuggestLimit
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+
+	suggestions := []models.Suggestion{}
+	if len([]rune(search)) >= 2 {
+		db := config.ConnectDB()
+		defer db.Close()
+
+		var err error
+		if suggestions, err = suggest(db, search, limit); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		movies = append(movies, movie)
-
 	}
 
-	responseData := models.MoviesResponse{
+	responseData := models.SuggestionsResponse{
 		Response: models.Response{
 			Status:  http.StatusOK,
-			Message: "Movies retrieved successfully",
+			Message: "Suggestions retrieved successfully",
 		},
-		Movies: movies,
+		Suggestions: suggestions,
 	}
-
 	c.JSON(http.StatusOK, responseData)
 }
 
@@ -197,8 +299,7 @@ func GetMovieById(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve branches from database"})
 		return
 	}
-	var movie models.Movie
-	err = db.QueryRow("Select title,description,duration,rating,release_date from movie where id =?", movieId).Scan(&movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate)
+	movie, err := scanMovie(db.QueryRow("Select "+movieColumns+" from movie m where m.id =?", movieId))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			response := models.Response{
@@ -212,6 +313,13 @@ func GetMovieById(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	movies := []models.Movie{movie}
+	if err := loadMovieDetails(db, movies); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	movie = movies[0]
 	responseData := models.MovieResponse{
 		Response: models.Response{
 			Status:  200,
@@ -238,6 +346,10 @@ func CreateMovie(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validMovie(&movie); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Connect to database
 	db := config.ConnectDB()
@@ -245,22 +357,26 @@ func CreateMovie(c *gin.Context) {
 	// Ensure the database connection is closed when the function returns
 	defer db.Close()
 
-	// Insert the movie into the database
-	result, err := db.Exec("INSERT INTO movie (title, description, duration, rating, release_date) VALUES (?, ?, ?, ?, ?)", movie.Title, movie.Description, movie.Duration, movie.Rating, movie.ReleaseDate)
+	// Insert the movie with its genres and credits into the database
+	tx, err := db.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Get the ID of the inserted movie
-	id, err := result.LastInsertId()
+	defer tx.Rollback()
+	id, err := insertMovie(tx, movie)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get ID of inserted movie"})
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Set the ID of the movie to the inserted ID
-	movie.ID = int(id)
+	movie.ID = id
 
 	// Create a MovieResponse struct with the inserted movie and send it as a JSON response
 	responseData := models.MovieResponse{
@@ -270,6 +386,7 @@ func CreateMovie(c *gin.Context) {
 		},
 		Movie: movie,
 	}
+	invalidateCatalog()
 	c.JSON(http.StatusOK, responseData)
 }
 
@@ -302,24 +419,32 @@ func UpdateMovie(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validMovie(&movie); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	movie.ID = movieID
 	// Update movie in the database
-	result, err := db.Exec("UPDATE movie SET title=?, description=?, duration=?, rating=?, release_date=? WHERE id=?", movie.Title, movie.Description, movie.Duration, movie.Rating, movie.ReleaseDate, movie.ID)
+	tx, err := db.Begin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	rowsAffected, err := result.RowsAffected()
+	defer tx.Rollback()
+	found, err := updateMovie(tx, movie)
 	if err != nil {
+		log.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	if rowsAffected == 0 {
+	if !found {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	responseData := models.MovieResponse{
 		Response: models.Response{
@@ -328,6 +453,7 @@ func UpdateMovie(c *gin.Context) {
 		},
 		Movie: movie,
 	}
+	invalidateCatalog()
 	c.JSON(http.StatusOK, responseData)
 }
 
@@ -357,6 +483,24 @@ func DeleteMovie(c *gin.Context) {
 		return
 	}
 
+	// The rows of its images go with the movie, their files are removed after
+	var mediaKeys []string
+	rows, err := db.Query("select storage_key from movie_media where movie_id = ?", id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		mediaKeys = append(mediaKeys, key)
+	}
+	rows.Close()
+
 	// Delete the movie
 	result, err := db.Exec("DELETE FROM movie WHERE id=?", id)
 	if err != nil {
@@ -376,10 +520,57 @@ func DeleteMovie(c *gin.Context) {
 	}
 
 	log.Printf("Movie with id %s has been deleted", id)
+	if len(mediaKeys) > 0 {
+		if storage, err := tool.NewStorage(); err != nil {
+			log.Println(err)
+		} else {
+			deleteStoredFiles(storage, mediaKeys)
+		}
+	}
 
 	responseData := models.Response{
 		Status:  200,
 		Message: "Movie deleted successfully",
 	}
+	invalidateCatalog()
+	c.JSON(http.StatusOK, responseData)
+}
+
+// GetGenres godoc
+// @Summary Get Genres
+// @Description Get the genres movies can be searched by
+// @Tags Guest
+// @Produce json
+// @Success 200 {object} models.GenresResponse
+// @Router /movies/genres [get]
+func GetGenres(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	rows, err := db.Query("select name from genre order by name")
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	genres := []string{}
+	for rows.Next() {
+		var genre string
+		if err := rows.Scan(&genre); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		genres = append(genres, genre)
+	}
+
+	responseData := models.GenresResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Genres retrieved successfully",
+		},
+		Genres: genres,
+	}
 	c.JSON(http.StatusOK, responseData)
 }
@@ -0,0 +1,303 @@
+package controller
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"tix-id/config"
+	"tix-id/middleware"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadOrder loads an order of the customer with its schedule, tickets and payment.
+func loadOrder(q queryer, orderId, customerId int) (models.Order, error) {
+	var order models.Order
+	var scheduleId int
+	var provider, chargeId, promoCode sql.NullString
+	err := q.QueryRow("select o.id, o.schedule_id, p.id, p.amount, p.currency, p.discount, p.points_discount, p.gift_card_amount, (select coalesce(-sum(points), 0) from point_ledger where payment_id = p.id and type = 'redeem'), p.payment_status, p.provider, p.charge_id, pr.code from orders o join payment p on p.id = o.payment_id left join promotion_usage u on u.payment_id = p.id left join promotion pr on pr.id = u.promotion_id where o.id = ? and o.customer_id = ?", orderId, customerId).Scan(&order.ID, &scheduleId, &order.Payment.ID, &order.Payment.Amount, &order.Payment.Currency, &order.Payment.Discount, &order.Payment.PointsDiscount, &order.Payment.GiftCardAmount, &order.Payment.PointsUsed, &order.Payment.Status, &provider, &chargeId, &promoCode)
+	if err != nil {
+		return order, err
+	}
+	order.Payment.Provider = provider.String
+	order.Payment.ChargeID = chargeId.String
+	order.Payment.PromoCode = promoCode.String
+	order.Payment.Breakdown, err = loadPriceBreakdown(q, order.Payment.ID)
+	if err != nil {
+		return order, err
+	}
+	order.Amount = order.Payment.Amount
+
+	order.Schedule, err = getScheduleTicket(q, scheduleId)
+	if err != nil {
+		return order, err
+	}
+
+	rows, err := q.Query("select tc.id, se.id, se.row, se.seat_number from ticket tc join seat se on se.id = tc.seat_id where tc.order_id = ? order by tc.id", order.ID)
+	if err != nil {
+		return order, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ticket models.Ticket
+		if err := rows.Scan(&ticket.ID, &ticket.Seat.ID, &ticket.Seat.Row, &ticket.Seat.Number); err != nil {
+			return order, err
+		}
+		ticket.Schedule = models.ScheduleTicket{ID: scheduleId}
+		ticket.Payment = order.Payment
+		order.Tickets = append(order.Tickets, ticket)
+	}
+	return order, rows.Err()
+}
+
+// CreateOrder godoc
+// @Summary Create Order
+// @Description Book several seats of one schedule under a single payment, all or nothing
+// @Tags Customer
+// @Accept json
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param body body models.OrderRequest true "Order detail"
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried"
+// @Success 200 {object} models.OrderResponse
+// @Router /customer/{customerId}/orders [post]
+func CreateOrder(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	var request models.OrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := getScheduleTicket(db, request.ScheduleID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Schledule is not found"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := confirmAge(schedule.Movie, request.AgeConfirmed); err != nil {
+		c.JSON(http.StatusForbidden, models.Response{Status: 403, Message: err.Error()})
+		return
+	}
+
+	seatIds := uniqueSeatIds(request.SeatIDs)
+	redisClient := tool.NewRedisClient()
+	defer redisClient.Close()
+	heldPrices, err := checkSeatHolds(redisClient, int(customerId), schedule.ID, seatIds, request.HoldToken)
+	if err != nil {
+		if err == errSeatHeld {
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: err.Error()})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	order, err := bookSeats(tx, int(customerId), schedule, seatIds, heldPrices, request.Accessible, bookingDiscounts{PromoCode: request.PromoCode, Points: request.Points, GiftCardCode: request.GiftCard})
+	if err != nil {
+		switch err {
+		case errNoSeats:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errSeatNotFound:
+			c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: err.Error()})
+		case errSeatTaken, errSeatBlocked, errWheelchairReserved:
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: err.Error()})
+		case errCompanionAlone, errPromoNotFound, errPromoNotValid, errPromoNotApplicable, errPromoMinSeats, errPromoUsedUp, tool.ErrNotEnoughPoints, errGiftCardNotFound, errGiftCardEmpty, errCurrencyMismatch:
+			c.JSON(http.StatusBadRequest, models.Response{Status: 400, Message: err.Error()})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the seats are booked now, so they don't need the hold anymore
+	if request.HoldToken != "" {
+		for _, seatId := range seatIds {
+			if err := tool.ReleaseHeldSeat(redisClient, request.HoldToken, schedule.ID, seatId); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	responseData := models.OrderResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Order created successfully",
+		},
+		Order: order,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// GetOrder godoc
+// @Summary Get Order
+// @Description Get an order with all of its tickets
+// @Tags Customer
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param orderId path int true "Order ID"
+// @Success 200 {object} models.OrderResponse
+// @Router /customer/{customerId}/orders/{orderId} [get]
+func GetOrder(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	orderId, err := strconv.Atoi(c.Param("orderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := loadOrder(db, orderId, int(customerId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response := models.Response{
+				Status:  404,
+				Message: "the order is not found!",
+			}
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.OrderResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Order retrieved successfully",
+		},
+		Order: order,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// ConfirmOrderPayment godoc
+// @Summary Confirm Order Payment
+// @Description Charge the single payment of an order through the payment provider. One confirmation email is sent once the payment completes.
+// @Tags Customer
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param orderId path int true "Order ID"
+// @Param method query string false "wallet to pay from the customer wallet, otherwise the payment provider is charged"
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried"
+// @Success 200 {object} models.OrderResponse
+// @Router /customer/{customerId}/orders/{orderId}/payment [post]
+func ConfirmOrderPayment(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	orderId, err := strconv.Atoi(c.Param("orderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := loadOrder(db, orderId, int(customerId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response := models.Response{
+				Status:  404,
+				Message: "the order is not found!",
+			}
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payment, err := payPayment(db, order.Payment.ID, c.Query("method"))
+	if err != nil {
+		switch err {
+		case errPaymentNotPending:
+			response := models.Response{
+				Status:  404,
+				Message: "the order is paid or expired",
+			}
+			c.JSON(http.StatusNotFound, response)
+		case errPaymentDeclined, errInsufficientBalance, errCurrencyMismatch:
+			c.JSON(http.StatusPaymentRequired, models.Response{Status: 402, Message: err.Error()})
+		case errPaymentProcessing:
+			c.JSON(http.StatusAccepted, models.Response{Status: 202, Message: err.Error()})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	order.Payment = payment
+	for i := range order.Tickets {
+		order.Tickets[i].Payment = order.Payment
+	}
+
+	responseData := models.OrderResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Payment Confirm successfully",
+		},
+		Order: order,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
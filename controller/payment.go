@@ -0,0 +1,412 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"tix-id/models"
+	"tix-id/tool"
+)
+
+var (
+	errPaymentNotPending = errors.New("the payment is not pending")
+	errPaymentDeclined   = errors.New("the payment was declined")
+	errPaymentProcessing = errors.New("the payment is still being processed, it will be confirmed shortly")
+	errCurrencyMismatch  = errors.New("the payment is in another currency")
+)
+
+// payPayment pays a pending payment from the customer wallet, or through the
+// payment provider for any other method.
+func payPayment(db *sql.DB, paymentId int, method string) (models.Payment, error) {
+	payment := models.Payment{ID: paymentId}
+	if err := db.QueryRow("select amount, currency from payment where id = ?", paymentId).Scan(&payment.Amount, &payment.Currency); err != nil {
+		return payment, err
+	}
+	if payment.Amount <= 0 {
+		return settleFreePayment(db, payment)
+	}
+	if method == tool.WalletProvider {
+		return payWithWallet(db, paymentId)
+	}
+	provider, err := tool.NewPaymentProvider()
+	if err != nil {
+		return payment, err
+	}
+	return chargePayment(db, provider, paymentId)
+}
+
+// settleFreePayment completes a payment that discounts, points and gift
+// cards already cover in full, without charging anything.
+func settleFreePayment(db *sql.DB, payment models.Payment) (models.Payment, error) {
+	paymentId := payment.ID
+	tx, err := db.Begin()
+	if err != nil {
+		return payment, err
+	}
+	defer tx.Rollback()
+
+	moved, err := transitionPayment(tx, paymentId, models.Completed)
+	if err != nil {
+		return payment, err
+	}
+	if !moved {
+		return payment, errPaymentNotPending
+	}
+	if err := completePayment(tx, paymentId, time.Now()); err != nil {
+		return payment, err
+	}
+	if err := tx.Commit(); err != nil {
+		return payment, err
+	}
+	payment.Status = models.Completed
+
+	if err := onPaymentCompleted(db, paymentId); err != nil {
+		log.Println(err)
+	}
+	return payment, nil
+}
+
+// chargePayment charges a pending payment through the payment provider and
+// records the outcome. A payment that already has a charge, e.g. after a
+// timeout, is captured again instead of being charged twice.
+func chargePayment(db *sql.DB, provider tool.PaymentProvider, paymentId int) (models.Payment, error) {
+	payment := models.Payment{ID: paymentId, Provider: provider.Name()}
+	var chargeId sql.NullString
+	err := db.QueryRow("select amount, currency, payment_status, charge_id from payment where id = ?", paymentId).Scan(&payment.Amount, &payment.Currency, &payment.Status, &chargeId)
+	if err != nil {
+		return payment, err
+	}
+	if payment.Status != models.Pending {
+		return payment, errPaymentNotPending
+	}
+
+	if !chargeId.Valid {
+		charge, err := provider.CreateCharge(models.ChargeRequest{
+			PaymentID:   paymentId,
+			Amount:      payment.Amount,
+			Currency:    payment.Currency,
+			Description: fmt.Sprintf("TIX-ID payment #%d", paymentId),
+			CallbackURL: os.Getenv("PAYMENT_WEBHOOK_URL"),
+		})
+		if err != nil {
+			return payment, err
+		}
+		if _, err := db.Exec("update payment set provider = ?, charge_id = ? where id = ? and charge_id is null and payment_status = ?", provider.Name(), charge.ID, paymentId, models.Pending); err != nil {
+			return payment, err
+		}
+		// a concurrent request may have created its charge first, that one
+		// wins, and a payment paid from the wallet meanwhile keeps no charge,
+		// so the new one is never captured
+		if err := db.QueryRow("select charge_id from payment where id = ?", paymentId).Scan(&chargeId); err != nil {
+			return payment, err
+		}
+		if !chargeId.Valid {
+			return payment, errPaymentNotPending
+		}
+	}
+	payment.ChargeID = chargeId.String
+
+	charge, err := provider.Capture(payment.ChargeID)
+	payment.RedirectURL = charge.RedirectURL
+	if err == tool.ErrProviderTimeout {
+		return payment, errPaymentProcessing
+	}
+	if err != nil {
+		return payment, err
+	}
+
+	payment.Status, err = applyCharge(db, provider, paymentId, charge)
+	if err != nil {
+		return payment, err
+	}
+	switch payment.Status {
+	case models.Failed:
+		return payment, errPaymentDeclined
+	case models.Pending:
+		return payment, errPaymentProcessing
+	}
+	return payment, nil
+}
+
+// paymentTransitions lists, for every payment status, the statuses a payment
+// may reach it from. A payment only moves forward, never back.
+var paymentTransitions = map[models.PaymentStatus][]models.PaymentStatus{
+	models.Completed: {models.Pending},
+	models.Failed:    {models.Pending},
+	models.Cancelled: {models.Pending},
+	models.Refunded:  {models.Completed},
+}
+
+// transitionPayment moves the payment to the status when that is allowed from
+// its current status, and reports whether it moved.
+func transitionPayment(q queryer, paymentId int, to models.PaymentStatus) (bool, error) {
+	from := paymentTransitions[to]
+	if len(from) == 0 {
+		return false, nil
+	}
+	args := []interface{}{to, paymentId}
+	for _, status := range from {
+		args = append(args, status)
+	}
+	res, err := q.Exec("update payment set payment_status = ? where id = ? and payment_status in ("+placeholders(len(from))+")", args...)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// completePayment books what a payment pays for, in the transaction that
+// completes it: the wallet credit of a top-up, the activation of a bought gift
+// card, or the points of a booking.
+func completePayment(tx *sql.Tx, paymentId int, now time.Time) error {
+	var customerId int
+	var amount models.Money
+	err := tx.QueryRow("select w.customer_id, p.amount from wallet_topup w join payment p on p.id = w.payment_id where w.payment_id = ?", paymentId).Scan(&customerId, &amount)
+	if err == nil {
+		return creditTopUp(tx, customerId, paymentId, amount)
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	res, err := tx.Exec("update gift_card set status = 'active' where payment_id = ? and status = 'pending'", paymentId)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected > 0 {
+		return err
+	}
+	return tool.EarnPoints(tx, paymentId, tool.LoadPointsPolicy(), now)
+}
+
+// applyCharge moves the payment to the state of its charge and returns the
+// resulting payment status. Only the request that actually completes the
+// payment sends the confirmation email. Money captured for a payment that
+// expired in the meantime is refunded straight away, since its seats are
+// already released.
+func applyCharge(db *sql.DB, provider tool.PaymentProvider, paymentId int, charge models.Charge) (models.PaymentStatus, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	status, moved, err := applyChargeTx(tx, paymentId, charge)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	afterCharge(db, provider, paymentId, charge, status, moved)
+	return status, nil
+}
+
+// SettleCharge applies a charge the payment provider reports for a pending
+// payment, for the expiry cron, which asks the provider before it fails a
+// payment that has a charge.
+func SettleCharge(db *sql.DB, provider tool.PaymentProvider, paymentId int, charge models.Charge) error {
+	_, err := applyCharge(db, provider, paymentId, charge)
+	return err
+}
+
+// applyChargeTx is applyCharge inside the caller's transaction, so more can
+// be written along with the new state. It reports whether the payment moved,
+// and afterCharge must run once the transaction is committed.
+func applyChargeTx(tx *sql.Tx, paymentId int, charge models.Charge) (models.PaymentStatus, bool, error) {
+	var target models.PaymentStatus
+	switch charge.Status {
+	case models.ChargeSucceeded:
+		target = models.Completed
+	case models.ChargeDeclined:
+		target = models.Failed
+	case models.ChargeRefunded:
+		target = models.Refunded
+	}
+
+	var status models.PaymentStatus
+	if err := tx.QueryRow("select payment_status from payment where id = ? for update", paymentId).Scan(&status); err != nil {
+		return "", false, err
+	}
+	moved, err := transitionPayment(tx, paymentId, target)
+	if err != nil || !moved {
+		return status, false, err
+	}
+	now := time.Now()
+	switch target {
+	case models.Completed:
+		err = completePayment(tx, paymentId, now)
+	case models.Failed:
+		if _, err = tx.Exec("update ticket set active = NULL where payment_id = ?", paymentId); err == nil {
+			err = tool.RestorePaymentCredits(tx, paymentId, now)
+		}
+	case models.Refunded:
+		if err = refundTickets(tx, paymentId, now); err != nil {
+			break
+		}
+		if _, err = tx.Exec("update ticket set active = NULL, cancelled_at = ? where payment_id = ? and active = 1", now, paymentId); err == nil {
+			err = tool.ReverseEarnedPoints(tx, paymentId, 1, now)
+		}
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return target, true, nil
+}
+
+// afterCharge sends the confirmation of a payment the charge completed, and
+// refunds a charge that succeeded for a payment that can no longer be paid.
+func afterCharge(db *sql.DB, provider tool.PaymentProvider, paymentId int, charge models.Charge, status models.PaymentStatus, moved bool) {
+	if moved && status == models.Completed {
+		if err := onPaymentCompleted(db, paymentId); err != nil {
+			log.Println(err)
+		}
+	}
+	if charge.Status == models.ChargeSucceeded && (status == models.Failed || status == models.Cancelled) {
+		if _, err := provider.Refund(charge.ID, charge.Amount-charge.RefundedAmount); err != nil && err != tool.ErrChargeNotRefundable {
+			log.Println(err)
+		}
+	}
+}
+
+// refundTickets records a full refund for every active ticket of a payment
+// that the provider refunded, so they show up like cancelled tickets. Each
+// ticket gets its share of what was not refunded yet.
+func refundTickets(tx *sql.Tx, paymentId int, now time.Time) error {
+	var amount, allTotal, refunded models.Money
+	var currency models.Currency
+	err := tx.QueryRow("select p.amount, p.currency, coalesce((select sum(price) from ticket where payment_id = p.id), 0), coalesce((select sum(amount) from refund where payment_id = p.id), 0) from payment p where p.id = ?", paymentId).Scan(&amount, &currency, &allTotal, &refunded)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.Query("select id, price from ticket where payment_id = ? and active = 1", paymentId)
+	if err != nil {
+		return err
+	}
+	type ticket struct {
+		id    int
+		price models.Money
+	}
+	var tickets []ticket
+	for rows.Next() {
+		var t ticket
+		if err := rows.Scan(&t.id, &t.price); err != nil {
+			rows.Close()
+			return err
+		}
+		tickets = append(tickets, t)
+	}
+	rows.Close()
+
+	for i, t := range tickets {
+		share, err := ticketShare(tx, paymentId, t.id, t.price, amount, allTotal, i == len(tickets)-1)
+		if err != nil {
+			return err
+		}
+		share = share.Min(amount - refunded)
+		if share < 0 {
+			share = 0
+		}
+		if _, err := tx.Exec("insert into refund (payment_id, ticket_id, amount, currency, percentage, created_at) values (?, ?, ?, ?, 100, ?)", paymentId, t.id, share, currency, now); err != nil {
+			return err
+		}
+		refunded += share
+	}
+	return nil
+}
+
+// refundCharge gives the refunded amount back the way the payment was made:
+// to the wallet, or through the payment provider. Payments made before the
+// provider existed have no charge to refund.
+func refundCharge(q queryer, paymentId int, amount models.Money) error {
+	if amount <= 0 {
+		return nil
+	}
+	var provider, chargeId sql.NullString
+	if err := q.QueryRow("select provider, charge_id from payment where id = ?", paymentId).Scan(&provider, &chargeId); err != nil {
+		return err
+	}
+	if provider.String == tool.WalletProvider {
+		return refundToWallet(q, paymentId, amount)
+	}
+	if !chargeId.Valid {
+		log.Printf("payment %d has no charge, refund of %s must be settled manually", paymentId, amount)
+		return nil
+	}
+	gateway, err := tool.NewPaymentProvider()
+	if err != nil {
+		return err
+	}
+	// the charge may have been partly refunded outside of cancellations, by
+	// the provider or a webhook, and is never refunded more than was captured
+	charge, err := gateway.QueryStatus(chargeId.String)
+	if err != nil {
+		return err
+	}
+	if refundable := charge.Amount - charge.RefundedAmount; amount > refundable {
+		log.Printf("payment %d has %s left to refund, not %s", paymentId, refundable, amount)
+		amount = refundable
+	}
+	if amount <= 0 {
+		return nil
+	}
+	_, err = gateway.Refund(chargeId.String, amount)
+	return err
+}
+
+// onPaymentCompleted runs once, right after a payment becomes completed, and
+// sends the payment confirmation: one email for the whole order, or the
+// ticket email when the payment covers a single ticket.
+func onPaymentCompleted(db *sql.DB, paymentId int) error {
+	var customerId, scheduleId, tickets int
+	var seat models.Seat
+	err := db.QueryRow("select tc.customer_id, tc.schedule_id, se.id, se.row, se.seat_number, (select count(*) from ticket where payment_id = tc.payment_id) from ticket tc join seat se on se.id = tc.seat_id where tc.payment_id = ? order by tc.id limit 1", paymentId).Scan(&customerId, &scheduleId, &seat.ID, &seat.Row, &seat.Number, &tickets)
+	if err == sql.ErrNoRows {
+		// payments without tickets are top-ups, or gift cards to send to their recipient
+		return sendGiftCard(db, paymentId)
+	} else if err != nil {
+		return err
+	}
+
+	var customer models.Customer
+	if err := db.QueryRow("SELECT name, email FROM customer WHERE id = ?", customerId).Scan(&customer.Name, &customer.Email); err != nil {
+		return err
+	}
+
+	var content string
+	if tickets > 1 {
+		var orderId int
+		if err := db.QueryRow("select id from orders where payment_id = ?", paymentId).Scan(&orderId); err != nil {
+			return err
+		}
+		order, err := loadOrder(db, orderId, customerId)
+		if err != nil {
+			return err
+		}
+		content = tool.GenerateOrderPaymentEmail(customer, order)
+	} else {
+		payment := models.Payment{ID: paymentId}
+		var provider, promoCode sql.NullString
+		if err := db.QueryRow("select p.amount, p.currency, p.discount, p.points_discount, p.gift_card_amount, (select coalesce(-sum(points), 0) from point_ledger where payment_id = p.id and type = 'redeem'), p.payment_status, p.provider, pr.code from payment p left join promotion_usage u on u.payment_id = p.id left join promotion pr on pr.id = u.promotion_id where p.id = ?", paymentId).Scan(&payment.Amount, &payment.Currency, &payment.Discount, &payment.PointsDiscount, &payment.GiftCardAmount, &payment.PointsUsed, &payment.Status, &provider, &promoCode); err != nil {
+			return err
+		}
+		payment.Provider = provider.String
+		payment.PromoCode = promoCode.String
+		breakdown, err := loadPriceBreakdown(db, paymentId)
+		if err != nil {
+			return err
+		}
+		payment.Breakdown = breakdown
+		schedule, err := getScheduleTicket(db, scheduleId)
+		if err != nil {
+			return err
+		}
+		schedule.Seat = &seat
+		content = tool.GeneratePaymentEmail(customer, payment, schedule)
+	}
+	go tool.SendEmail(content, customer.Email, "[TIX-ID] Payment Successful")
+	return nil
+}
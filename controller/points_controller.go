@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"tix-id/config"
+	"tix-id/middleware"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPoints godoc
+// @Summary Get Points
+// @Description Get the points balance and the points history of the customer, newest first
+// @Tags Customer
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param limit query int false "Entries per page, 20 by default"
+// @Param offset query int false "Entries to skip"
+// @Success 200 {object} models.PointHistoryResponse
+// @Router /customer/{customerId}/points [get]
+func GetPoints(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	history := models.PointHistory{Entries: []models.PointEntry{}, Paging: models.Paging{Limit: 20}}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= 100 {
+		history.Paging.Limit = limit
+	}
+	if offset, err := strconv.Atoi(c.Query("offset")); err == nil && offset > 0 {
+		history.Paging.Offset = offset
+	}
+
+	history.Balance, err = tool.PointBalance(db, int(customerId), time.Now())
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.QueryRow("select count(*) from point_ledger where customer_id = ?", customerId).Scan(&history.Paging.Total); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := db.Query("select id, payment_id, type, points, description, expires_at, created_at from point_ledger where customer_id = ? order by id desc limit ? offset ?", customerId, history.Paging.Limit, history.Paging.Offset)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entry models.PointEntry
+		var paymentId sql.NullInt64
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&entry.ID, &paymentId, &entry.Type, &entry.Points, &entry.Description, &expiresAt, &entry.CreatedAt); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if paymentId.Valid {
+			id := int(paymentId.Int64)
+			entry.PaymentID = &id
+		}
+		if expiresAt.Valid {
+			entry.ExpiresAt = &expiresAt.Time
+		}
+		history.Entries = append(history.Entries, entry)
+	}
+
+	responseData := models.PointHistoryResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Points retrieved successfully",
+		},
+		History: history,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
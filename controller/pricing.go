@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"database/sql"
+	"tix-id/models"
+)
+
+// schedulePrices returns the flat price of a schedule and its price per seat type.
+func schedulePrices(q queryer, scheduleId int) (models.Money, map[models.SeatType]models.Money, error) {
+	var base models.Money
+	prices := map[models.SeatType]models.Money{}
+	if err := q.QueryRow("select price from schedule where id = ?", scheduleId).Scan(&base); err != nil {
+		return base, prices, err
+	}
+
+	rows, err := q.Query("select seat_type, price from schedule_price where schedule_id = ?", scheduleId)
+	if err != nil {
+		return base, prices, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seatType models.SeatType
+		var price models.Money
+		if err := rows.Scan(&seatType, &price); err != nil {
+			return base, prices, err
+		}
+		prices[seatType] = price
+	}
+	return base, prices, rows.Err()
+}
+
+// seatPrice returns the price of a seat type, falling back to the flat
+// schedule price when the type has no price of its own.
+func seatPrice(base models.Money, prices map[models.SeatType]models.Money, seatType models.SeatType) models.Money {
+	if price, ok := prices[seatType]; ok {
+		return price
+	}
+	return base
+}
+
+// saveSchedulePrices replaces the price matrix of a schedule.
+func saveSchedulePrices(q queryer, scheduleId int, prices map[models.SeatType]models.Money) error {
+	if _, err := q.Exec("delete from schedule_price where schedule_id = ?", scheduleId); err != nil {
+		return err
+	}
+	for seatType, price := range prices {
+		if _, err := q.Exec("insert into schedule_price (schedule_id, seat_type, price) values (?, ?, ?)", scheduleId, seatType, price); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validSchedulePrices checks every seat type of a price matrix.
+func validSchedulePrices(prices map[models.SeatType]models.Money) bool {
+	for seatType, price := range prices {
+		if !seatType.Valid() || price < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// scheduleJurisdiction returns the tax jurisdiction of the branch showing the schedule.
+func scheduleJurisdiction(q queryer, scheduleId int) (models.TaxJurisdiction, error) {
+	var jurisdiction models.TaxJurisdiction
+	err := q.QueryRow("select j.id, j.name, j.tax_rate, j.service_fee from schedule s join theatre t on t.id = s.theatre_id join branch b on b.id = t.branch_id join tax_jurisdiction j on j.id = b.tax_jurisdiction_id where s.id = ?", scheduleId).Scan(&jurisdiction.ID, &jurisdiction.Name, &jurisdiction.TaxRate, &jurisdiction.ServiceFee)
+	return jurisdiction, err
+}
+
+// priceBreakdown adds the service fee per seat and the entertainment tax of
+// the jurisdiction to the seat prices, less the promo discount.
+func priceBreakdown(jurisdiction models.TaxJurisdiction, seatPrices []models.Money, discount models.Money) models.PriceBreakdown {
+	breakdown := models.PriceBreakdown{TaxRate: jurisdiction.TaxRate, Jurisdiction: jurisdiction.Name}
+	for _, price := range seatPrices {
+		breakdown.BasePrice += price
+	}
+	breakdown.Discount = discount.Min(breakdown.BasePrice)
+	breakdown.ServiceFee = jurisdiction.ServiceFee.Mul(len(seatPrices))
+	breakdown.Tax = (breakdown.BasePrice - breakdown.Discount).Percent(jurisdiction.TaxRate)
+	breakdown.Total = breakdown.BasePrice - breakdown.Discount + breakdown.ServiceFee + breakdown.Tax
+	return breakdown
+}
+
+// withoutSeat is the breakdown once a seat of the price is cancelled out of
+// the active ones, as if it had been booked without it: the seat's part of
+// the promo discount and its service fee come off, and the tax is computed
+// again.
+func withoutSeat(breakdown models.PriceBreakdown, price, activeTotal models.Money, activeCount int) models.PriceBreakdown {
+	breakdown.Discount -= breakdown.Discount.Share(price, activeTotal)
+	breakdown.BasePrice -= price
+	breakdown.Discount = breakdown.Discount.Min(breakdown.BasePrice)
+	if activeCount > 0 {
+		breakdown.ServiceFee -= breakdown.ServiceFee.Share(1, models.Money(activeCount))
+	}
+	breakdown.Tax = (breakdown.BasePrice - breakdown.Discount).Percent(breakdown.TaxRate)
+	breakdown.Total = breakdown.BasePrice - breakdown.Discount + breakdown.ServiceFee + breakdown.Tax
+	return breakdown
+}
+
+// loadPriceBreakdown returns the breakdown saved with a payment, or nil when
+// the payment isn't for a booking.
+func loadPriceBreakdown(q queryer, paymentId int) (*models.PriceBreakdown, error) {
+	var breakdown models.PriceBreakdown
+	var basePrice sql.NullString
+	var jurisdiction sql.NullString
+	err := q.QueryRow("select base_price, discount, service_fee, tax, tax_rate, tax_jurisdiction from payment where id = ?", paymentId).Scan(&basePrice, &breakdown.Discount, &breakdown.ServiceFee, &breakdown.Tax, &breakdown.TaxRate, &jurisdiction)
+	if err != nil || !basePrice.Valid {
+		return nil, err
+	}
+	if err := breakdown.BasePrice.Scan(basePrice.String); err != nil {
+		return nil, err
+	}
+	breakdown.Jurisdiction = jurisdiction.String
+	breakdown.Total = breakdown.BasePrice - breakdown.Discount + breakdown.ServiceFee + breakdown.Tax
+	return &breakdown, nil
+}
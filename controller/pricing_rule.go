@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"tix-id/models"
+)
+
+var errPricingRuleInvalid = errors.New("a pricing rule needs a name, a valid adjustment and valid conditions")
+
+const pricingRuleColumns = "id, name, priority, day_type, time_format(start_time, '%H:%i'), time_format(end_time, '%H:%i'), format, min_occupancy, adjustment_type, percentage, amount, active"
+
+// pricingContext is what the pricing rules look at when pricing a schedule.
+type pricingContext struct {
+	dayType   models.DayType
+	clock     string
+	format    models.Format
+	occupancy float64
+}
+
+// schedulePricing prices the seats of a schedule: the price of the seat type,
+// adjusted by every pricing rule that applies.
+type schedulePricing struct {
+	base    models.Money
+	prices  map[models.SeatType]models.Money
+	rules   []models.PricingRule
+	context pricingContext
+}
+
+func scanPricingRule(row scanner) (models.PricingRule, error) {
+	var rule models.PricingRule
+	var dayType, startTime, endTime, format sql.NullString
+	var minOccupancy sql.NullFloat64
+	err := row.Scan(&rule.ID, &rule.Name, &rule.Priority, &dayType, &startTime, &endTime, &format, &minOccupancy, &rule.AdjustmentType, &rule.Percentage, &rule.Amount, &rule.Active)
+	if err != nil {
+		return rule, err
+	}
+	if dayType.Valid {
+		t := models.DayType(dayType.String)
+		rule.DayType = &t
+	}
+	if startTime.Valid {
+		rule.StartTime = &startTime.String
+	}
+	if endTime.Valid {
+		rule.EndTime = &endTime.String
+	}
+	if format.Valid {
+		f := models.Format(format.String)
+		rule.Format = &f
+	}
+	if minOccupancy.Valid {
+		rule.MinOccupancy = &minOccupancy.Float64
+	}
+	return rule, nil
+}
+
+func validClock(clock *string) bool {
+	if clock == nil {
+		return true
+	}
+	_, err := time.Parse("15:04", *clock)
+	return err == nil
+}
+
+func validPricingRule(rule models.PricingRule) bool {
+	if strings.TrimSpace(rule.Name) == "" || !rule.AdjustmentType.Valid() {
+		return false
+	}
+	switch rule.AdjustmentType {
+	case models.PercentageAdjustment:
+		if rule.Amount != 0 || rule.Percentage <= -100 {
+			return false
+		}
+	case models.FixedAdjustment:
+		if rule.Percentage != 0 {
+			return false
+		}
+	}
+	if rule.DayType != nil && !rule.DayType.Valid() {
+		return false
+	}
+	if rule.Format != nil {
+		format, ok := models.ParseFormat(string(*rule.Format))
+		if !ok {
+			return false
+		}
+		*rule.Format = format
+	}
+	// a time slot needs both ends
+	if (rule.StartTime == nil) != (rule.EndTime == nil) || !validClock(rule.StartTime) || !validClock(rule.EndTime) {
+		return false
+	}
+	return rule.MinOccupancy == nil || (*rule.MinOccupancy >= 0 && *rule.MinOccupancy <= 100)
+}
+
+// activePricingRules returns the active rules in the order they are applied.
+func activePricingRules(q queryer) ([]models.PricingRule, error) {
+	rows, err := q.Query("select " + pricingRuleColumns + " from pricing_rule where active = 1 order by priority, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules []models.PricingRule
+	for rows.Next() {
+		rule, err := scanPricingRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// loadPricingContext works out the kind of day, the time slot, the format
+// and the occupancy of a schedule.
+func loadPricingContext(q queryer, scheduleId int) (pricingContext, error) {
+	var context pricingContext
+	var showTime time.Time
+	var format sql.NullString
+	var seats, sold, holiday int
+	err := q.QueryRow("select s.show_time, s.format, (select count(*) from seat where schedule_id = s.id and blocked = 0), (select count(*) from ticket where schedule_id = s.id and active = 1), (select count(*) from public_holiday where date = date(s.show_time)) from schedule s where s.id = ?", scheduleId).Scan(&showTime, &format, &seats, &sold, &holiday)
+	if err != nil {
+		return context, err
+	}
+	context.dayType = models.Weekday
+	if holiday > 0 {
+		context.dayType = models.Holiday
+	} else if day := showTime.Weekday(); day == time.Saturday || day == time.Sunday {
+		context.dayType = models.Weekend
+	}
+	context.clock = showTime.Format("15:04")
+	context.format = models.Format(format.String)
+	if seats > 0 {
+		context.occupancy = float64(sold) * 100 / float64(seats)
+	}
+	return context, nil
+}
+
+// pricingRuleApplies checks every condition of the rule against the schedule.
+func pricingRuleApplies(rule models.PricingRule, context pricingContext) bool {
+	if rule.DayType != nil && *rule.DayType != context.dayType {
+		return false
+	}
+	if rule.StartTime != nil && rule.EndTime != nil {
+		start, end := *rule.StartTime, *rule.EndTime
+		if start <= end && (context.clock < start || context.clock >= end) {
+			return false
+		}
+		if start > end && context.clock < start && context.clock >= end {
+			return false
+		}
+	}
+	if rule.Format != nil && *rule.Format != context.format {
+		return false
+	}
+	return rule.MinOccupancy == nil || context.occupancy >= *rule.MinOccupancy
+}
+
+// adjustPrice applies the adjustment of a rule, never going below zero.
+func adjustPrice(rule models.PricingRule, price models.Money) models.Money {
+	switch rule.AdjustmentType {
+	case models.PercentageAdjustment:
+		price += price.Percent(rule.Percentage)
+	case models.FixedAdjustment:
+		price += rule.Amount
+	}
+	if price < 0 {
+		return 0
+	}
+	return price
+}
+
+// loadSchedulePricing loads what it takes to price the seats of a schedule at
+// this moment. Prices go up as the schedule fills, so it is loaded when seats
+// are held or booked.
+func loadSchedulePricing(q queryer, scheduleId int) (schedulePricing, error) {
+	var pricing schedulePricing
+	var err error
+	pricing.base, pricing.prices, err = schedulePrices(q, scheduleId)
+	if err != nil {
+		return pricing, err
+	}
+	pricing.rules, err = activePricingRules(q)
+	if err != nil {
+		return pricing, err
+	}
+	pricing.context, err = loadPricingContext(q, scheduleId)
+	return pricing, err
+}
+
+// seatPrice returns the price of a seat type with every applying rule.
+func (p schedulePricing) seatPrice(seatType models.SeatType) models.Money {
+	price := seatPrice(p.base, p.prices, seatType)
+	for _, rule := range p.rules {
+		if pricingRuleApplies(rule, p.context) {
+			price = adjustPrice(rule, price)
+		}
+	}
+	return price
+}
@@ -0,0 +1,418 @@
+package controller
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"tix-id/config"
+	"tix-id/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+)
+
+// pricingRuleArgs returns the column values of a rule, in the order used by
+// CreatePricingRule and UpdatePricingRule.
+func pricingRuleArgs(rule models.PricingRule) []interface{} {
+	return []interface{}{strings.TrimSpace(rule.Name), rule.Priority, rule.DayType, rule.StartTime, rule.EndTime, rule.Format, rule.MinOccupancy, rule.AdjustmentType, rule.Percentage, rule.Amount, rule.Active}
+}
+
+// GetPricingRules godoc
+// @Summary Get Pricing Rules
+// @Description Get every pricing rule in the order they are applied
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.PricingRulesResponse
+// @Router /pricing-rules [get]
+func GetPricingRules(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	rows, err := db.Query("select " + pricingRuleColumns + " from pricing_rule order by priority, id")
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	rules := []models.PricingRule{}
+	for rows.Next() {
+		rule, err := scanPricingRule(rows)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		rules = append(rules, rule)
+	}
+
+	responseData := models.PricingRulesResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Pricing rules retrieved successfully",
+		},
+		Rules: rules,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// CreatePricingRule godoc
+// @Summary Create Pricing Rule
+// @Description Create a rule adjusting the seat prices of the schedules it matches. New rules are active.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.PricingRule true "Pricing rule"
+// @Success 200 {object} models.PricingRuleResponse
+// @Router /pricing-rules [post]
+func CreatePricingRule(c *gin.Context) {
+	var rule models.PricingRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.Active = true
+	if !validPricingRule(rule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errPricingRuleInvalid.Error()})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	res, err := db.Exec("insert into pricing_rule (name, priority, day_type, start_time, end_time, format, min_occupancy, adjustment_type, percentage, amount, active) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", pricingRuleArgs(rule)...)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rule, err = scanPricingRule(db.QueryRow("select "+pricingRuleColumns+" from pricing_rule where id = ?", id))
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.PricingRuleResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Pricing rule created successfully",
+		},
+		Rule: rule,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// UpdatePricingRule godoc
+// @Summary Update Pricing Rule
+// @Description Replace a pricing rule. Seats already held or booked keep their price.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param ruleId path int true "Pricing rule ID"
+// @Param body body models.PricingRule true "Pricing rule"
+// @Success 200 {object} models.PricingRuleResponse
+// @Router /pricing-rules/{ruleId} [put]
+func UpdatePricingRule(c *gin.Context) {
+	ruleId, err := strconv.Atoi(c.Param("ruleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pricing rule ID"})
+		return
+	}
+	var rule models.PricingRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validPricingRule(rule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errPricingRuleInvalid.Error()})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("select count(*) from pricing_rule where id = ?", ruleId).Scan(&count); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the pricing rule is not found!"})
+		return
+	}
+	if _, err := db.Exec("update pricing_rule set name = ?, priority = ?, day_type = ?, start_time = ?, end_time = ?, format = ?, min_occupancy = ?, adjustment_type = ?, percentage = ?, amount = ?, active = ? where id = ?", append(pricingRuleArgs(rule), ruleId)...); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rule, err = scanPricingRule(db.QueryRow("select "+pricingRuleColumns+" from pricing_rule where id = ?", ruleId))
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.PricingRuleResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Pricing rule updated successfully",
+		},
+		Rule: rule,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// DeletePricingRule godoc
+// @Summary Delete Pricing Rule
+// @Description Delete a pricing rule
+// @Tags Admin
+// @Produce json
+// @Param ruleId path int true "Pricing rule ID"
+// @Success 200 {object} models.Response
+// @Router /pricing-rules/{ruleId} [delete]
+func DeletePricingRule(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	res, err := db.Exec("delete from pricing_rule where id = ?", c.Param("ruleId"))
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the pricing rule is not found!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: 200, Message: "Pricing rule deleted successfully"})
+}
+
+// GetPublicHolidays godoc
+// @Summary Get Public Holidays
+// @Description Get the dates priced as holidays, from today on
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.PublicHolidaysResponse
+// @Router /holidays [get]
+func GetPublicHolidays(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	rows, err := db.Query("select date_format(date, '%Y-%m-%d'), name from public_holiday where date >= curdate() order by date")
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	holidays := []models.PublicHoliday{}
+	for rows.Next() {
+		var holiday models.PublicHoliday
+		if err := rows.Scan(&holiday.Date, &holiday.Name); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		holidays = append(holidays, holiday)
+	}
+
+	responseData := models.PublicHolidaysResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Public holidays retrieved successfully",
+		},
+		Holidays: holidays,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// CreatePublicHoliday godoc
+// @Summary Create Public Holiday
+// @Description Price a date (YYYY-MM-DD) as a public holiday
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.PublicHoliday true "Holiday"
+// @Success 200 {object} models.PublicHolidayResponse
+// @Router /holidays [post]
+func CreatePublicHoliday(c *gin.Context) {
+	var holiday models.PublicHoliday
+	if err := c.ShouldBindJSON(&holiday); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	holiday.Name = strings.TrimSpace(holiday.Name)
+	if _, err := time.Parse("2006-01-02", holiday.Date); err != nil || holiday.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a holiday needs a name and a date as YYYY-MM-DD"})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	if _, err := db.Exec("insert into public_holiday (date, name) values (?, ?)", holiday.Date, holiday.Name); err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: "the date is already a holiday"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.PublicHolidayResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Public holiday created successfully",
+		},
+		Holiday: holiday,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// DeletePublicHoliday godoc
+// @Summary Delete Public Holiday
+// @Description Price a date as a normal day again
+// @Tags Admin
+// @Produce json
+// @Param date path string true "Date (YYYY-MM-DD)"
+// @Success 200 {object} models.Response
+// @Router /holidays/{date} [delete]
+func DeletePublicHoliday(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	res, err := db.Exec("delete from public_holiday where date = ?", c.Param("date"))
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the holiday is not found!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: 200, Message: "Public holiday deleted successfully"})
+}
+
+// PreviewSchedulePricing godoc
+// @Summary Preview Schedule Pricing
+// @Description Show the price each pricing rule would produce for the seat types of a schedule, and the price with every applying rule. The occupancy can be overridden to see a surge before it happens.
+// @Tags Admin
+// @Produce json
+// @Param movieId path int true "Movie ID"
+// @Param scheduleId path int true "Schedule ID"
+// @Param occupancy query number false "Percentage of seats sold to price with"
+// @Success 200 {object} models.PricingPreviewResponse
+// @Router /movies/{movieId}/schedules/{scheduleId}/pricing [get]
+func PreviewSchedulePricing(c *gin.Context) {
+	scheduleId, err := strconv.Atoi(c.Param("scheduleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	pricing, err := loadSchedulePricing(db, scheduleId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the schedule is not found!"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if occupancy, err := strconv.ParseFloat(c.Query("occupancy"), 64); err == nil && occupancy >= 0 && occupancy <= 100 {
+		pricing.context.occupancy = occupancy
+	}
+
+	// every seat type of the schedule, and any type with a price of its own
+	rows, err := db.Query("select distinct seat_type from seat where schedule_id = ?", scheduleId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	seatTypes := map[models.SeatType]bool{}
+	for rows.Next() {
+		var seatType models.SeatType
+		if err := rows.Scan(&seatType); err != nil {
+			rows.Close()
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		seatTypes[seatType] = true
+	}
+	rows.Close()
+	for seatType := range pricing.prices {
+		seatTypes[seatType] = true
+	}
+	if len(seatTypes) == 0 {
+		seatTypes[models.Regular] = true
+	}
+
+	rules, err := db.Query("select " + pricingRuleColumns + " from pricing_rule order by priority, id")
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rules.Close()
+
+	preview := models.PricingPreview{
+		ScheduleID: scheduleId,
+		DayType:    pricing.context.dayType,
+		Format:     pricing.context.format,
+		Occupancy:  pricing.context.occupancy,
+		BasePrices: map[models.SeatType]models.Money{},
+		Rules:      []models.PricingRuleEffect{},
+		Prices:     map[models.SeatType]models.Money{},
+	}
+	for seatType := range seatTypes {
+		preview.BasePrices[seatType] = seatPrice(pricing.base, pricing.prices, seatType)
+		preview.Prices[seatType] = pricing.seatPrice(seatType)
+	}
+	for rules.Next() {
+		rule, err := scanPricingRule(rules)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		effect := models.PricingRuleEffect{
+			Rule:    rule,
+			Applies: rule.Active && pricingRuleApplies(rule, pricing.context),
+			Prices:  map[models.SeatType]models.Money{},
+		}
+		for seatType, base := range preview.BasePrices {
+			effect.Prices[seatType] = adjustPrice(rule, base)
+		}
+		preview.Rules = append(preview.Rules, effect)
+	}
+
+	responseData := models.PricingPreviewResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Schedule pricing previewed successfully",
+		},
+		Preview: preview,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"tix-id/models"
+)
+
+var (
+	errPromoNotFound      = errors.New("the promo code is not found")
+	errPromoNotValid      = errors.New("the promo code is not valid at this time")
+	errPromoNotApplicable = errors.New("the promo code doesn't apply to this schedule")
+	errPromoMinSeats      = errors.New("the promo code needs more seats")
+	errPromoUsedUp        = errors.New("the promo code has reached its usage limit")
+)
+
+var weekdays = map[string]bool{}
+
+func init() {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		weekdays[strings.ToLower(day.String())] = true
+	}
+}
+
+// validPromotion checks the rules of a promotion before it is saved.
+func validPromotion(promo *models.Promotion) error {
+	promo.Code = strings.ToUpper(strings.TrimSpace(promo.Code))
+	if promo.Code == "" {
+		return errors.New("the promo code is required")
+	}
+	if !promo.Type.Valid() {
+		return fmt.Errorf("unknown promotion type %q", promo.Type)
+	}
+	switch promo.Type {
+	case models.PercentageOff:
+		if promo.Percentage <= 0 || promo.Percentage > 100 || promo.Amount != 0 {
+			return errors.New("a percentage must be between 0 and 100, without an amount")
+		}
+	case models.FixedOff:
+		if promo.Amount <= 0 || promo.Percentage != 0 {
+			return errors.New("a fixed discount must be a positive amount, without a percentage")
+		}
+	default:
+		if promo.Percentage != 0 || promo.Amount != 0 {
+			return fmt.Errorf("a %s promotion has no percentage or amount", promo.Type)
+		}
+	}
+	if promo.Currency == "" {
+		promo.Currency = models.DefaultCurrency
+	}
+	if !promo.Currency.Valid() {
+		return fmt.Errorf("unsupported currency %q", promo.Currency)
+	}
+	if promo.MinSeats < 1 {
+		promo.MinSeats = 1
+	}
+	if promo.Type == models.BuyOneGetOne && promo.MinSeats < 2 {
+		promo.MinSeats = 2
+	}
+	for i, day := range promo.Days {
+		promo.Days[i] = strings.ToLower(day)
+		if !weekdays[promo.Days[i]] {
+			return fmt.Errorf("unknown day %q", day)
+		}
+	}
+	if (promo.UsageLimit != nil && *promo.UsageLimit < 1) || (promo.CustomerLimit != nil && *promo.CustomerLimit < 1) {
+		return errors.New("usage limits must be at least 1")
+	}
+	if promo.ValidFrom != nil && promo.ValidUntil != nil && !promo.ValidUntil.After(*promo.ValidFrom) {
+		return errors.New("validUntil must be after validFrom")
+	}
+	return nil
+}
+
+// loadPromotion loads a promotion with its movies, branches and usage count.
+// With forUpdate the promotion row stays locked until the transaction ends,
+// so concurrent checkouts can't both take the last use.
+func loadPromotion(q queryer, column string, value interface{}, forUpdate bool) (models.Promotion, error) {
+	var promo models.Promotion
+	var days sql.NullString
+	var usageLimit, customerLimit sql.NullInt64
+	var validFrom, validUntil sql.NullTime
+	query := "select id, code, description, type, percentage, amount, currency, min_seats, days, usage_limit, customer_limit, valid_from, valid_until, active from promotion where " + column + " = ?"
+	if forUpdate {
+		query += " for update"
+	}
+	err := q.QueryRow(query, value).Scan(&promo.ID, &promo.Code, &promo.Description, &promo.Type, &promo.Percentage, &promo.Amount, &promo.Currency, &promo.MinSeats, &days, &usageLimit, &customerLimit, &validFrom, &validUntil, &promo.Active)
+	if err != nil {
+		return promo, err
+	}
+	promo.Days = []string{}
+	if days.String != "" {
+		promo.Days = strings.Split(days.String, ",")
+	}
+	if usageLimit.Valid {
+		limit := int(usageLimit.Int64)
+		promo.UsageLimit = &limit
+	}
+	if customerLimit.Valid {
+		limit := int(customerLimit.Int64)
+		promo.CustomerLimit = &limit
+	}
+	if validFrom.Valid {
+		promo.ValidFrom = &validFrom.Time
+	}
+	if validUntil.Valid {
+		promo.ValidUntil = &validUntil.Time
+	}
+
+	if promo.MovieIDs, err = promotionTargets(q, "select movie_id from promotion_movie where promotion_id = ?", promo.ID); err != nil {
+		return promo, err
+	}
+	if promo.BranchIDs, err = promotionTargets(q, "select branch_id from promotion_branch where promotion_id = ?", promo.ID); err != nil {
+		return promo, err
+	}
+	promo.UsageCount, err = promotionUsage(q, promo.ID, 0)
+	return promo, err
+}
+
+func promotionTargets(q queryer, query string, promotionId int) ([]int, error) {
+	ids := []int{}
+	rows, err := q.Query(query, promotionId)
+	if err != nil {
+		return ids, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// promotionUsage counts the uses of the promotion whose payment is paid or
+// still pending, by one customer when customerId is set. Failed and cancelled
+// payments give their use back.
+func promotionUsage(q queryer, promotionId, customerId int) (int, error) {
+	query := "select count(*) from promotion_usage u join payment p on p.id = u.payment_id where u.promotion_id = ? and p.payment_status not in ('failed', 'cancelled')"
+	args := []interface{}{promotionId}
+	if customerId != 0 {
+		query += " and u.customer_id = ?"
+		args = append(args, customerId)
+	}
+	var count int
+	err := q.QueryRow(query, args...).Scan(&count)
+	return count, err
+}
+
+// savePromotionTargets replaces the movies and branches of the promotion.
+func savePromotionTargets(q queryer, promo models.Promotion) error {
+	if _, err := q.Exec("delete from promotion_movie where promotion_id = ?", promo.ID); err != nil {
+		return err
+	}
+	if _, err := q.Exec("delete from promotion_branch where promotion_id = ?", promo.ID); err != nil {
+		return err
+	}
+	for _, movieId := range promo.MovieIDs {
+		if _, err := q.Exec("insert into promotion_movie (promotion_id, movie_id) values (?, ?)", promo.ID, movieId); err != nil {
+			return err
+		}
+	}
+	for _, branchId := range promo.BranchIDs {
+		if _, err := q.Exec("insert into promotion_branch (promotion_id, branch_id) values (?, ?)", promo.ID, branchId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsInt(values []int, target int) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluatePromotion checks that the promotion applies to the seats of the
+// schedule and returns the discount on their prices.
+func evaluatePromotion(q queryer, promo models.Promotion, customerId int, schedule models.ScheduleTicket, prices []models.Money, now time.Time) (models.Money, error) {
+	if !promo.Active {
+		return 0, errPromoNotFound
+	}
+	if (promo.ValidFrom != nil && now.Before(*promo.ValidFrom)) || (promo.ValidUntil != nil && !now.Before(*promo.ValidUntil)) {
+		return 0, errPromoNotValid
+	}
+	if len(promo.MovieIDs) > 0 && (schedule.Movie == nil || !containsInt(promo.MovieIDs, schedule.Movie.ID)) {
+		return 0, errPromoNotApplicable
+	}
+	if len(promo.BranchIDs) > 0 && (schedule.Branch == nil || schedule.Branch.ID == nil || !containsInt(promo.BranchIDs, *schedule.Branch.ID)) {
+		return 0, errPromoNotApplicable
+	}
+	// the days are the days of the show, "Tuesday deals" are for Tuesday screenings
+	if len(promo.Days) > 0 && (schedule.Showtime == nil || !containsString(promo.Days, strings.ToLower(schedule.Showtime.Weekday().String()))) {
+		return 0, errPromoNotApplicable
+	}
+	if promo.Type == models.FixedOff && promo.Currency != schedule.Currency {
+		return 0, errPromoNotApplicable
+	}
+	if len(prices) < promo.MinSeats {
+		return 0, errPromoMinSeats
+	}
+
+	if promo.UsageLimit != nil && promo.UsageCount >= *promo.UsageLimit {
+		return 0, errPromoUsedUp
+	}
+	if promo.CustomerLimit != nil {
+		used, err := promotionUsage(q, promo.ID, customerId)
+		if err != nil {
+			return 0, err
+		}
+		if used >= *promo.CustomerLimit {
+			return 0, errPromoUsedUp
+		}
+	}
+	return promotionDiscount(promo, prices), nil
+}
+
+// promotionDiscount computes the discount, never more than the subtotal.
+func promotionDiscount(promo models.Promotion, prices []models.Money) models.Money {
+	var subtotal models.Money
+	for _, price := range prices {
+		subtotal += price
+	}
+
+	var discount models.Money
+	switch promo.Type {
+	case models.PercentageOff:
+		discount = subtotal.Percent(promo.Percentage)
+	case models.FixedOff:
+		discount = promo.Amount
+	case models.BuyOneGetOne:
+		// pair the seats from the most expensive down, the second of each pair is free
+		sorted := append([]models.Money(nil), prices...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+		for i := 1; i < len(sorted); i += 2 {
+			discount += sorted[i]
+		}
+	}
+	return discount.Min(subtotal)
+}
@@ -0,0 +1,411 @@
+package controller
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"tix-id/config"
+	"tix-id/middleware"
+	"tix-id/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+)
+
+// promotionArgs returns the column values of a promotion, in the order of
+// the promotion columns used by CreatePromotion and UpdatePromotion.
+func promotionArgs(promo models.Promotion) []interface{} {
+	days := sql.NullString{String: strings.Join(promo.Days, ","), Valid: len(promo.Days) > 0}
+	return []interface{}{promo.Code, promo.Description, promo.Type, promo.Percentage, promo.Amount, promo.Currency, promo.MinSeats, days, promo.UsageLimit, promo.CustomerLimit, promo.ValidFrom, promo.ValidUntil, promo.Active}
+}
+
+// CreatePromotion godoc
+// @Summary Create Promotion
+// @Description Create a promo code. New promotions are active.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.Promotion true "Promotion"
+// @Success 200 {object} models.PromotionResponse
+// @Router /promotions [post]
+func CreatePromotion(c *gin.Context) {
+	var promo models.Promotion
+	if err := c.ShouldBindJSON(&promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	promo.Active = true
+	if err := validPromotion(&promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO promotion (code, description, type, percentage, amount, currency, min_seats, days, usage_limit, customer_limit, valid_from, valid_until, active) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", promotionArgs(promo)...)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: "the promo code already exists"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	promo.ID = int(id)
+	if err := savePromotionTargets(tx, promo); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.PromotionResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Promotion created successfully",
+		},
+		Promotion: promo,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// GetPromotions godoc
+// @Summary Get Promotions
+// @Description Get all promo codes with their usage
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.PromotionsResponse
+// @Router /promotions [get]
+func GetPromotions(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id FROM promotion ORDER BY id DESC")
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	promotions := []models.Promotion{}
+	for _, id := range ids {
+		promo, err := loadPromotion(db, "id", id, false)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		promotions = append(promotions, promo)
+	}
+
+	responseData := models.PromotionsResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Promotions retrieved successfully",
+		},
+		Promotions: promotions,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// GetPromotion godoc
+// @Summary Get Promotion
+// @Description Get a promo code with its usage
+// @Tags Admin
+// @Produce json
+// @Param promotionId path int true "Promotion ID"
+// @Success 200 {object} models.PromotionResponse
+// @Router /promotions/{promotionId} [get]
+func GetPromotion(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	promotionId, err := strconv.Atoi(c.Param("promotionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return
+	}
+	promo, err := loadPromotion(db, "id", promotionId, false)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the promotion is not found!"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.PromotionResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Promotion retrieved successfully",
+		},
+		Promotion: promo,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// UpdatePromotion godoc
+// @Summary Update Promotion
+// @Description Replace the rules of a promo code
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param promotionId path int true "Promotion ID"
+// @Param body body models.Promotion true "Promotion"
+// @Success 200 {object} models.PromotionResponse
+// @Router /promotions/{promotionId} [put]
+func UpdatePromotion(c *gin.Context) {
+	promotionId, err := strconv.Atoi(c.Param("promotionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return
+	}
+	var promo models.Promotion
+	if err := c.ShouldBindJSON(&promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validPromotion(&promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	promo.ID = promotionId
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE promotion SET code = ?, description = ?, type = ?, percentage = ?, amount = ?, currency = ?, min_seats = ?, days = ?, usage_limit = ?, customer_limit = ?, valid_from = ?, valid_until = ?, active = ? WHERE id = ?", append(promotionArgs(promo), promo.ID)...)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: "the promo code already exists"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM promotion WHERE id = ?", promo.ID).Scan(&count); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the promotion is not found!"})
+		return
+	}
+	if err := savePromotionTargets(tx, promo); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	promo, err = loadPromotion(tx, "id", promo.ID, false)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	responseData := models.PromotionResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Promotion updated successfully",
+		},
+		Promotion: promo,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// DeletePromotion godoc
+// @Summary Deactivate Promotion
+// @Description Deactivate a promo code. It is kept for the payments that used it.
+// @Tags Admin
+// @Produce json
+// @Param promotionId path int true "Promotion ID"
+// @Success 200 {object} models.Response
+// @Router /promotions/{promotionId} [delete]
+func DeletePromotion(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	res, err := db.Exec("UPDATE promotion SET active = 0 WHERE id = ?", c.Param("promotionId"))
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the promotion is not found or already inactive"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: 200, Message: "Promotion deactivated successfully"})
+}
+
+// PreviewPromotion godoc
+// @Summary Preview Promo Code
+// @Description Check a promo code against the seats of a schedule and show the discount, without using the code
+// @Tags Customer
+// @Accept json
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param body body models.PromotionPreviewRequest true "Promo code and seats"
+// @Success 200 {object} models.PromotionPreviewResponse
+// @Router /customer/{customerId}/promotions/preview [post]
+func PreviewPromotion(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	var request models.PromotionPreviewRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seatIds := uniqueSeatIds(request.SeatIDs)
+	if len(seatIds) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errNoSeats.Error()})
+		return
+	}
+
+	schedule, err := getScheduleTicket(db, request.ScheduleID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Schledule is not found"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pricing, err := loadSchedulePricing(db, schedule.ID)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	args := []interface{}{schedule.ID}
+	for _, id := range seatIds {
+		args = append(args, id)
+	}
+	rows, err := db.Query("select seat_type from seat where schedule_id = ? and blocked = 0 and id in ("+placeholders(len(seatIds))+")", args...)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var seatPrices []models.Money
+	preview := models.PromotionPreview{Code: strings.ToUpper(strings.TrimSpace(request.Code))}
+	for rows.Next() {
+		var seatType models.SeatType
+		if err := rows.Scan(&seatType); err != nil {
+			rows.Close()
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		price := pricing.seatPrice(seatType)
+		seatPrices = append(seatPrices, price)
+		preview.Subtotal += price
+	}
+	rows.Close()
+	if len(seatPrices) != len(seatIds) {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: errSeatNotFound.Error()})
+		return
+	}
+
+	promo, err := loadPromotion(db, "code", preview.Code, false)
+	if err == nil {
+		preview.Discount, err = evaluatePromotion(db, promo, int(customerId), schedule, seatPrices, time.Now())
+	} else if err == sql.ErrNoRows {
+		err = errPromoNotFound
+	}
+	if err != nil {
+		switch err {
+		case errPromoNotFound, errPromoNotValid, errPromoNotApplicable, errPromoMinSeats, errPromoUsedUp:
+			c.JSON(http.StatusBadRequest, models.Response{Status: 400, Message: err.Error()})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	jurisdiction, err := scheduleJurisdiction(db, schedule.ID)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	breakdown := priceBreakdown(jurisdiction, seatPrices, preview.Discount)
+	preview.Total = breakdown.Total
+	preview.Breakdown = &breakdown
+
+	responseData := models.PromotionPreviewResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Promo code applies",
+		},
+		Preview: preview,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
@@ -0,0 +1,263 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"tix-id/models"
+)
+
+// maxCopyDays caps the range of schedules copied at once.
+const maxCopyDays = 31
+
+var (
+	errScheduleConflict = errors.New("the show time overlaps other schedules of the theatre")
+	errRunEnded         = errors.New("the run of the movie has ended by the new show time")
+)
+
+// scheduleConflicts returns the schedules of the theatre that overlap a show
+// of the movie at the show time. A show takes the theatre for the duration of
+// its movie plus the cleanup time of the theatre. The schedule being changed
+// is left out by excludeId.
+func scheduleConflicts(q queryer, theatreId, movieId int, showTime time.Time, excludeId int) ([]int, error) {
+	var duration, cleanup int
+	err := q.QueryRow("select m.duration, t.cleanup_minutes from movie m join theatre t on t.id = ? where m.id = ?", theatreId, movieId).Scan(&duration, &cleanup)
+	if err != nil {
+		return nil, err
+	}
+	endTime := showTime.Add(time.Duration(duration+cleanup) * time.Minute)
+
+	rows, err := q.Query("select s.id from schedule s join movie m on m.id = s.movie_id join theatre t on t.id = s.theatre_id where s.theatre_id = ? and s.id <> ? and s.show_time < ? and s.show_time + interval (m.duration + t.cleanup_minutes) minute > ? order by s.show_time", theatreId, excludeId, endTime, showTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var conflicts []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, id)
+	}
+	return conflicts, rows.Err()
+}
+
+// lockTheatre locks the theatre row until the transaction ends, so schedules
+// of the same theatre are checked for conflicts one at a time.
+func lockTheatre(q queryer, theatreId int) error {
+	var id int
+	return q.QueryRow("select id from theatre where id = ? for update", theatreId).Scan(&id)
+}
+
+// validSchedule checks the show time, format, prices and currency of a
+// schedule before it is created or updated.
+func validSchedule(schedule *models.Schedule) error {
+	if schedule.Showtime.IsZero() {
+		return errors.New("a schedule needs a show time")
+	}
+	format, ok := models.ParseFormat(string(schedule.Format))
+	if !ok {
+		return fmt.Errorf("unknown format %q", schedule.Format)
+	}
+	schedule.Format = format
+	if schedule.Price < 0 || !validSchedulePrices(schedule.Prices) {
+		return errors.New("invalid seat type prices")
+	}
+	if schedule.Currency == "" {
+		schedule.Currency = models.DefaultCurrency
+	}
+	if !schedule.Currency.Valid() {
+		return fmt.Errorf("unsupported currency %q", schedule.Currency)
+	}
+	return nil
+}
+
+// insertSchedule creates a schedule of the movie in the theatre, with the
+// seat layout of the theatre and its seat type prices. When the show time
+// overlaps other schedules nothing is created and those are returned
+// instead. The theatre must be locked by the caller.
+func insertSchedule(q queryer, schedule models.Schedule, movieId, theatreId int) (int, []int, error) {
+	if err := checkScheduleFormat(q, movieId, theatreId, schedule.Format); err != nil {
+		return 0, nil, err
+	}
+	conflicts, err := scheduleConflicts(q, theatreId, movieId, schedule.Showtime, 0)
+	if err != nil || len(conflicts) > 0 {
+		return 0, conflicts, err
+	}
+
+	if schedule.Currency == "" {
+		schedule.Currency = models.DefaultCurrency
+	}
+	res, err := q.Exec("INSERT INTO schedule (price, currency, format, show_time, movie_id, theatre_id) VALUES (?, ?, ?, ?, ?, ?)",
+		schedule.Price, schedule.Currency, schedule.Format, schedule.Showtime, movieId, theatreId)
+	if err != nil {
+		return 0, nil, err
+	}
+	scheduleId, err := res.LastInsertId()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// the schedule inherits the seat layout of its theatre
+	if err := copyTheatreLayout(q, int(scheduleId), theatreId); err != nil {
+		return 0, nil, err
+	}
+	if err := saveSchedulePrices(q, int(scheduleId), schedule.Prices); err != nil {
+		return 0, nil, err
+	}
+	return int(scheduleId), nil, nil
+}
+
+// cloneSchedule creates a copy of a schedule at another show time, with the
+// seat layout and prices of the original rather than those of the theatre,
+// so blocked seats stay blocked. Like insertSchedule it returns the overlapped
+// schedules instead when there are any, and the theatre must be locked.
+func cloneSchedule(q queryer, sourceId, movieId, theatreId int, showTime time.Time) (int, []int, error) {
+	conflicts, err := scheduleConflicts(q, theatreId, movieId, showTime, 0)
+	if err != nil || len(conflicts) > 0 {
+		return 0, conflicts, err
+	}
+
+	res, err := q.Exec("insert into schedule (price, currency, format, show_time, movie_id, theatre_id) select price, currency, format, ?, movie_id, theatre_id from schedule where id = ?", showTime, sourceId)
+	if err != nil {
+		return 0, nil, err
+	}
+	scheduleId, err := res.LastInsertId()
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := q.Exec("insert into seat (row, seat_number, position, seat_type, blocked, schedule_id) select row, seat_number, position, seat_type, blocked, ? from seat where schedule_id = ? order by row, position", scheduleId, sourceId); err != nil {
+		return 0, nil, err
+	}
+	if _, err := q.Exec("insert into schedule_price (schedule_id, seat_type, price) select ?, seat_type, price from schedule_price where schedule_id = ?", scheduleId, sourceId); err != nil {
+		return 0, nil, err
+	}
+	return int(scheduleId), nil, nil
+}
+
+// updateSchedule changes the movie, theatre, show time, format and prices of
+// a schedule. When the new show time overlaps other schedules nothing changes
+// and those are returned instead. The theatre must be locked by the caller.
+func updateSchedule(q queryer, scheduleId int, schedule models.Schedule, movieId, theatreId int) ([]int, error) {
+	if err := checkScheduleFormat(q, movieId, theatreId, schedule.Format); err != nil {
+		return nil, err
+	}
+	conflicts, err := scheduleConflicts(q, theatreId, movieId, schedule.Showtime, scheduleId)
+	if err != nil || len(conflicts) > 0 {
+		return conflicts, err
+	}
+
+	_, err = q.Exec("UPDATE schedule SET price = ?, currency = ?, format = ?, show_time = ?, movie_id = ?, theatre_id = ? WHERE id = ?",
+		schedule.Price, schedule.Currency, schedule.Format, schedule.Showtime, movieId, theatreId, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Prices != nil {
+		if err := saveSchedulePrices(q, scheduleId, schedule.Prices); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// validScheduleCopy checks the dates of a copy and returns the range of show
+// times to copy and how many days to shift them by.
+func validScheduleCopy(scheduleCopy models.ScheduleCopy) (from, to time.Time, days int, err error) {
+	from, err = time.ParseInLocation("2006-01-02", scheduleCopy.FromDate, showLocation)
+	if err != nil {
+		return from, to, 0, errors.New("fromDate must be YYYY-MM-DD")
+	}
+	to, err = time.ParseInLocation("2006-01-02", scheduleCopy.ToDate, showLocation)
+	if err != nil {
+		return from, to, 0, errors.New("toDate must be YYYY-MM-DD")
+	}
+	target, err := time.ParseInLocation("2006-01-02", scheduleCopy.TargetDate, showLocation)
+	if err != nil {
+		return from, to, 0, errors.New("targetDate must be YYYY-MM-DD")
+	}
+	if to.Before(from) || to.Sub(from) >= maxCopyDays*24*time.Hour {
+		return from, to, 0, fmt.Errorf("the date range must be forward and at most %d days", maxCopyDays)
+	}
+	days = int(math.Round(target.Sub(from).Hours() / 24))
+	if days == 0 {
+		return from, to, 0, errors.New("targetDate must differ from fromDate")
+	}
+	// the range ends after the last day
+	return from, to.AddDate(0, 0, 1), days, nil
+}
+
+// copySchedules clones the schedules of the branch, or of one theatre of it,
+// shown from from up to to, shifting their show times by days. Schedules of
+// movies whose run has ended by then, and those that would overlap another
+// schedule, are skipped and reported; the rest are cloned.
+func copySchedules(q queryer, branchId int, theatreId *int, from, to time.Time, days int) (models.ScheduleCopyResult, error) {
+	result := models.ScheduleCopyResult{Copied: []models.CopiedSchedule{}, Skipped: []models.CopiedSchedule{}}
+
+	query := "select s.id, s.movie_id, s.theatre_id, s.show_time, date_format(m.run_end_date, '%Y-%m-%d') from schedule s join movie m on m.id = s.movie_id join theatre t on t.id = s.theatre_id where t.branch_id = ? and s.show_time >= ? and s.show_time < ?"
+	args := []interface{}{branchId, from, to}
+	if theatreId != nil {
+		query += " and s.theatre_id = ?"
+		args = append(args, *theatreId)
+	}
+	rows, err := q.Query(query+" order by s.theatre_id, s.show_time", args...)
+	if err != nil {
+		return result, err
+	}
+	var sources []models.CopiedSchedule
+	var runEnds []*string
+	for rows.Next() {
+		var source models.CopiedSchedule
+		var runEnd *string
+		if err := rows.Scan(&source.SourceID, &source.MovieID, &source.TheatreID, &source.Showtime, &runEnd); err != nil {
+			rows.Close()
+			return result, err
+		}
+		sources = append(sources, source)
+		runEnds = append(runEnds, runEnd)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	// lock the theatres in order, so two copies can't deadlock each other
+	var theatres []int
+	locked := map[int]bool{}
+	for _, source := range sources {
+		if !locked[source.TheatreID] {
+			locked[source.TheatreID] = true
+			theatres = append(theatres, source.TheatreID)
+		}
+	}
+	sort.Ints(theatres)
+	for _, id := range theatres {
+		if err := lockTheatre(q, id); err != nil {
+			return result, err
+		}
+	}
+
+	for i, clone := range sources {
+		clone.Showtime = clone.Showtime.In(showLocation).AddDate(0, 0, days)
+		if runEnds[i] != nil && clone.Showtime.Format("2006-01-02") > *runEnds[i] {
+			clone.Reason = errRunEnded.Error()
+			result.Skipped = append(result.Skipped, clone)
+			continue
+		}
+		scheduleId, conflicts, err := cloneSchedule(q, clone.SourceID, clone.MovieID, clone.TheatreID, clone.Showtime)
+		if err != nil {
+			return result, err
+		}
+		if len(conflicts) > 0 {
+			clone.Reason = errScheduleConflict.Error()
+			clone.Conflicts = conflicts
+			result.Skipped = append(result.Skipped, clone)
+			continue
+		}
+		clone.ScheduleID = scheduleId
+		result.Copied = append(result.Copied, clone)
+	}
+	return result, nil
+}
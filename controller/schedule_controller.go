@@ -9,6 +9,7 @@ import (
 	"time"
 	"tix-id/config"
 	"tix-id/models"
+	"tix-id/tool"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,6 +19,7 @@ import (
 // @Description Get a schedule by movie_id and schedule_id.
 // @Tags Customer
 // @Param movieId path string true "movie id"
+// @Param format query string false "Only the schedules in this format, e.g. IMAX"
 // @Accept json
 // @Produce json
 // @Success 200 {object} models.MovieSchedulesResponse
@@ -64,8 +66,18 @@ func GetSchedules(c *gin.Context) {
 
 	// get Schedules
 	var schedules []models.Schedule
-	query := "select sc.id, sc.show_time, sc.price, t.id, t.name, b.id, b.name, b.address from schedule sc join theatre t on t.id = sc.theatre_id join branch b on b.id = t.branch_id where sc.movie_id = ?"
-	rows, err := db.Query(query, movie.ID)
+	query := "select sc.id, sc.show_time, sc.price, sc.currency, coalesce(sc.format, ''), t.id, t.name, b.id, b.name, b.address from schedule sc join theatre t on t.id = sc.theatre_id join branch b on b.id = t.branch_id where sc.movie_id = ?"
+	params := []interface{}{movie.ID}
+	if format := c.Query("format"); format != "" {
+		parsed, ok := models.ParseFormat(format)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown format"})
+			return
+		}
+		query += " and sc.format = ?"
+		params = append(params, parsed)
+	}
+	rows, err := db.Query(query, params...)
 	if err != nil {
 		log.Println(err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -76,7 +88,7 @@ func GetSchedules(c *gin.Context) {
 		var schedule models.Schedule
 		var theatre models.Theatre
 		var branch models.BranchTheatre
-		if err := rows.Scan(&schedule.ID, &schedule.Showtime, &schedule.Price, &theatre.ID, &theatre.Name, &branch.ID, &branch.Name, &branch.Address); err != nil {
+		if err := rows.Scan(&schedule.ID, &schedule.Showtime, &schedule.Price, &schedule.Currency, &schedule.Format, &theatre.ID, &theatre.Name, &branch.ID, &branch.Name, &branch.Address); err != nil {
 			log.Println(err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -139,7 +151,7 @@ func GetSchedule(c *gin.Context) {
 	var theatre models.Theatre
 	var branch models.BranchTheatre
 
-	err = db.QueryRow("select sc.id, sc.show_time, sc.price, t.id, t.name, b.id, b.name, b.address from schedule sc join theatre t on t.id = sc.theatre_id join branch b on b.id = t.branch_id where sc.id = ?", scheduleId).Scan(&schedule.ID, &schedule.Showtime, &schedule.Price, &theatre.ID, &theatre.Name, &branch.ID, &branch.Name, &branch.Address)
+	err = db.QueryRow("select sc.id, sc.show_time, sc.price, sc.currency, coalesce(sc.format, ''), t.id, t.name, b.id, b.name, b.address from schedule sc join theatre t on t.id = sc.theatre_id join branch b on b.id = t.branch_id where sc.id = ?", scheduleId).Scan(&schedule.ID, &schedule.Showtime, &schedule.Price, &schedule.Currency, &schedule.Format, &theatre.ID, &theatre.Name, &branch.ID, &branch.Name, &branch.Address)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			response := models.Response{
@@ -171,7 +183,15 @@ func GetSchedule(c *gin.Context) {
 
 	// get seats data
 	var seats []models.Seat
-	query := "select se.id, se.row, se.seat_number, IF(EXISTS (SELECT 1 FROM ticket t WHERE t.seat_id = se.id), 0, 1) AS availability from seat se where se.schedule_id = ?"
+	pricing, err := loadSchedulePricing(db, schedule.ID)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	schedule.Prices = pricing.prices
+
+	query := "select se.id, se.row, se.seat_number, IFNULL(se.position, 0), se.seat_type, se.blocked, IF(se.blocked = 0 AND NOT EXISTS (SELECT 1 FROM ticket t WHERE t.seat_id = se.id AND t.active = 1), 1, 0) AS availability from seat se where se.schedule_id = ? order by se.row, se.seat_number"
 	rows, err := db.Query(query, schedule.ID)
 	if err != nil {
 		log.Println(err)
@@ -182,15 +202,37 @@ func GetSchedule(c *gin.Context) {
 	for rows.Next() {
 		var availabilityInt int
 		var seat models.Seat
-		if err := rows.Scan(&seat.ID, &seat.Row, &seat.Number, &availabilityInt); err != nil {
+		if err := rows.Scan(&seat.ID, &seat.Row, &seat.Number, &seat.Position, &seat.Type, &seat.Blocked, &availabilityInt); err != nil {
 			log.Println(err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 		availability := availabilityInt == 1
 		seat.Availability = &availability
+		price := pricing.seatPrice(seat.Type)
+		seat.Price = &price
 		seats = append(seats, seat)
 	}
+
+	// seats held by a customer are not available either
+	seatIds := make([]int, len(seats))
+	for i, seat := range seats {
+		seatIds[i] = seat.ID
+	}
+	redisClient := tool.NewRedisClient()
+	defer redisClient.Close()
+	holders, err := tool.SeatHolders(redisClient, schedule.ID, seatIds)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range seats {
+		if _, held := holders[seats[i].ID]; held {
+			unavailable := false
+			seats[i].Availability = &unavailable
+		}
+	}
 	schedule.Seats = &seats
 
 	responseData := models.MovieScheduleResponse{
@@ -244,25 +286,59 @@ func CreateMovieSchedule(c *gin.Context) {
 			return
 		}
 	}
-	log.Println("A: ", schedule.Price)
-	log.Println(" B: ", schedule.Showtime)
-	log.Println(" D: ", schedule.Branch.ID)
-	// Insert schedule into database
-	result, err := db.Exec("INSERT INTO schedule (price, show_time, movie_id, theatre_id) VALUES (?, ?, ?, ?)",
-		schedule.Price, schedule.Showtime, movieId, schedule.Branch.ID)
+	if err := validSchedule(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// the theatre may be given explicitly, otherwise branch.id holds the theatre id
+	theatreId := schedule.Branch.Theatre.ID
+	if theatreId == 0 && schedule.Branch.ID != nil {
+		theatreId = *schedule.Branch.ID
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM theatre WHERE id = ?", theatreId).Scan(&count); err != nil || count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Theatre not found"})
+		return
+	}
+
+	tx, err := db.Begin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	defer tx.Rollback()
 
-	// Get the ID of the inserted schedule
-	scheduleID, err := result.LastInsertId()
+	// the theatre can only show one movie at a time, cleanup included
+	if err := lockTheatre(tx, theatreId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	scheduleID, conflicts, err := insertSchedule(tx, schedule, movieId, theatreId)
 	if err != nil {
+		if formatError(err) {
+			c.JSON(http.StatusBadRequest, models.Response{Status: 400, Message: err.Error()})
+			return
+		}
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, models.ScheduleConflictResponse{
+			Response:  models.Response{Status: 409, Message: errScheduleConflict.Error()},
+			Conflicts: conflicts,
+		})
+		return
+	}
+	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	schedule.ID = int(scheduleID)
+	schedule.ID = scheduleID
 
 	responseData := models.ScheduleResponse{
 		Response: models.Response{
@@ -271,6 +347,7 @@ func CreateMovieSchedule(c *gin.Context) {
 		},
 		Schedule: schedule,
 	}
+	invalidateCatalog()
 	c.JSON(http.StatusOK, responseData)
 }
 
@@ -314,6 +391,16 @@ func AddScheduleSeats(c *gin.Context) {
 		return
 	}
 
+	for i := range seatRows {
+		if seatRows[i].Type == "" {
+			seatRows[i].Type = models.Regular
+		}
+		if !seatRows[i].Type.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seat type " + string(seatRows[i].Type)})
+			return
+		}
+	}
+
 	for _, seat := range seatRows {
 		// check if the row already exist
 		var lastNumber int
@@ -335,7 +422,7 @@ func AddScheduleSeats(c *gin.Context) {
 		}
 
 		for i := (0 + lastNumber); i <= seat.Count; i++ {
-			_, err := db.Exec("insert into seat (row, seat_number, schedule_id) values (?, ?, ?)", seat.Row, i, scheduleId)
+			_, err := db.Exec("insert into seat (row, seat_number, seat_type, schedule_id) values (?, ?, ?, ?)", seat.Row, i, seat.Type, scheduleId)
 			if err != nil {
 				log.Println(err)
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -380,6 +467,11 @@ func UpdateMovieSchedule(c *gin.Context) {
 		return
 	}
 
+	if err := validSchedule(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	log.Println("schedule.Branch.Theatre.ID: ", schedule.Branch.Theatre.ID)
 	scheduleID, err := strconv.Atoi(c.Param("scheduleId"))
 	if err != nil {
@@ -413,18 +505,55 @@ func UpdateMovieSchedule(c *gin.Context) {
 	log.Println("scheduleID: ", scheduleID)
 	log.Println("schedule.Branch.Theatre.ID: ", schedule.Branch.Theatre.ID)
 
-	_, err = db.Exec("UPDATE schedule SET price = ?, show_time = ?, movie_id = ?, theatre_id = ? WHERE id = ?",
-		schedule.Price, schedule.Showtime, schedule.Movie.ID, schedule.Branch.Theatre.ID, scheduleID)
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// the theatre can only show one movie at a time, cleanup included
+	if err := lockTheatre(tx, schedule.Branch.Theatre.ID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Theatre not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	conflicts, err := updateSchedule(tx, scheduleID, schedule, schedule.Movie.ID, schedule.Branch.Theatre.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error1": err.Error()})
+		if formatError(err) {
+			c.JSON(http.StatusBadRequest, models.Response{Status: 400, Message: err.Error()})
+			return
+		}
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, models.ScheduleConflictResponse{
+			Response:  models.Response{Status: 409, Message: errScheduleConflict.Error()},
+			Conflicts: conflicts,
+		})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	schedule.Branch = models.BranchTheatre{}
 	schedulee.Movie = &models.Movie{}
 	schedulee.Branch = models.BranchTheatre{}
-	if err := db.QueryRow("SELECT id, price, show_time, movie_id, theatre_id FROM schedule WHERE id = ?", scheduleID).Scan(
+	if err := db.QueryRow("SELECT id, price, currency, coalesce(format, ''), show_time, movie_id, theatre_id FROM schedule WHERE id = ?", scheduleID).Scan(
 		&schedulee.ID,
 		&schedulee.Price,
+		&schedulee.Currency,
+		&schedulee.Format,
 		&schedulee.Showtime,
 		&schedulee.Movie.ID,
 		&schedulee.Branch.ID,
@@ -440,6 +569,7 @@ func UpdateMovieSchedule(c *gin.Context) {
 		},
 		Schedule: schedulee,
 	}
+	invalidateCatalog()
 	c.JSON(http.StatusOK, responseData)
 }
 
@@ -496,5 +626,78 @@ func DeleteSchedule(c *gin.Context) {
 		Status:  http.StatusOK,
 		Message: "Schedule deleted successfully",
 	}
+	invalidateCatalog()
+	c.JSON(http.StatusOK, responseData)
+}
+
+// CopySchedules godoc
+// @Summary Copy Schedules
+// @Description Clone the schedules of a branch, or of one of its theatres, from a date range to the same days starting at targetDate, with their seat layouts. Movies whose run has ended and show times that would overlap are skipped and reported.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param branchId path int true "Branch ID"
+// @Param body body models.ScheduleCopy true "Dates to copy"
+// @Success 200 {object} models.ScheduleCopyResponse
+// @Router /branches/{branchId}/schedules/copy [post]
+func CopySchedules(c *gin.Context) {
+	branchId, err := strconv.Atoi(c.Param("branchId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+	var scheduleCopy models.ScheduleCopy
+	if err := c.ShouldBindJSON(&scheduleCopy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, to, days, err := validScheduleCopy(scheduleCopy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	var count int
+	if scheduleCopy.TheatreID != nil {
+		err = db.QueryRow("SELECT COUNT(*) FROM theatre WHERE id = ? AND branch_id = ?", *scheduleCopy.TheatreID, branchId).Scan(&count)
+	} else {
+		err = db.QueryRow("SELECT COUNT(*) FROM branch WHERE id = ?", branchId).Scan(&count)
+	}
+	if err != nil || count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch or theatre not found"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := copySchedules(tx, branchId, scheduleCopy.TheatreID, from, to, days)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.ScheduleCopyResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: fmt.Sprintf("%d schedules copied, %d skipped", len(result.Copied), len(result.Skipped)),
+		},
+		Result: result,
+	}
+	invalidateCatalog()
 	c.JSON(http.StatusOK, responseData)
 }
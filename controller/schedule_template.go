@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"tix-id/models"
+)
+
+// maxTemplateDays caps how far a template reaches, so a typo in a date can't
+// generate years of schedules.
+const maxTemplateDays = 92
+
+var errTemplateNotFound = errors.New("the schedule template is not found")
+
+// showLocation is the time zone show times are programmed in, the same one
+// the database connection uses.
+var showLocation = func() *time.Location {
+	if location, err := time.LoadLocation("Asia/Jakarta"); err == nil {
+		return location
+	}
+	return time.FixedZone("WIB", 7*60*60)
+}()
+
+// validScheduleTemplate checks a template before it is saved, and puts its
+// days and start times in order.
+func validScheduleTemplate(template *models.ScheduleTemplate) error {
+	if template.MovieID <= 0 || template.TheatreID <= 0 {
+		return errors.New("a template needs a movie and a theatre")
+	}
+	if len(template.Days) == 0 || len(template.StartTimes) == 0 {
+		return errors.New("a template needs days and start times")
+	}
+	for i, day := range template.Days {
+		template.Days[i] = strings.ToLower(strings.TrimSpace(day))
+		if !weekdays[template.Days[i]] {
+			return fmt.Errorf("unknown day %q", day)
+		}
+	}
+	for i, startTime := range template.StartTimes {
+		clock, err := time.Parse("15:04", strings.TrimSpace(startTime))
+		if err != nil {
+			return fmt.Errorf("start time %q is not HH:MM", startTime)
+		}
+		template.StartTimes[i] = clock.Format("15:04")
+	}
+	sort.Strings(template.StartTimes)
+	startDate, err := time.Parse("2006-01-02", template.StartDate)
+	if err != nil {
+		return errors.New("startDate must be YYYY-MM-DD")
+	}
+	endDate, err := time.Parse("2006-01-02", template.EndDate)
+	if err != nil {
+		return errors.New("endDate must be YYYY-MM-DD")
+	}
+	if endDate.Before(startDate) || endDate.Sub(startDate) >= maxTemplateDays*24*time.Hour {
+		return fmt.Errorf("the date range must be forward and at most %d days", maxTemplateDays)
+	}
+	if template.Price < 0 || !validSchedulePrices(template.Prices) {
+		return errors.New("invalid prices")
+	}
+	format, ok := models.ParseFormat(string(template.Format))
+	if !ok {
+		return fmt.Errorf("unknown format %q", template.Format)
+	}
+	template.Format = format
+	return nil
+}
+
+// expandScheduleTemplate returns the show times of a template in order.
+func expandScheduleTemplate(template models.ScheduleTemplate) ([]time.Time, error) {
+	days := map[string]bool{}
+	for _, day := range template.Days {
+		days[day] = true
+	}
+	startDate, err := time.ParseInLocation("2006-01-02", template.StartDate, showLocation)
+	if err != nil {
+		return nil, err
+	}
+	endDate, err := time.ParseInLocation("2006-01-02", template.EndDate, showLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	var showTimes []time.Time
+	for date := startDate; !date.After(endDate); date = date.AddDate(0, 0, 1) {
+		if !days[strings.ToLower(date.Weekday().String())] {
+			continue
+		}
+		for _, startTime := range template.StartTimes {
+			showTime, err := time.ParseInLocation("2006-01-02 15:04", date.Format("2006-01-02")+" "+startTime, showLocation)
+			if err != nil {
+				return nil, err
+			}
+			showTimes = append(showTimes, showTime)
+		}
+	}
+	return showTimes, nil
+}
+
+// loadScheduleTemplate loads a template with its seat type prices.
+func loadScheduleTemplate(q queryer, templateId int) (models.ScheduleTemplate, error) {
+	var template models.ScheduleTemplate
+	var days, startTimes string
+	var format sql.NullString
+	err := q.QueryRow("select id, movie_id, theatre_id, days, start_times, date_format(start_date, '%Y-%m-%d'), date_format(end_date, '%Y-%m-%d'), price, format, created_at from schedule_template where id = ?", templateId).Scan(&template.ID, &template.MovieID, &template.TheatreID, &days, &startTimes, &template.StartDate, &template.EndDate, &template.Price, &format, &template.CreatedAt)
+	if err == sql.ErrNoRows {
+		return template, errTemplateNotFound
+	} else if err != nil {
+		return template, err
+	}
+	template.Days = strings.Split(days, ",")
+	template.StartTimes = strings.Split(startTimes, ",")
+	template.Format = models.Format(format.String)
+
+	rows, err := q.Query("select seat_type, price from schedule_template_price where template_id = ?", templateId)
+	if err != nil {
+		return template, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seatType models.SeatType
+		var price models.Money
+		if err := rows.Scan(&seatType, &price); err != nil {
+			return template, err
+		}
+		if template.Prices == nil {
+			template.Prices = map[models.SeatType]models.Money{}
+		}
+		template.Prices[seatType] = price
+	}
+	return template, rows.Err()
+}
+
+// generateSchedules creates a schedule for every show of the template inside
+// the transaction. A show that overlaps an existing schedule, or another
+// show of the batch, is reported with its conflicts instead, and the caller
+// should roll the whole batch back.
+func generateSchedules(q queryer, template models.ScheduleTemplate) ([]models.GeneratedSchedule, bool, error) {
+	showTimes, err := expandScheduleTemplate(template)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := lockTheatre(q, template.TheatreID); err != nil {
+		return nil, false, err
+	}
+
+	generated := []models.GeneratedSchedule{}
+	conflicting := false
+	for _, showTime := range showTimes {
+		schedule := models.Schedule{Price: template.Price, Prices: template.Prices, Format: template.Format, Showtime: showTime}
+		scheduleId, conflicts, err := insertSchedule(q, schedule, template.MovieID, template.TheatreID)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(conflicts) > 0 {
+			conflicting = true
+		}
+		generated = append(generated, models.GeneratedSchedule{ScheduleID: scheduleId, Showtime: showTime, Conflicts: conflicts})
+	}
+	return generated, conflicting, nil
+}
@@ -0,0 +1,314 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"tix-id/config"
+	"tix-id/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlNoReferencedRow is the error MySQL returns when a foreign key points nowhere.
+const mysqlNoReferencedRow = 1452
+
+// CreateScheduleTemplate godoc
+// @Summary Create Schedule Template
+// @Description Create a weekly programme of a movie in a theatre, to generate its schedules from
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.ScheduleTemplate true "Template"
+// @Success 200 {object} models.ScheduleTemplateResponse
+// @Router /schedule-templates [post]
+func CreateScheduleTemplate(c *gin.Context) {
+	var template models.ScheduleTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validScheduleTemplate(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("insert into schedule_template (movie_id, theatre_id, days, start_times, start_date, end_date, price, format) values (?, ?, ?, ?, ?, ?, ?, ?)", template.MovieID, template.TheatreID, strings.Join(template.Days, ","), strings.Join(template.StartTimes, ","), template.StartDate, template.EndDate, template.Price, template.Format)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlNoReferencedRow {
+			c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the movie or theatre is not found"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkScheduleFormat(tx, template.MovieID, template.TheatreID, template.Format); err != nil {
+		if formatError(err) {
+			c.JSON(http.StatusBadRequest, models.Response{Status: 400, Message: err.Error()})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for seatType, price := range template.Prices {
+		if _, err := tx.Exec("insert into schedule_template_price (template_id, seat_type, price) values (?, ?, ?)", id, seatType, price); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	template, err = loadScheduleTemplate(tx, int(id))
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.ScheduleTemplateResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Schedule template created successfully",
+		},
+		Template: template,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// GetScheduleTemplates godoc
+// @Summary Get Schedule Templates
+// @Description Get the schedule templates, optionally of one movie
+// @Tags Admin
+// @Produce json
+// @Param movieId query int false "Movie ID"
+// @Success 200 {object} models.ScheduleTemplatesResponse
+// @Router /schedule-templates [get]
+func GetScheduleTemplates(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	query := "select id from schedule_template"
+	var args []interface{}
+	if movieId := c.Query("movieId"); movieId != "" {
+		query += " where movie_id = ?"
+		args = append(args, movieId)
+	}
+	rows, err := db.Query(query+" order by id desc", args...)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	templates := []models.ScheduleTemplate{}
+	for _, id := range ids {
+		template, err := loadScheduleTemplate(db, id)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		templates = append(templates, template)
+	}
+
+	responseData := models.ScheduleTemplatesResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Schedule templates retrieved successfully",
+		},
+		Templates: templates,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// GetScheduleTemplate godoc
+// @Summary Get Schedule Template
+// @Description Get a schedule template
+// @Tags Admin
+// @Produce json
+// @Param templateId path int true "Template ID"
+// @Success 200 {object} models.ScheduleTemplateResponse
+// @Router /schedule-templates/{templateId} [get]
+func GetScheduleTemplate(c *gin.Context) {
+	templateId, err := strconv.Atoi(c.Param("templateId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	template, err := loadScheduleTemplate(db, templateId)
+	if err != nil {
+		if err == errTemplateNotFound {
+			c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: err.Error()})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.ScheduleTemplateResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Schedule template retrieved successfully",
+		},
+		Template: template,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// DeleteScheduleTemplate godoc
+// @Summary Delete Schedule Template
+// @Description Delete a schedule template. The schedules generated from it stay.
+// @Tags Admin
+// @Produce json
+// @Param templateId path int true "Template ID"
+// @Success 200 {object} models.Response
+// @Router /schedule-templates/{templateId} [delete]
+func DeleteScheduleTemplate(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	res, err := db.Exec("delete from schedule_template where id = ?", c.Param("templateId"))
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: errTemplateNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: 200, Message: "Schedule template deleted successfully"})
+}
+
+// GenerateSchedules godoc
+// @Summary Generate Schedules
+// @Description Expand a template into schedules with their seats, all of them or none. With dryRun the schedules are only previewed, together with any conflict.
+// @Tags Admin
+// @Produce json
+// @Param templateId path int true "Template ID"
+// @Param dryRun query bool false "Preview without creating anything"
+// @Success 200 {object} models.ScheduleGenerationResponse
+// @Failure 409 {object} models.ScheduleGenerationResponse
+// @Router /schedule-templates/{templateId}/schedules [post]
+func GenerateSchedules(c *gin.Context) {
+	templateId, err := strconv.Atoi(c.Param("templateId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	template, err := loadScheduleTemplate(db, templateId)
+	if err != nil {
+		if err == errTemplateNotFound {
+			c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: err.Error()})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// a dry run creates the batch like the real thing, so it sees the
+	// conflicts within the batch too, then rolls it back
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	schedules, conflicting, err := generateSchedules(tx, template)
+	if err != nil {
+		if formatError(err) {
+			c.JSON(http.StatusBadRequest, models.Response{Status: 400, Message: err.Error()})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	generation := models.ScheduleGeneration{TemplateID: template.ID, DryRun: dryRun, Schedules: schedules}
+	if dryRun || conflicting {
+		for i := range generation.Schedules {
+			generation.Schedules[i].ScheduleID = 0
+		}
+	}
+	if conflicting && !dryRun {
+		c.JSON(http.StatusConflict, models.ScheduleGenerationResponse{
+			Response:   models.Response{Status: 409, Message: errScheduleConflict.Error()},
+			Generation: generation,
+		})
+		return
+	}
+
+	message := strconv.Itoa(len(schedules)) + " schedules would be generated"
+	if conflicting {
+		message = "some schedules overlap other schedules of the theatre"
+	}
+	if !dryRun {
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		message = strconv.Itoa(len(schedules)) + " schedules generated successfully"
+		invalidateCatalog()
+	}
+
+	responseData := models.ScheduleGenerationResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: message,
+		},
+		Generation: generation,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	defaultSearchLimit  = 50
+	maxSearchLimit      = 100
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 20
+)
+
+// movieScore ranks a movie against a full-text query in boolean mode, given
+// four times, and a LIKE pattern of the whole search. Matches in the title
+// count most, then the cast and crew and the genres, then the description,
+// and a title containing the search as typed comes first.
+const movieScore = `3 * match(m.title) against (? in boolean mode)
+	+ match(m.description) against (? in boolean mode)
+	+ 2 * coalesce((select max(match(mc.name) against (? in boolean mode)) from movie_credit mc where mc.movie_id = m.id), 0)
+	+ 2 * coalesce((select max(match(g.name) against (? in boolean mode)) from movie_genre mg join genre g on g.id = mg.genre_id where mg.movie_id = m.id), 0)
+	+ 10 * (m.title like ?)`
+
+// vocabularyCache keeps the search vocabulary for the version of the
+// catalog it was read at, so it is read again once invalidateCatalog is
+// called after a movie changes.
+var vocabularyCache struct {
+	sync.Mutex
+	version string
+	words   map[string]bool
+}
+
+// cachedSearchVocabulary returns the search vocabulary, reading it only when
+// the catalog changed since. Without Redis it is read every time.
+func cachedSearchVocabulary(q queryer) (map[string]bool, error) {
+	client := tool.NewRedisClient()
+	defer client.Close()
+	version, err := client.Get(catalogVersionKey).Result()
+	if err == redis.Nil {
+		version, err = "0", nil
+	}
+	if err != nil {
+		log.Println(err)
+		return searchVocabulary(q)
+	}
+
+	vocabularyCache.Lock()
+	defer vocabularyCache.Unlock()
+	if vocabularyCache.words != nil && vocabularyCache.version == version {
+		return vocabularyCache.words, nil
+	}
+	words, err := searchVocabulary(q)
+	if err != nil {
+		return nil, err
+	}
+	vocabularyCache.version, vocabularyCache.words = version, words
+	return words, nil
+}
+
+// searchVocabulary is every word of the titles, genres and names, the words
+// a misspelled search is corrected to.
+func searchVocabulary(q queryer) (map[string]bool, error) {
+	rows, err := q.Query("select title from movie union select name from genre union select name from movie_credit")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	vocabulary := map[string]bool{}
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			return nil, err
+		}
+		for _, word := range tool.SearchWords(text) {
+			vocabulary[word] = true
+		}
+	}
+	return vocabulary, rows.Err()
+}
+
+// correctTerms finds the words each misspelled term could have meant.
+func correctTerms(q queryer, terms []string) (map[string][]string, error) {
+	vocabulary, err := cachedSearchVocabulary(q)
+	if err != nil {
+		return nil, err
+	}
+	corrections := map[string][]string{}
+	for _, term := range terms {
+		if words := tool.CorrectTerm(term, vocabulary); len(words) > 0 {
+			corrections[term] = words
+		}
+	}
+	return corrections, nil
+}
+
+// fullTextQuery makes a query in boolean mode that matches words starting
+// with any of the terms, or with their corrections. When required, every
+// term or one of its corrections must match.
+func fullTextQuery(terms []string, corrections map[string][]string, required bool) string {
+	groups := make([]string, len(terms))
+	for i, term := range terms {
+		group := term + "*"
+		for _, word := range corrections[term] {
+			group += " " + word + "*"
+		}
+		if required {
+			group = "+(" + group + ")"
+		}
+		groups[i] = group
+	}
+	return strings.Join(groups, " ")
+}
+
+// findMovies reads the movies the query selects with movieColumns.
+func findMovies(q queryer, query string, args []interface{}) ([]models.Movie, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var movies []models.Movie
+	for rows.Next() {
+		movie, err := scanMovie(rows)
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, movie)
+	}
+	return movies, rows.Err()
+}
+
+// rankedMovies finds the movies matching the search terms, or their
+// corrections, and the filters, most relevant first.
+func rankedMovies(q queryer, search string, terms []string, corrections map[string][]string, filters string, filterArgs []interface{}, limit int) ([]models.Movie, error) {
+	fullText := fullTextQuery(terms, corrections, false)
+	args := []interface{}{fullText, fullText, fullText, fullText, "%" + search + "%"}
+	args = append(append(args, filterArgs...), limit)
+	return findMovies(q, "SELECT "+movieColumns+" FROM (select m.id, "+movieScore+" score from movie m) s join movie m on m.id = s.id where s.score > 0"+filters+" order by s.score desc, m.release_date desc limit ?", args)
+}
+
+// suggestMovies finds the movies whose title has every term, the last one
+// as a prefix, with the titles starting with the search first.
+func suggestMovies(q queryer, search string, terms []string, corrections map[string][]string, limit int) ([]models.Suggestion, error) {
+	query := fullTextQuery(terms, corrections, true)
+	rows, err := q.Query("select m.id, m.title from movie m where match(m.title) against (? in boolean mode) or m.title like ? order by m.title like ? desc, match(m.title) against (? in boolean mode) desc, m.release_date desc limit ?",
+		query, "%"+search+"%", search+"%", query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var suggestions []models.Suggestion
+	for rows.Next() {
+		suggestion := models.Suggestion{Kind: models.MovieSuggestion}
+		if err := rows.Scan(&suggestion.MovieID, &suggestion.Text); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, rows.Err()
+}
+
+// suggestNames finds the genres, or the people by how many movies they are
+// in, with a word starting with the search.
+func suggestNames(q queryer, kind models.SuggestionKind, search string, limit int) ([]models.Suggestion, error) {
+	query := "select name from genre where name like ? or name like ? order by name limit ?"
+	if kind == models.PersonSuggestion {
+		query = "select name from movie_credit where name like ? or name like ? group by name order by count(distinct movie_id) desc, name limit ?"
+	}
+	rows, err := q.Query(query, search+"%", "% "+search+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var suggestions []models.Suggestion
+	for rows.Next() {
+		suggestion := models.Suggestion{Kind: kind}
+		if err := rows.Scan(&suggestion.Text); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, rows.Err()
+}
+
+// suggest completes a search being typed with movies, then genres and
+// people, at most limit of them. Misspelled words are corrected when no
+// movie matches as typed.
+func suggest(q queryer, search string, limit int) ([]models.Suggestion, error) {
+	terms := tool.SearchTerms(search)
+	if len(terms) == 0 {
+		return []models.Suggestion{}, nil
+	}
+	suggestions, err := suggestMovies(q, search, terms, nil, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(suggestions) == 0 {
+		corrections, err := correctTerms(q, terms)
+		if err != nil {
+			return nil, err
+		}
+		if len(corrections) > 0 {
+			if suggestions, err = suggestMovies(q, search, terms, corrections, limit); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, kind := range []models.SuggestionKind{models.GenreSuggestion, models.PersonSuggestion} {
+		if len(suggestions) >= limit {
+			break
+		}
+		names, err := suggestNames(q, kind, search, limit-len(suggestions))
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, names...)
+	}
+	if suggestions == nil {
+		suggestions = []models.Suggestion{}
+	}
+	return suggestions, nil
+}
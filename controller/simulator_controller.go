@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"tix-id/config"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SimulatorCheckout godoc
+// @Summary Payment Simulator Checkout
+// @Description The redirect URL of a simulator charge. Opening it pays the charge, like a customer would at a real gateway.
+// @Tags Payment
+// @Produce json
+// @Param chargeId path string true "Charge ID"
+// @Success 200 {object} models.Response
+// @Router /simulator/checkout/{chargeId} [get]
+func SimulatorCheckout(c *gin.Context) {
+	provider, err := tool.NewPaymentProvider()
+	if err != nil || provider.Name() != tool.SimulatorProvider {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the payment simulator is not enabled"})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	var paymentId int
+	if err := db.QueryRow("select id from payment where charge_id = ?", c.Param("chargeId")).Scan(&paymentId); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: tool.ErrChargeNotFound.Error()})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := chargePayment(db, provider, paymentId); err != nil {
+		switch err {
+		case errPaymentNotPending:
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: "the payment is paid or expired"})
+		case errPaymentDeclined:
+			c.JSON(http.StatusPaymentRequired, models.Response{Status: 402, Message: err.Error()})
+		case errPaymentProcessing:
+			c.JSON(http.StatusAccepted, models.Response{Status: 202, Message: err.Error()})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: 200, Message: "Payment Confirm successfully"})
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"tix-id/config"
+	"tix-id/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+)
+
+func validJurisdiction(jurisdiction models.TaxJurisdiction) bool {
+	return strings.TrimSpace(jurisdiction.Name) != "" && jurisdiction.TaxRate >= 0 && jurisdiction.TaxRate <= 100 && jurisdiction.ServiceFee >= 0
+}
+
+// GetTaxJurisdictions godoc
+// @Summary Get Tax Jurisdictions
+// @Description Get the entertainment tax rate and service fee of every jurisdiction
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.TaxJurisdictionsResponse
+// @Router /tax-jurisdictions [get]
+func GetTaxJurisdictions(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	rows, err := db.Query("select id, name, tax_rate, service_fee from tax_jurisdiction order by name")
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	jurisdictions := []models.TaxJurisdiction{}
+	for rows.Next() {
+		var jurisdiction models.TaxJurisdiction
+		if err := rows.Scan(&jurisdiction.ID, &jurisdiction.Name, &jurisdiction.TaxRate, &jurisdiction.ServiceFee); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		jurisdictions = append(jurisdictions, jurisdiction)
+	}
+
+	responseData := models.TaxJurisdictionsResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Tax jurisdictions retrieved successfully",
+		},
+		Jurisdictions: jurisdictions,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// CreateTaxJurisdiction godoc
+// @Summary Create Tax Jurisdiction
+// @Description Create a jurisdiction with its entertainment tax rate (percent) and service fee per ticket
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.TaxJurisdiction true "Jurisdiction"
+// @Success 200 {object} models.TaxJurisdictionResponse
+// @Router /tax-jurisdictions [post]
+func CreateTaxJurisdiction(c *gin.Context) {
+	var jurisdiction models.TaxJurisdiction
+	if err := c.ShouldBindJSON(&jurisdiction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	jurisdiction.Name = strings.TrimSpace(jurisdiction.Name)
+	if !validJurisdiction(jurisdiction) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a jurisdiction needs a name, a tax rate between 0 and 100 and no negative fee"})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	res, err := db.Exec("insert into tax_jurisdiction (name, tax_rate, service_fee) values (?, ?, ?)", jurisdiction.Name, jurisdiction.TaxRate, jurisdiction.ServiceFee)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: "the jurisdiction already exists"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	jurisdiction.ID = int(id)
+
+	responseData := models.TaxJurisdictionResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Tax jurisdiction created successfully",
+		},
+		Jurisdiction: jurisdiction,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// UpdateTaxJurisdiction godoc
+// @Summary Update Tax Jurisdiction
+// @Description Change the tax rate or service fee of a jurisdiction. Bookings already made keep the breakdown they were charged.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param jurisdictionId path int true "Jurisdiction ID"
+// @Param body body models.TaxJurisdiction true "Jurisdiction"
+// @Success 200 {object} models.TaxJurisdictionResponse
+// @Router /tax-jurisdictions/{jurisdictionId} [put]
+func UpdateTaxJurisdiction(c *gin.Context) {
+	jurisdictionId, err := strconv.Atoi(c.Param("jurisdictionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var jurisdiction models.TaxJurisdiction
+	if err := c.ShouldBindJSON(&jurisdiction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	jurisdiction.ID = jurisdictionId
+	jurisdiction.Name = strings.TrimSpace(jurisdiction.Name)
+	if !validJurisdiction(jurisdiction) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a jurisdiction needs a name, a tax rate between 0 and 100 and no negative fee"})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("select count(*) from tax_jurisdiction where id = ?", jurisdiction.ID).Scan(&count); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: "the jurisdiction is not found!"})
+		return
+	}
+	if _, err := db.Exec("update tax_jurisdiction set name = ?, tax_rate = ?, service_fee = ? where id = ?", jurisdiction.Name, jurisdiction.TaxRate, jurisdiction.ServiceFee, jurisdiction.ID); err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: "the jurisdiction already exists"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	responseData := models.TaxJurisdictionResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Tax jurisdiction updated successfully",
+		},
+		Jurisdiction: jurisdiction,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
@@ -39,7 +39,15 @@ func CreateTheatre(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	result, err := db.Exec("INSERT INTO theatre (name,branch_id) VALUES (?,?)", theatre.Name, branchId)
+	if theatre.CleanupMinutes != nil && *theatre.CleanupMinutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the cleanup time can't be negative"})
+		return
+	}
+	if err := validFormats(theatre.Formats); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := db.Exec("INSERT INTO theatre (name,branch_id,cleanup_minutes,formats) VALUES (?,?,coalesce(?, default(cleanup_minutes)),coalesce(nullif(?, ''), default(formats)))", theatre.Name, branchId, theatre.CleanupMinutes, formatSet(theatre.Formats))
 
 	id, err := result.LastInsertId()
 	if err != nil {
@@ -54,6 +62,7 @@ func CreateTheatre(c *gin.Context) {
 		},
 		Theatre: theatre,
 	}
+	invalidateCatalog()
 	c.JSON(http.StatusOK, responseData)
 }
 
@@ -97,7 +106,16 @@ func UpdateTheatre(c *gin.Context) {
 	//branch id
 	branch.ID = branchId
 
-	result, err := db.Exec("UPDATE theatre SET name=? WHERE id=? && branch_id=?", theatre.Name, theatre.ID, branch.ID)
+	if theatre.CleanupMinutes != nil && *theatre.CleanupMinutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the cleanup time can't be negative"})
+		return
+	}
+	if err := validFormats(theatre.Formats); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// the formats only change when they are given
+	result, err := db.Exec("UPDATE theatre SET name=?, cleanup_minutes=coalesce(?, cleanup_minutes), formats=coalesce(nullif(?, ''), formats) WHERE id=? && branch_id=?", theatre.Name, theatre.CleanupMinutes, formatSet(theatre.Formats), theatre.ID, branch.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -121,6 +139,7 @@ func UpdateTheatre(c *gin.Context) {
 		},
 		Theatre: theatre,
 	}
+	invalidateCatalog()
 	c.JSON(http.StatusOK, responseData)
 }
 
@@ -201,5 +220,6 @@ func DeleteTheatre(c *gin.Context) {
 		Status:  200,
 		Message: message,
 	}
+	invalidateCatalog()
 	c.JSON(http.StatusOK, responseData)
 }
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 	"tix-id/config"
 	"tix-id/middleware"
 	"tix-id/models"
@@ -58,7 +59,7 @@ func GetTickets(c *gin.Context) {
 	}
 
 	// get data
-	query = "SELECT tc.id, se.id, se.row, se.seat_number, p.id, p.amount, p.payment_status, s.id, s.price, s.show_time, m.id, m.title, m.description, m.duration, m.rating, m.release_date, b.id, b.name, b.address, t.id, t.name from ticket tc join seat se on se.id = tc.seat_id join payment p on p.id = tc.payment_id join schedule s on s.id = tc.schedule_id join movie m on m.id = s.movie_id join theatre t on t.id = s.theatre_id join branch b on b.id = t.branch_id where tc.customer_id = ?"
+	query = "SELECT tc.id, se.id, se.row, se.seat_number, p.id, p.amount, p.currency, p.payment_status, s.id, s.price, s.currency, s.show_time, m.id, m.title, m.description, m.duration, m.rating, m.release_date, b.id, b.name, b.address, t.id, t.name, tc.cancelled_at from ticket tc join seat se on se.id = tc.seat_id join payment p on p.id = tc.payment_id join schedule s on s.id = tc.schedule_id join movie m on m.id = s.movie_id join theatre t on t.id = s.theatre_id join branch b on b.id = t.branch_id where tc.customer_id = ?"
 	rows, err := db.Query(query, customerId)
 	if err != nil {
 		log.Println(err)
@@ -75,7 +76,8 @@ func GetTickets(c *gin.Context) {
 	var branch models.BranchTheatre
 	var theatre models.Theatre
 	for rows.Next() {
-		if err := rows.Scan(&ticket.ID, &seat.ID, &seat.Row, &seat.Number, &payment.ID, &payment.Amount, &payment.Status, &schedule.ID, &schedule.Price, &schedule.Showtime, &movie.ID, &movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate, &branch.ID, &branch.Name, &branch.Address, &theatre.ID, &theatre.Name); err != nil {
+		var cancelledAt sql.NullTime
+		if err := rows.Scan(&ticket.ID, &seat.ID, &seat.Row, &seat.Number, &payment.ID, &payment.Amount, &payment.Currency, &payment.Status, &schedule.ID, &schedule.Price, &schedule.Currency, &schedule.Showtime, &movie.ID, &movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate, &branch.ID, &branch.Name, &branch.Address, &theatre.ID, &theatre.Name, &cancelledAt); err != nil {
 			log.Println(err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -86,6 +88,10 @@ func GetTickets(c *gin.Context) {
 			ticket.Schedule = schedule
 			ticket.Payment = payment
 			ticket.Seat = seat
+			ticket.CancelledAt = nil
+			if cancelledAt.Valid {
+				ticket.CancelledAt = &cancelledAt.Time
+			}
 			tickets = append(tickets, ticket)
 		}
 	}
@@ -109,6 +115,7 @@ func GetTickets(c *gin.Context) {
 // @Produce json
 // @Param customerId path int true "Customer ID"
 // @Param body body models.ScheduleTicket true "Schedule Detail"
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried"
 // @Success 200 {object} models.TicketResponse
 // @Router /customer/{customerId}/tickets [post]
 func CreateTicket(c *gin.Context) {
@@ -126,128 +133,98 @@ func CreateTicket(c *gin.Context) {
 		return
 	}
 
-	var schedule models.ScheduleTicket
-	if err := c.ShouldBindJSON(&schedule); err != nil {
+	var request models.ScheduleTicket
+	if err := c.ShouldBindJSON(&request); err != nil {
 		log.Println(err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if request.Seat == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no seat at body!"})
+		return
+	}
+
 	// checking schedule
-	var movie models.Movie
-	var theatre models.Theatre
-	error := db.QueryRow("select movie_id, theatre_id, show_time from schedule where id = ?", schedule.ID).Scan(&movie.ID, &theatre.ID, &schedule.Showtime)
-	if error != nil {
-		log.Println(error)
-		if error == sql.ErrNoRows {
+	schedule, err := getScheduleTicket(db, request.ID)
+	if err != nil {
+		log.Println(err)
+		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"message": "Schledule is not found"})
 			return
 		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": error.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// get movie data
-	error = db.QueryRow("select title, description, duration, rating, release_date from movie where id = ?", movie.ID).Scan(&movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate)
-
-	if error != nil {
-		log.Println("error disini")
-		log.Println(error)
-		c.JSON(http.StatusBadRequest, gin.H{"error": error.Error()})
+	if err := confirmAge(schedule.Movie, request.AgeConfirmed); err != nil {
+		c.JSON(http.StatusForbidden, models.Response{Status: 403, Message: err.Error()})
 		return
 	}
-	schedule.Movie = &movie
 
-	// get branch data
-	var branch models.BranchTheatre
-	branch.Theatre = theatre
-	error = db.QueryRow("select b.name, b.address, t.name from branch b join theatre t on t.branch_id = b.id where t.id = ?", branch.Theatre.ID).Scan(&branch.Name, &branch.Address, &branch.Theatre.Name)
-	if error != nil {
-		log.Println(error)
-		c.JSON(http.StatusBadRequest, gin.H{"error": error.Error()})
-		return
-	}
-
-	var seat models.Seat
-	seat.ID = schedule.Seat.ID
-
-	// verify  seat is not taken yet
-	var count int
-	error = db.QueryRow("select count(t.id) from ticket t join payment p on t.payment_id = p.id where t.seat_id = ? and t.schedule_id = ? and p.payment_status in ('pending', 'completed')", seat.ID, schedule.ID).Scan(&count)
-	if error != nil {
-		log.Println(error)
-		c.JSON(http.StatusBadRequest, gin.H{"error": error.Error()})
+	// verify the seat is not held, unless the hold belongs to this customer
+	redisClient := tool.NewRedisClient()
+	defer redisClient.Close()
+	heldPrices, err := checkSeatHolds(redisClient, int(customerId), schedule.ID, []int{request.Seat.ID}, request.HoldToken)
+	if err != nil {
+		if err == errSeatHeld {
+			response := models.Response{
+				Status:  200,
+				Message: err.Error(),
+			}
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if count > 0 {
-		response := models.Response{
-			Status:  200,
-			Message: "the seat is taken",
-		}
-		c.JSON(http.StatusOK, response)
+	// lock the seat, verify it is free and create the payment and ticket in one transaction
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	defer tx.Rollback()
 
-	// get seat data and verify the seat is matched with the schdule
-	error = db.QueryRow("select row, seat_number from seat where id = ? and schedule_id = ?", seat.ID, schedule.ID).Scan(&seat.Row, &seat.Number)
-	if error != nil {
-		if error == sql.ErrNoRows {
+	order, err := bookSeats(tx, int(customerId), schedule, []int{request.Seat.ID}, heldPrices, request.Accessible, bookingDiscounts{PromoCode: request.PromoCode, Points: request.Points, GiftCardCode: request.GiftCard})
+	if err != nil {
+		switch err {
+		case errSeatNotFound:
 			response := models.Response{
 				Status:  404,
-				Message: "the seat is not match with the schedule",
+				Message: err.Error(),
 			}
 			c.JSON(http.StatusNotFound, response)
-			return
+		case errSeatTaken, errSeatBlocked, errWheelchairReserved:
+			response := models.Response{
+				Status:  200,
+				Message: err.Error(),
+			}
+			c.JSON(http.StatusOK, response)
+		case errCompanionAlone, errPromoNotFound, errPromoNotValid, errPromoNotApplicable, errPromoMinSeats, errPromoUsedUp, tool.ErrNotEnoughPoints, errGiftCardNotFound, errGiftCardEmpty, errCurrencyMismatch:
+			c.JSON(http.StatusBadRequest, models.Response{Status: 400, Message: err.Error()})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		}
-		log.Println(error)
-		c.JSON(http.StatusBadRequest, gin.H{"error": error.Error()})
-		return
-	}
-
-	// make a new payment
-	res, errQuery := db.Exec("insert into payment(amount, payment_status) values ((select price from schedule where id = ? limit 1),'pending')", schedule.ID)
-	if errQuery != nil {
-		log.Println(errQuery)
-		c.JSON(http.StatusBadRequest, gin.H{"error": errQuery.Error()})
 		return
 	}
 
-	lastInsertID, err := res.LastInsertId()
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		log.Println(err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	var payment models.Payment
-	payment.ID = int(lastInsertID)
-
-	// get payment data
-	error = db.QueryRow("select amount, payment_status from payment where id = ?", payment.ID).Scan(&payment.Amount, &payment.Status)
-	if error != nil {
-		log.Println(error)
-		c.JSON(http.StatusBadRequest, gin.H{"error": error.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// create new ticket
-	res, errQuery = db.Exec("insert into ticket(customer_id, schedule_id, seat_id, payment_id) values (?,?,?,?)",
-		customerId,
-		schedule.ID,
-		schedule.Seat.ID,
-		payment.ID,
-	)
-	lastInsertID, err = res.LastInsertId()
-	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	// the seat is booked now, so it doesn't need the hold anymore
+	if request.HoldToken != "" {
+		if err := tool.ReleaseHeldSeat(redisClient, request.HoldToken, schedule.ID, request.Seat.ID); err != nil {
+			log.Println(err)
+		}
 	}
 
-	schedule.Seat = nil
-	var ticket models.Ticket
-	ticket.ID = int(lastInsertID)
-	ticket.Payment = payment
-	ticket.Seat = seat
+	ticket := order.Tickets[0]
 	ticket.Schedule = schedule
 
 	responseData := models.TicketResponse{
@@ -289,7 +266,7 @@ func GetTicket(c *gin.Context) {
 	}
 
 	// get data
-	query := "SELECT tc.id, se.id, se.row, se.seat_number, p.id, p.amount, p.payment_status, s.id, s.price, s.show_time, m.id, m.title, m.description, m.duration, m.rating, m.release_date, b.id, b.name, b.address, t.id, t.name from ticket tc join seat se on se.id = tc.seat_id join payment p on p.id = tc.payment_id join schedule s on s.id = tc.schedule_id join movie m on m.id = s.movie_id join theatre t on t.id = s.theatre_id join branch b on b.id = t.branch_id where tc.id = ? and tc.customer_id = ?"
+	query := "SELECT tc.id, se.id, se.row, se.seat_number, p.id, p.amount, p.currency, p.payment_status, s.id, s.price, s.currency, s.show_time, m.id, m.title, m.description, m.duration, m.rating, m.release_date, b.id, b.name, b.address, t.id, t.name, tc.cancelled_at from ticket tc join seat se on se.id = tc.seat_id join payment p on p.id = tc.payment_id join schedule s on s.id = tc.schedule_id join movie m on m.id = s.movie_id join theatre t on t.id = s.theatre_id join branch b on b.id = t.branch_id where tc.id = ? and tc.customer_id = ?"
 	var ticket models.Ticket
 	var seat models.Seat
 	var payment models.Payment
@@ -297,7 +274,8 @@ func GetTicket(c *gin.Context) {
 	var movie models.Movie
 	var branch models.BranchTheatre
 	var theatre models.Theatre
-	err = db.QueryRow(query, ticketId, customerId).Scan(&ticket.ID, &seat.ID, &seat.Row, &seat.Number, &payment.ID, &payment.Amount, &payment.Status, &schedule.ID, &schedule.Price, &schedule.Showtime, &movie.ID, &movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate, &branch.ID, &branch.Name, &branch.Address, &theatre.ID, &theatre.Name)
+	var cancelledAt sql.NullTime
+	err = db.QueryRow(query, ticketId, customerId).Scan(&ticket.ID, &seat.ID, &seat.Row, &seat.Number, &payment.ID, &payment.Amount, &payment.Currency, &payment.Status, &schedule.ID, &schedule.Price, &schedule.Currency, &schedule.Showtime, &movie.ID, &movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate, &branch.ID, &branch.Name, &branch.Address, &theatre.ID, &theatre.Name, &cancelledAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			response := models.Response{
@@ -311,12 +289,21 @@ func GetTicket(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	payment.Breakdown, err = loadPriceBreakdown(db, payment.ID)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	branch.Theatre = theatre
 	schedule.Branch = &branch
 	schedule.Movie = &movie
 	ticket.Schedule = schedule
 	ticket.Payment = payment
 	ticket.Seat = seat
+	if cancelledAt.Valid {
+		ticket.CancelledAt = &cancelledAt.Time
+	}
 
 	responseData := models.TicketResponse{
 		Response: models.Response{
@@ -331,10 +318,12 @@ func GetTicket(c *gin.Context) {
 
 // ConfirmPayment godoc
 // @Summary Confirm Payment
-// @Description Confirm payment with payment_id.
+// @Description Charge the payment of the ticket through the payment provider.
 // @Tags Customer
 // @Param customerId path int true "Customer ID"
 // @Param ticketId path string true "payment id"
+// @Param method query string false "wallet to pay from the customer wallet, otherwise the payment provider is charged"
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried"
 // @Accept json
 // @Produce json
 // @Success 200 {object} models.TicketResponse
@@ -393,33 +382,29 @@ func ConfirmPayment(c *gin.Context) {
 
 	payment.ID = int(paymentID.Int64)
 
-	// set payment into completed
-	res, err := db.Exec("update payment set payment_status = 'completed' where id = ?", payment.ID)
+	payment, err = payPayment(db, payment.ID, c.Query("method"))
 	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": error.Error()})
-		return
-	}
-
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if rowsAffected == 0 {
-		response := models.Response{
-			Status:  404,
-			Message: "payment not found",
+		switch err {
+		case errPaymentNotPending:
+			response := models.Response{
+				Status:  404,
+				Message: "payment not found",
+			}
+			c.JSON(http.StatusNotFound, response)
+		case errPaymentDeclined, errInsufficientBalance, errCurrencyMismatch:
+			c.JSON(http.StatusPaymentRequired, models.Response{Status: 402, Message: err.Error()})
+		case errPaymentProcessing:
+			c.JSON(http.StatusAccepted, models.Response{Status: 202, Message: err.Error()})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
 		}
-		c.JSON(http.StatusNotFound, response)
 		return
 	}
 
 	// get seat data
 	var seat models.Seat
-	error = db.QueryRow("select s.id, s.row, s.seat_number from seat s join ticket t on s.id = t.schedule_id where t.id = ?", ticket.ID).Scan(&seat.ID, &seat.Row, &seat.Number)
+	error = db.QueryRow("select s.id, s.row, s.seat_number from seat s join ticket t on s.id = t.seat_id where t.id = ?", ticket.ID).Scan(&seat.ID, &seat.Row, &seat.Number)
 	if error != nil {
 		log.Println(error)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": error.Error()})
@@ -427,13 +412,6 @@ func ConfirmPayment(c *gin.Context) {
 	}
 	ticket.Seat = seat
 
-	// get payment data
-	error = db.QueryRow("select amount, payment_status from payment where id = ?", payment.ID).Scan(&payment.Amount, &payment.Status)
-	if error != nil {
-		log.Println(error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": error.Error()})
-		return
-	}
 	ticket.Payment = payment
 
 	// get schedule data
@@ -441,7 +419,7 @@ func ConfirmPayment(c *gin.Context) {
 	var movie models.Movie
 	var theatre models.Theatre
 	var branch models.BranchTheatre
-	error = db.QueryRow("select m.id, m.title, m.description, m.duration, m.rating, m.release_date, t.id, t.name, b.id, b.name, b.address, s.id, s.show_time, s.price from movie m join schedule s on s.movie_id = m.id join theatre t on t.id = s.theatre_id join branch b on b.id = t.branch_id join ticket tc on tc.schedule_id = s.id where tc.id = ?", ticket.ID).Scan(&movie.ID, &movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate, &theatre.ID, &theatre.Name, &branch.ID, &branch.Name, &branch.Address, &schedule.ID, &schedule.Showtime, &schedule.Price)
+	error = db.QueryRow("select m.id, m.title, m.description, m.duration, m.rating, m.release_date, t.id, t.name, b.id, b.name, b.address, s.id, s.show_time, s.price, s.currency from movie m join schedule s on s.movie_id = m.id join theatre t on t.id = s.theatre_id join branch b on b.id = t.branch_id join ticket tc on tc.schedule_id = s.id where tc.id = ?", ticket.ID).Scan(&movie.ID, &movie.Title, &movie.Description, &movie.Duration, &movie.Rating, &movie.ReleaseDate, &theatre.ID, &theatre.Name, &branch.ID, &branch.Name, &branch.Address, &schedule.ID, &schedule.Showtime, &schedule.Price, &schedule.Currency)
 	if error != nil {
 		log.Println(error)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": error.Error()})
@@ -452,21 +430,6 @@ func ConfirmPayment(c *gin.Context) {
 	schedule.Movie = &movie
 	ticket.Schedule = schedule
 
-	var customer models.Customer
-	// Check if schedule exists in database
-	if err := db.QueryRow("SELECT name, email FROM customer WHERE id = ?", customerIdParam).Scan(
-		&customer.Name,
-		&customer.Email,
-	); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
-		return
-	}
-	schedule.Seat = &models.Seat{}
-	schedule.Seat.Number = ticket.Seat.Number
-	schedule.Seat.Number = ticket.Seat.Row
-	content := tool.GeneratePaymentEmail(customer, payment, schedule)
-	go tool.SendEmail(content, customer.Email, "[TIX-ID] Payment Successful")
-
 	responseData := models.TicketResponse{
 		Response: models.Response{
 			Status:  200,
@@ -477,3 +440,98 @@ func ConfirmPayment(c *gin.Context) {
 
 	c.JSON(http.StatusOK, responseData)
 }
+
+// CancelTicket godoc
+// @Summary Cancel Ticket
+// @Description Cancel a ticket, release its seat and refund it according to the refund policy
+// @Tags Customer
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param ticketId path int true "Ticket ID"
+// @Success 200 {object} models.RefundResponse
+// @Router /customer/{customerId}/tickets/{ticketId} [delete]
+func CancelTicket(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	ticketId, err := strconv.Atoi(c.Param("ticketId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	refund, status, err := cancelBooking(tx, ticketId, int(customerId), tool.LoadRefundPolicy(), time.Now())
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			response := models.Response{
+				Status:  404,
+				Message: "the ticket is not found!",
+			}
+			c.JSON(http.StatusNotFound, response)
+		case errTicketNotCancellable:
+			c.JSON(http.StatusConflict, models.Response{Status: 409, Message: err.Error()})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	// the money goes back before the cancellation is committed, so a failed refund keeps the ticket
+	if status == models.Completed {
+		if err := refundCharge(tx, refund.PaymentID, refund.Amount); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// only paid tickets get a refund confirmation
+	if status == models.Completed {
+		var ticket models.Ticket
+		var scheduleId int
+		var customer models.Customer
+		err := db.QueryRow("select tc.id, tc.schedule_id, se.row, se.seat_number, cu.name, cu.email from ticket tc join seat se on se.id = tc.seat_id join customer cu on cu.id = tc.customer_id where tc.id = ?", ticketId).Scan(&ticket.ID, &scheduleId, &ticket.Seat.Row, &ticket.Seat.Number, &customer.Name, &customer.Email)
+		if err == nil {
+			ticket.Schedule, err = getScheduleTicket(db, scheduleId)
+		}
+		if err != nil {
+			log.Println(err)
+		} else {
+			content := tool.GenerateRefundEmail(customer, ticket, refund)
+			go tool.SendEmail(content, customer.Email, "[TIX-ID] Ticket Cancelled")
+		}
+	}
+
+	responseData := models.RefundResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Ticket cancelled successfully",
+		},
+		Refund: refund,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
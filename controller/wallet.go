@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"tix-id/models"
+	"tix-id/tool"
+)
+
+// The wallet is a double-entry ledger: every transaction moves money from one
+// account to another, so its entries always sum to zero. Money enters through
+// the gateway account on top-ups and leaves to the sales account on payments.
+const (
+	walletGatewayAccount = "gateway"
+	walletSalesAccount   = "sales"
+)
+
+var errInsufficientBalance = errors.New("the wallet balance is not enough")
+
+func customerAccountName(customerId int) string {
+	return fmt.Sprintf("customer:%d", customerId)
+}
+
+// walletAccount returns the wallet account of the customer, creating it on
+// first use. The row stays locked until the transaction ends, which keeps
+// concurrent debits from overdrawing the wallet.
+func walletAccount(q queryer, customerId int) (int, error) {
+	if _, err := q.Exec("insert ignore into wallet_account (name, customer_id) values (?, ?)", customerAccountName(customerId), customerId); err != nil {
+		return 0, err
+	}
+	var accountId int
+	err := q.QueryRow("select id from wallet_account where customer_id = ? for update", customerId).Scan(&accountId)
+	return accountId, err
+}
+
+// walletCurrency returns the currency of the customer's wallet, the one
+// wallets are opened in when the customer has none yet.
+func walletCurrency(q queryer, customerId int) (models.Currency, error) {
+	var currency models.Currency
+	err := q.QueryRow("select currency from wallet_account where customer_id = ?", customerId).Scan(&currency)
+	if err == sql.ErrNoRows {
+		return models.DefaultCurrency, nil
+	}
+	return currency, err
+}
+
+func systemAccount(q queryer, name string) (int, error) {
+	var accountId int
+	err := q.QueryRow("select id from wallet_account where name = ? and customer_id is null", name).Scan(&accountId)
+	return accountId, err
+}
+
+func walletBalance(q queryer, accountId int) (models.Money, error) {
+	var balance models.Money
+	err := q.QueryRow("select coalesce(sum(amount), 0) from wallet_entry where account_id = ?", accountId).Scan(&balance)
+	return balance, err
+}
+
+// postTransfer records a transaction moving the amount between two accounts.
+// Ledger rows are never updated or deleted, a mistake is corrected by a new
+// transaction.
+func postTransfer(q queryer, kind models.WalletTransactionType, paymentId int, description string, fromAccount, toAccount int, amount models.Money) error {
+	res, err := q.Exec("insert into wallet_transaction (type, payment_id, description, created_at) values (?, ?, ?, ?)", kind, paymentId, description, time.Now())
+	if err != nil {
+		return err
+	}
+	transactionId, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err := q.Exec("insert into wallet_entry (transaction_id, account_id, amount) values (?, ?, ?), (?, ?, ?)", transactionId, fromAccount, -amount, transactionId, toAccount, amount); err != nil {
+		return err
+	}
+	return nil
+}
+
+// creditTopUp puts a completed top-up into the customer's wallet.
+func creditTopUp(q queryer, customerId, paymentId int, amount models.Money) error {
+	gateway, err := systemAccount(q, walletGatewayAccount)
+	if err != nil {
+		return err
+	}
+	account, err := walletAccount(q, customerId)
+	if err != nil {
+		return err
+	}
+	return postTransfer(q, models.WalletTopUp, paymentId, fmt.Sprintf("Top-up by payment #%d", paymentId), gateway, account, amount)
+}
+
+// refundToWallet gives the refunded amount of a wallet payment back to the wallet.
+func refundToWallet(q queryer, paymentId int, amount models.Money) error {
+	var customerId int
+	if err := q.QueryRow("select customer_id from ticket where payment_id = ? limit 1", paymentId).Scan(&customerId); err != nil {
+		return err
+	}
+	sales, err := systemAccount(q, walletSalesAccount)
+	if err != nil {
+		return err
+	}
+	account, err := walletAccount(q, customerId)
+	if err != nil {
+		return err
+	}
+	return postTransfer(q, models.WalletRefund, paymentId, fmt.Sprintf("Refund of payment #%d", paymentId), sales, account, amount)
+}
+
+// payWithWallet pays a pending payment from the wallet of the customer who
+// booked it, all in one transaction. The wallet only pays in its own currency,
+// and not for a payment that already has a charge with the payment provider,
+// which may still be captured.
+func payWithWallet(db *sql.DB, paymentId int) (models.Payment, error) {
+	payment := models.Payment{ID: paymentId, Provider: tool.WalletProvider}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return payment, err
+	}
+	defer tx.Rollback()
+
+	var customerId int
+	var chargeId sql.NullString
+	err = tx.QueryRow("select p.amount, p.currency, p.payment_status, p.charge_id, tc.customer_id from payment p join ticket tc on tc.payment_id = p.id where p.id = ? limit 1 for update", paymentId).Scan(&payment.Amount, &payment.Currency, &payment.Status, &chargeId, &customerId)
+	if err != nil {
+		return payment, err
+	}
+	if payment.Status != models.Pending {
+		return payment, errPaymentNotPending
+	}
+	if chargeId.Valid {
+		return payment, errPaymentProcessing
+	}
+
+	account, err := walletAccount(tx, customerId)
+	if err != nil {
+		return payment, err
+	}
+	currency, err := walletCurrency(tx, customerId)
+	if err != nil {
+		return payment, err
+	}
+	if currency != payment.Currency {
+		return payment, errCurrencyMismatch
+	}
+	balance, err := walletBalance(tx, account)
+	if err != nil {
+		return payment, err
+	}
+	if balance < payment.Amount {
+		return payment, errInsufficientBalance
+	}
+	sales, err := systemAccount(tx, walletSalesAccount)
+	if err != nil {
+		return payment, err
+	}
+	if err := postTransfer(tx, models.WalletPayment, paymentId, fmt.Sprintf("Payment #%d", paymentId), account, sales, payment.Amount); err != nil {
+		return payment, err
+	}
+
+	if _, err := tx.Exec("update payment set provider = ? where id = ?", tool.WalletProvider, paymentId); err != nil {
+		return payment, err
+	}
+	moved, err := transitionPayment(tx, paymentId, models.Completed)
+	if err != nil {
+		return payment, err
+	}
+	if !moved {
+		return payment, errPaymentNotPending
+	}
+	if err := completePayment(tx, paymentId, time.Now()); err != nil {
+		return payment, err
+	}
+	if err := tx.Commit(); err != nil {
+		return payment, err
+	}
+	payment.Status = models.Completed
+
+	if err := onPaymentCompleted(db, paymentId); err != nil {
+		log.Println(err)
+	}
+	return payment, nil
+}
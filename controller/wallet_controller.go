@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"tix-id/config"
+	"tix-id/middleware"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetWallet godoc
+// @Summary Get Wallet
+// @Description Get the wallet balance and the wallet transactions of the customer, newest first
+// @Tags Customer
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param limit query int false "Entries per page, 20 by default"
+// @Param offset query int false "Entries to skip"
+// @Success 200 {object} models.WalletResponse
+// @Router /customer/{customerId}/wallet [get]
+func GetWallet(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	wallet := models.Wallet{Entries: []models.WalletEntry{}, Paging: models.Paging{Limit: 20}}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= 100 {
+		wallet.Paging.Limit = limit
+	}
+	if offset, err := strconv.Atoi(c.Query("offset")); err == nil && offset > 0 {
+		wallet.Paging.Offset = offset
+	}
+
+	wallet.Currency = models.DefaultCurrency
+	var accountId int
+	err = db.QueryRow("select id, currency from wallet_account where customer_id = ?", customerId).Scan(&accountId, &wallet.Currency)
+	if err == sql.ErrNoRows {
+		// the account is only opened by the first top-up
+		c.JSON(http.StatusOK, models.WalletResponse{
+			Response: models.Response{Status: 200, Message: "Wallet retrieved successfully"},
+			Wallet:   wallet,
+		})
+		return
+	} else if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if wallet.Balance, err = walletBalance(db, accountId); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.QueryRow("select count(*) from wallet_entry where account_id = ?", accountId).Scan(&wallet.Paging.Total); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := db.Query("select e.id, t.id, t.type, t.payment_id, e.amount, t.description, t.created_at from wallet_entry e join wallet_transaction t on t.id = e.transaction_id where e.account_id = ? order by e.id desc limit ? offset ?", accountId, wallet.Paging.Limit, wallet.Paging.Offset)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entry models.WalletEntry
+		var paymentId sql.NullInt64
+		if err := rows.Scan(&entry.ID, &entry.TransactionID, &entry.Type, &paymentId, &entry.Amount, &entry.Description, &entry.CreatedAt); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if paymentId.Valid {
+			id := int(paymentId.Int64)
+			entry.PaymentID = &id
+		}
+		wallet.Entries = append(wallet.Entries, entry)
+	}
+
+	responseData := models.WalletResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Wallet retrieved successfully",
+		},
+		Wallet: wallet,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
+
+// TopUpWallet godoc
+// @Summary Top Up Wallet
+// @Description Add money to the wallet through the payment provider. The wallet is credited once the payment completes.
+// @Tags Customer
+// @Accept json
+// @Produce json
+// @Param customerId path int true "Customer ID"
+// @Param body body models.TopUpRequest true "Top-up amount"
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried"
+// @Success 200 {object} models.TopUpResponse
+// @Router /customer/{customerId}/wallet/topups [post]
+func TopUpWallet(c *gin.Context) {
+	db := config.ConnectDB()
+	defer db.Close()
+
+	customerIdParam, err := strconv.Atoi(c.Param("customerId"))
+	customerId, _, _ := middleware.GetUserIdAndRoleFromCookie(c)
+	if err != nil || customerIdParam != int(customerId) {
+		response := models.Response{
+			Status:  200,
+			Message: "The user id didn't matched",
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	var request models.TopUpRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	amount := request.Amount
+	if amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the top-up amount must be positive"})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	currency, err := walletCurrency(tx, int(customerId))
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := tx.Exec("insert into payment(amount, currency, payment_status) values (?, ?, 'pending')", amount, currency)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	paymentId, err := res.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	res, err = tx.Exec("insert into wallet_topup (customer_id, payment_id, amount) values (?, ?, ?)", customerId, paymentId, amount)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	topUpId, err := res.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	topUp := models.TopUp{ID: int(topUpId), Amount: amount}
+	provider, err := tool.NewPaymentProvider()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	topUp.Payment, err = chargePayment(db, provider, int(paymentId))
+	if err != nil {
+		switch err {
+		case errPaymentDeclined:
+			c.JSON(http.StatusPaymentRequired, models.Response{Status: 402, Message: err.Error()})
+		case errPaymentProcessing:
+			c.JSON(http.StatusAccepted, models.TopUpResponse{
+				Response: models.Response{Status: 202, Message: err.Error()},
+				TopUp:    topUp,
+			})
+		default:
+			log.Println(err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	responseData := models.TopUpResponse{
+		Response: models.Response{
+			Status:  200,
+			Message: "Wallet topped up successfully",
+		},
+		TopUp: topUp,
+	}
+	c.JSON(http.StatusOK, responseData)
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+	"tix-id/config"
+	"tix-id/models"
+	"tix-id/tool"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+)
+
+// PaymentWebhook godoc
+// @Summary Payment Webhook
+// @Description Receive a payment event from the payment provider. The event must be signed, and each event is processed only once.
+// @Tags Payment
+// @Accept json
+// @Produce json
+// @Param X-Tix-Signature header string true "Hex encoded HMAC-SHA256 of the body"
+// @Param body body models.PaymentEvent true "Payment event"
+// @Success 200 {object} models.Response
+// @Router /payments/webhook [post]
+func PaymentWebhook(c *gin.Context) {
+	provider, err := tool.NewPaymentProvider()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	payload, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := provider.VerifyWebhook(payload, c.GetHeader(tool.WebhookSignatureHeader)); err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: 401, Message: err.Error()})
+		return
+	}
+	var event models.PaymentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if event.ID == "" || event.ChargeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the event has no id or charge id"})
+		return
+	}
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	var paymentId int
+	if err := db.QueryRow("select id from payment where charge_id = ?", event.ChargeID).Scan(&paymentId); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.Response{Status: 404, Message: tool.ErrChargeNotFound.Error()})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// providers deliver at least once, the event id tells a redelivery apart,
+	// and the event is only kept along with what it changed
+	_, err = tx.Exec("insert into payment_event (id, payment_id, type, created_at) values (?, ?, ?, ?)", event.ID, paymentId, event.Type, time.Now())
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+			c.JSON(http.StatusOK, models.Response{Status: 200, Message: "Payment event already processed"})
+			return
+		}
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	charge := models.Charge{
+		ID:        event.ChargeID,
+		PaymentID: paymentId,
+		Amount:    event.Amount,
+		Currency:  event.Currency,
+		Status:    event.Status,
+	}
+	status, moved, err := applyChargeTx(tx, paymentId, charge)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	afterCharge(db, provider, paymentId, charge, status, moved)
+
+	c.JSON(http.StatusOK, models.Response{Status: 200, Message: "Payment event processed, the payment is " + string(status)})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"tix-id/controller"
 	"tix-id/docs"
 	"tix-id/routes"
 	"tix-id/tool"
@@ -24,7 +25,13 @@ func main() {
 	docs.SwaggerInfo.Host = "localhost:8080"
 	docs.SwaggerInfo.Schemes = []string{"http", "https"}
 
-	go tool.CronTicketExpiry()
+	// payments can't be taken without a provider, so don't start without one
+	if _, err := tool.NewPaymentProvider(); err != nil {
+		log.Fatal(err)
+	}
+
+	go tool.CronTicketExpiry(controller.SettleCharge)
+	go tool.CronPointExpiry()
 
 	r := routes.SetupRouter()
 	r.Run(":8080")
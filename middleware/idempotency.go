@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+	"tix-id/tool"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	idempotencyTTL    = 24 * time.Hour
+)
+
+// idempotentResponse is what is stored under an idempotency key. It has no
+// status while the first request is still running.
+type idempotentResponse struct {
+	RequestHash string `json:"requestHash"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// responseRecorder keeps a copy of everything the handler writes.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// finalStatus tells if a response is the final outcome of the request. A
+// payment still being processed (202), a declined one (402) and a conflict
+// with the state at the time (409), like a seat held by someone else, may end
+// differently when retried, and so may timeouts, rate limits and server errors.
+func finalStatus(status int) bool {
+	switch status {
+	case http.StatusAccepted, http.StatusPaymentRequired, http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+// Idempotency makes a request safe to retry. When the client sends an
+// Idempotency-Key header, the first response is stored in Redis and replayed
+// for every repeat with the same key, query and body. Reusing a key with a
+// different query or body is a conflict. Only final responses are stored, see
+// finalStatus, so the others can be retried with the same key.
+// It must run after AuthMiddleware, since keys are scoped to the user.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		// the query is part of the request, e.g. the payment method
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.URL.RawQuery + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		userId, _ := c.Get("userId")
+		redisKey := fmt.Sprintf("idempotency:%v:%s:%s:%s", userId, c.Request.Method, c.Request.URL.Path, key)
+
+		redisClient := tool.NewRedisClient()
+		defer redisClient.Close()
+
+		pending, _ := json.Marshal(idempotentResponse{RequestHash: requestHash})
+		ok, err := redisClient.SetNX(redisKey, pending, idempotencyTTL).Result()
+		if err != nil {
+			log.Println(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !ok {
+			value, err := tool.GetRedisValue(redisClient, redisKey)
+			if err != nil {
+				log.Println(err)
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "the request with this idempotency key is still being processed"})
+				return
+			}
+			var stored idempotentResponse
+			if err := json.Unmarshal([]byte(value), &stored); err != nil {
+				log.Println(err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if stored.RequestHash != requestHash {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "the idempotency key was already used with a different request"})
+				return
+			}
+			if stored.Status == 0 {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "the request with this idempotency key is still being processed"})
+				return
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.Status, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		// the key is freed unless a response gets stored, also when the
+		// handler panics, or it would stay pending until it expires
+		stored := false
+		defer func() {
+			if !stored {
+				redisClient.Del(redisKey)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if !finalStatus(status) {
+			return
+		}
+		response, err := json.Marshal(idempotentResponse{
+			RequestHash: requestHash,
+			Status:      status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err == nil {
+			err = tool.SetRedisValue(redisClient, redisKey, string(response), idempotencyTTL)
+		}
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		stored = true
+	}
+}
@@ -1,10 +1,15 @@
 package models
 
 type Branch struct {
-	ID       int        `json:"id,omitempty"`
-	Name     string     `json:"name"`
-	Address  string     `json:"address"`
-	Theatres *[]Theatre `json:"theatres,omitempty"`
+	ID      int    `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// City is where the branch is, for customers to find the movies showing
+	// in their city.
+	City string `json:"city,omitempty"`
+	// TaxJurisdictionID is where the branch pays its entertainment tax.
+	TaxJurisdictionID *int       `json:"taxJurisdictionId,omitempty"`
+	Theatres          *[]Theatre `json:"theatres,omitempty"`
 }
 
 type BranchTheatre struct {
@@ -0,0 +1,39 @@
+package models
+
+import "strings"
+
+// Format is how a schedule shows its movie. A theatre can only project the
+// formats it is equipped for, and a movie only plays in the formats it is
+// released in.
+type Format string
+
+const (
+	Format2D         Format = "2D"
+	Format3D         Format = "3D"
+	FormatIMAX       Format = "IMAX"
+	Format4DX        Format = "4DX"
+	FormatDolbyAtmos Format = "DOLBY_ATMOS"
+)
+
+// DefaultFormat is what every theatre and movie supports unless told
+// otherwise, and what a schedule without a format is shown in.
+const DefaultFormat = Format2D
+
+func (f Format) Valid() bool {
+	switch f {
+	case Format2D, Format3D, FormatIMAX, Format4DX, FormatDolbyAtmos:
+		return true
+	}
+	return false
+}
+
+// ParseFormat reads a format written in any case, e.g. "imax" or
+// "Dolby Atmos". An empty one is DefaultFormat.
+func ParseFormat(s string) (Format, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return DefaultFormat, true
+	}
+	f := Format(strings.ToUpper(strings.ReplaceAll(s, " ", "_")))
+	return f, f.Valid()
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+type GiftCardStatus string
+
+const (
+	// GiftCardPending cards are bought but not paid yet.
+	GiftCardPending  GiftCardStatus = "pending"
+	GiftCardActive   GiftCardStatus = "active"
+	GiftCardInactive GiftCardStatus = "inactive"
+)
+
+type GiftCard struct {
+	ID             int            `json:"id"`
+	Code           string         `json:"code"`
+	Amount         Money          `json:"amount"`
+	Balance        Money          `json:"balance"`
+	Currency       Currency       `json:"currency"`
+	Status         GiftCardStatus `json:"status"`
+	RecipientName  string         `json:"recipientName,omitempty"`
+	RecipientEmail string         `json:"recipientEmail,omitempty"`
+	Message        string         `json:"message,omitempty"`
+	Batch          string         `json:"batch,omitempty"`
+	ExpiresAt      *time.Time     `json:"expiresAt,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt"`
+}
+
+type GiftCardPurchaseRequest struct {
+	Amount         Money    `json:"amount"`
+	Currency       Currency `json:"currency"`
+	RecipientName  string   `json:"recipientName"`
+	RecipientEmail string   `json:"recipientEmail"`
+	Message        string   `json:"message"`
+}
+
+type GiftCardPurchase struct {
+	GiftCard GiftCard `json:"giftCard"`
+	Payment  Payment  `json:"payment"`
+}
+
+type GiftCardPurchaseResponse struct {
+	Response
+	Purchase GiftCardPurchase `json:"data"`
+}
+
+// GiftCardBatchRequest issues many cards at once, e.g. for a corporate client.
+type GiftCardBatchRequest struct {
+	Count     int        `json:"count"`
+	Amount    Money      `json:"amount"`
+	Currency  Currency   `json:"currency"`
+	Batch     string     `json:"batch"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type GiftCardResponse struct {
+	Response
+	GiftCard GiftCard `json:"data"`
+}
+
+type GiftCardsResponse struct {
+	Response
+	GiftCards []GiftCard `json:"data"`
+	Paging    Paging     `json:"paging"`
+}
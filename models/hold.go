@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type SeatHold struct {
+	Token      string `json:"token"`
+	CustomerID int    `json:"customerId"`
+	ScheduleID int    `json:"scheduleId"`
+	SeatIDs    []int  `json:"seatIds"`
+	// Prices are the seat prices locked in when the seats were held.
+	Prices    map[int]Money `json:"prices,omitempty"`
+	Extended  bool          `json:"extended"`
+	ExpiresAt time.Time     `json:"expiresAt"`
+}
+
+type SeatHoldRequest struct {
+	ScheduleID int   `json:"scheduleId"`
+	SeatIDs    []int `json:"seatIds"`
+	// Accessible tells the customer needs a wheelchair space.
+	Accessible bool `json:"accessible,omitempty"`
+}
+
+type SeatHoldResponse struct {
+	Response
+	Hold SeatHold `json:"data"`
+}
@@ -0,0 +1,52 @@
+package models
+
+// ImportKind is what a bulk import file holds.
+type ImportKind string
+
+const (
+	ImportMovies    ImportKind = "movies"
+	ImportSchedules ImportKind = "schedules"
+)
+
+func (k ImportKind) Valid() bool {
+	return k == ImportMovies || k == ImportSchedules
+}
+
+// ScheduleImport is a row of a schedule import. The movie is given by its id
+// or by its exact title, and the show time as RFC 3339 or "YYYY-MM-DD HH:MM"
+// in the local time of the cinemas. A row with an id updates that schedule.
+type ScheduleImport struct {
+	ID         int                `json:"id,omitempty"`
+	MovieID    int                `json:"movieId,omitempty"`
+	MovieTitle string             `json:"movieTitle,omitempty"`
+	TheatreID  int                `json:"theatreId"`
+	Showtime   string             `json:"showtime"`
+	Price      Money              `json:"price"`
+	Prices     map[SeatType]Money `json:"prices,omitempty"`
+	Format     string             `json:"format,omitempty"`
+}
+
+// ImportedRow is the outcome of a row of an import. Row counts from 1 for
+// JSON and is the line number for CSV, header included.
+type ImportedRow struct {
+	Row    int    `json:"row"`
+	ID     int    `json:"id,omitempty"`
+	Action string `json:"action,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportResult reports every row of an import. Nothing is written when any
+// row fails or on a dry run.
+type ImportResult struct {
+	Kind    ImportKind    `json:"kind"`
+	DryRun  bool          `json:"dryRun"`
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Failed  int           `json:"failed"`
+	Rows    []ImportedRow `json:"rows"`
+}
+
+type ImportResponse struct {
+	Response
+	Result ImportResult `json:"data"`
+}
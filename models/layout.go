@@ -0,0 +1,28 @@
+package models
+
+type SeatLayout struct {
+	TheatreID int         `json:"theatreId,omitempty"`
+	Rows      []LayoutRow `json:"rows"`
+}
+
+// LayoutRow describes one row of a theatre. Seats are numbered 1..Count from
+// left to right, skipping the positions listed in Gaps (aisles).
+type LayoutRow struct {
+	Row       string           `json:"row"`
+	Count     int              `json:"count"`
+	Gaps      []int            `json:"gaps,omitempty"`
+	Type      SeatType         `json:"type,omitempty"`
+	SeatTypes map[int]SeatType `json:"seatTypes,omitempty"`
+}
+
+type SeatLayoutResponse struct {
+	Response
+	Layout SeatLayout `json:"data"`
+}
+
+// SeatOverride changes a seat for one showtime. Whatever is left out stays
+// as it is.
+type SeatOverride struct {
+	Blocked *bool    `json:"blocked,omitempty"`
+	Type    SeatType `json:"type,omitempty"`
+}
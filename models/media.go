@@ -0,0 +1,30 @@
+package models
+
+// MediaKind is what an image of a movie is used for.
+type MediaKind string
+
+const (
+	PosterMedia   MediaKind = "poster"
+	BackdropMedia MediaKind = "backdrop"
+)
+
+func (k MediaKind) Valid() bool {
+	return k == PosterMedia || k == BackdropMedia
+}
+
+// ImageURLs are the URLs of an image by size: the original upload, then
+// large, medium and small JPEGs of it.
+type ImageURLs map[string]string
+
+type MovieMedia struct {
+	MovieID int       `json:"movieId"`
+	Kind    MediaKind `json:"kind"`
+	Width   int       `json:"width"`
+	Height  int       `json:"height"`
+	URLs    ImageURLs `json:"urls"`
+}
+
+type MovieMediaResponse struct {
+	Response
+	Media MovieMedia `json:"data"`
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency is the ISO 4217 code of the currency an amount is in.
+type Currency string
+
+const (
+	IDR Currency = "IDR"
+	// DefaultCurrency is the currency of the records created without one.
+	DefaultCurrency = IDR
+)
+
+// MinorUnits is the number of decimals every supported currency has.
+const MinorUnits = 2
+
+const minorPerMajor = 100
+
+// supportedCurrencies are the currencies with MinorUnits decimals that
+// prices, payments, gift cards and wallets can be in.
+var supportedCurrencies = map[Currency]bool{
+	IDR: true,
+}
+
+// Valid reports whether amounts can be in the currency.
+func (c Currency) Valid() bool {
+	return supportedCurrencies[c]
+}
+
+var errInvalidMoney = errors.New("invalid amount of money")
+
+// Money is an exact amount in minor units, so 1 rupiah is 100. Its currency
+// is the one of the schedule, payment, refund, gift card or wallet it
+// belongs to, which is stored in their currency column and sent next to it
+// as "currency". It scans from and writes to decimal columns and encodes as
+// a JSON number with MinorUnits decimals.
+type Money int64
+
+// NewMoney returns the amount of major units, e.g. NewMoney(45000) is
+// IDR 45,000.00.
+func NewMoney(major int64) Money {
+	return Money(major * minorPerMajor)
+}
+
+// MoneyFromFloat rounds a float to the nearest minor unit. It is meant for
+// values that were a float to begin with, not for arithmetic.
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * minorPerMajor))
+}
+
+// ParseMoney parses a decimal such as "45000", "-12.5" or "0.05" exactly.
+// More than MinorUnits decimals is an error rather than being rounded.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	major, minor := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		major, minor = s[:i], s[i+1:]
+	}
+	if major == "" && minor == "" || len(minor) > MinorUnits {
+		return 0, errInvalidMoney
+	}
+	minor = (minor + strings.Repeat("0", MinorUnits))[:MinorUnits]
+	var units int64
+	for _, part := range []string{major, minor} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, errInvalidMoney
+			}
+			if units > (math.MaxInt64-9)/10 {
+				return 0, errInvalidMoney
+			}
+			units = units*10 + int64(r-'0')
+		}
+	}
+	if negative {
+		units = -units
+	}
+	return Money(units), nil
+}
+
+// Mul returns the amount times n, e.g. the price of n seats.
+func (m Money) Mul(n int) Money {
+	return m * Money(n)
+}
+
+// Percent returns p percent of the amount, rounded half away from zero.
+func (m Money) Percent(p float64) Money {
+	return Money(math.Round(float64(m) * p / 100))
+}
+
+// Share returns the part of the amount that part is of whole, rounded half
+// away from zero. It is how a payment is split over its tickets.
+func (m Money) Share(part, whole Money) Money {
+	if whole == 0 {
+		return 0
+	}
+	return Money(math.Round(float64(m) * float64(part) / float64(whole)))
+}
+
+// Min returns the smaller of the two amounts.
+func (m Money) Min(o Money) Money {
+	if o < m {
+		return o
+	}
+	return m
+}
+
+// Major returns the whole units of the amount, dropping the minor units.
+func (m Money) Major() int64 {
+	return int64(m) / minorPerMajor
+}
+
+// Float64 returns the amount in major units, for display and ratios only.
+func (m Money) Float64() float64 {
+	return float64(m) / minorPerMajor
+}
+
+// String returns the amount as a plain decimal, e.g. "45000.00".
+func (m Money) String() string {
+	sign, units := "", int64(m)
+	if units < 0 {
+		sign, units = "-", -units
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, units/minorPerMajor, MinorUnits, units%minorPerMajor)
+}
+
+// Format returns the amount in the currency for people to read, e.g.
+// "IDR 45,000.00".
+func (m Money) Format(currency Currency) string {
+	s := m.String()
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	major, minor := s[:len(s)-MinorUnits-1], s[len(s)-MinorUnits:]
+	var grouped strings.Builder
+	for i, r := range major {
+		if i > 0 && (len(major)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(r)
+	}
+	return string(currency) + " " + sign + grouped.String() + "." + minor
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a number or a string holding one.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return fmt.Errorf("%w %q", errInvalidMoney, string(data))
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan reads decimal columns exactly. Double columns and computed floats are
+// rounded to the nearest minor unit.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case int64:
+		*m = NewMoney(v)
+		return nil
+	case float64:
+		*m = MoneyFromFloat(v)
+		return nil
+	case float32:
+		*m = MoneyFromFloat(float64(v))
+		return nil
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	}
+	return fmt.Errorf("cannot scan %T into Money", src)
+}
+
+func (m *Money) scanString(s string) error {
+	parsed, err := ParseMoney(s)
+	if err == nil {
+		*m = parsed
+		return nil
+	}
+	// a double column or an average can have more decimals than a decimal one
+	f, ferr := strconv.ParseFloat(s, 64)
+	if ferr != nil {
+		return fmt.Errorf("%w %q", errInvalidMoney, s)
+	}
+	*m = MoneyFromFloat(f)
+	return nil
+}
+
+// Value writes the amount as a decimal string, so MySQL stores it exactly.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
@@ -1,5 +1,52 @@
 package models
 
+// Classification is the age classification of the Lembaga Sensor Film.
+type Classification string
+
+const (
+	AllAges Classification = "SU"
+	Teen    Classification = "13+"
+	Mature  Classification = "17+"
+	Adult   Classification = "21+"
+)
+
+func (c Classification) Valid() bool {
+	switch c {
+	case AllAges, Teen, Mature, Adult:
+		return true
+	}
+	return false
+}
+
+// Restricted tells if viewers must confirm their age to book the movie.
+func (c Classification) Restricted() bool {
+	return c != "" && c != AllAges
+}
+
+type CreditRole string
+
+const (
+	CastCredit     CreditRole = "cast"
+	DirectorCredit CreditRole = "director"
+	WriterCredit   CreditRole = "writer"
+	ProducerCredit CreditRole = "producer"
+)
+
+func (r CreditRole) Valid() bool {
+	switch r {
+	case CastCredit, DirectorCredit, WriterCredit, ProducerCredit:
+		return true
+	}
+	return false
+}
+
+// Credit is a person in the cast or crew of a movie, in billing order.
+type Credit struct {
+	Name      string     `json:"name"`
+	Role      CreditRole `json:"role"`
+	Character string     `json:"character,omitempty"`
+}
+
 type Movie struct {
 	ID          int     `json:"id,omitempty"`
 	Title       string  `json:"title"`
@@ -7,6 +54,28 @@ type Movie struct {
 	Duration    int     `json:"duration"`
 	Rating      float32 `json:"rating"`
 	ReleaseDate string  `json:"releaseDate"`
+	// RunStartDate is the first day the movie is shown, YYYY-MM-DD, the day
+	// it is released unless it is given.
+	RunStartDate *string `json:"runStartDate,omitempty"`
+	// RunEndDate is the last day the movie is shown, YYYY-MM-DD, if it is known.
+	RunEndDate     *string        `json:"runEndDate,omitempty"`
+	Classification Classification `json:"classification,omitempty"`
+	Language       string         `json:"language,omitempty"`
+	Subtitles      []string       `json:"subtitles,omitempty"`
+	// Formats are what the movie is released in, 2D only unless given.
+	Formats    []Format `json:"formats,omitempty"`
+	TrailerURL string   `json:"trailerUrl,omitempty"`
+	// Poster and Backdrop are uploaded on their own and ignored by updates.
+	Poster   ImageURLs `json:"poster,omitempty"`
+	Backdrop ImageURLs `json:"backdrop,omitempty"`
+	// Genres and Credits are left as they are by an update that omits them.
+	Genres  []string `json:"genres,omitempty"`
+	Credits []Credit `json:"credits,omitempty"`
+}
+
+type GenresResponse struct {
+	Response
+	Genres []string `json:"data"`
 }
 
 type MovieSchedules struct {
@@ -31,6 +100,15 @@ type MoviesResponse struct {
 	Response
 	Movies []Movie `json:"data"`
 }
+
+// MoviePageResponse is a page of a catalog list such as the movies now
+// showing.
+type MoviePageResponse struct {
+	Response
+	Movies []Movie `json:"data"`
+	Paging Paging  `json:"paging"`
+}
+
 type MovieResponse struct {
 	Response
 	Movie Movie `json:"data"`
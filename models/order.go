@@ -0,0 +1,27 @@
+package models
+
+type Order struct {
+	ID       int            `json:"id"`
+	Schedule ScheduleTicket `json:"schedule"`
+	Tickets  []Ticket       `json:"tickets"`
+	Payment  Payment        `json:"payment"`
+	Amount   Money          `json:"amount"`
+}
+
+type OrderRequest struct {
+	ScheduleID int    `json:"scheduleId"`
+	SeatIDs    []int  `json:"seatIds"`
+	HoldToken  string `json:"holdToken,omitempty"`
+	PromoCode  string `json:"promoCode,omitempty"`
+	Points     int    `json:"points,omitempty"`
+	GiftCard   string `json:"giftCardCode,omitempty"`
+	// AgeConfirmed must be set to book a movie classified 13+ or above.
+	AgeConfirmed bool `json:"ageConfirmed,omitempty"`
+	// Accessible tells the customer needs a wheelchair space.
+	Accessible bool `json:"accessible,omitempty"`
+}
+
+type OrderResponse struct {
+	Response
+	Order Order `json:"data"`
+}
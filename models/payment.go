@@ -1,9 +1,26 @@
 package models
 
+import "time"
+
 type Payment struct {
-	ID     int           `json:"id"`
-	Amount float64       `json:"amount"`
-	Status PaymentStatus `json:"status"`
+	ID     int   `json:"id"`
+	Amount Money `json:"amount"`
+	// Currency is the currency of every amount of the payment.
+	Currency  Currency `json:"currency"`
+	Discount  Money    `json:"discount,omitempty"`
+	PromoCode string   `json:"promoCode,omitempty"`
+	// PointsUsed were redeemed for PointsDiscount, on top of the promo discount.
+	PointsUsed     int   `json:"pointsUsed,omitempty"`
+	PointsDiscount Money `json:"pointsDiscount,omitempty"`
+	// GiftCardAmount is the part of the price paid with a gift card.
+	GiftCardAmount Money `json:"giftCardAmount,omitempty"`
+	// Breakdown is the base price, fees and tax of a booking, nil for
+	// top-ups and gift cards.
+	Breakdown   *PriceBreakdown `json:"breakdown,omitempty"`
+	Status      PaymentStatus   `json:"status"`
+	Provider    string          `json:"provider,omitempty"`
+	ChargeID    string          `json:"chargeId,omitempty"`
+	RedirectURL string          `json:"redirectUrl,omitempty"`
 }
 
 type PaymentStatus string
@@ -12,6 +29,8 @@ const (
 	Pending   PaymentStatus = "pending"
 	Completed PaymentStatus = "completed"
 	Failed    PaymentStatus = "failed"
+	Refunded  PaymentStatus = "refunded"
+	Cancelled PaymentStatus = "cancelled"
 )
 
 type PaymentResponse struct {
@@ -22,3 +41,44 @@ type PaymentsResponse struct {
 	Response
 	Payments []Payment `json:"data"`
 }
+
+type ChargeStatus string
+
+const (
+	ChargePending   ChargeStatus = "pending"
+	ChargeSucceeded ChargeStatus = "succeeded"
+	ChargeDeclined  ChargeStatus = "declined"
+	ChargeRefunded  ChargeStatus = "refunded"
+)
+
+// Charge is a payment as the payment provider sees it.
+type Charge struct {
+	ID             string       `json:"id"`
+	PaymentID      int          `json:"paymentId"`
+	Amount         Money        `json:"amount"`
+	Currency       Currency     `json:"currency"`
+	RefundedAmount Money        `json:"refundedAmount"`
+	Status         ChargeStatus `json:"status"`
+	RedirectURL    string       `json:"redirectUrl,omitempty"`
+	CreatedAt      time.Time    `json:"createdAt"`
+}
+
+type ChargeRequest struct {
+	PaymentID   int
+	Amount      Money
+	Currency    Currency
+	Description string
+	CallbackURL string
+}
+
+// PaymentEvent is the body of a webhook sent by the payment provider.
+type PaymentEvent struct {
+	ID        string       `json:"id"`
+	Type      string       `json:"type"`
+	ChargeID  string       `json:"chargeId"`
+	PaymentID int          `json:"paymentId"`
+	Status    ChargeStatus `json:"status"`
+	Amount    Money        `json:"amount"`
+	Currency  Currency     `json:"currency"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type PointEntryType string
+
+const (
+	EarnPoints    PointEntryType = "earn"
+	RedeemPoints  PointEntryType = "redeem"
+	ReversePoints PointEntryType = "reverse"
+	RestorePoints PointEntryType = "restore"
+	ExpirePoints  PointEntryType = "expire"
+)
+
+// PointEntry is one line of a customer's points ledger. Credits are
+// positive, debits negative.
+type PointEntry struct {
+	ID          int            `json:"id"`
+	PaymentID   *int           `json:"paymentId,omitempty"`
+	Type        PointEntryType `json:"type"`
+	Points      int            `json:"points"`
+	Description string         `json:"description"`
+	ExpiresAt   *time.Time     `json:"expiresAt,omitempty"`
+	CreatedAt   time.Time      `json:"createdAt"`
+}
+
+type PointHistory struct {
+	Balance int          `json:"balance"`
+	Entries []PointEntry `json:"entries"`
+	Paging  Paging       `json:"paging"`
+}
+
+type PointHistoryResponse struct {
+	Response
+	History PointHistory `json:"data"`
+}
@@ -0,0 +1,100 @@
+package models
+
+type DayType string
+
+const (
+	Weekday DayType = "weekday"
+	Weekend DayType = "weekend"
+	Holiday DayType = "holiday"
+)
+
+func (t DayType) Valid() bool {
+	switch t {
+	case Weekday, Weekend, Holiday:
+		return true
+	}
+	return false
+}
+
+type PriceAdjustment string
+
+const (
+	PercentageAdjustment PriceAdjustment = "percentage"
+	FixedAdjustment      PriceAdjustment = "fixed"
+)
+
+func (a PriceAdjustment) Valid() bool {
+	return a == PercentageAdjustment || a == FixedAdjustment
+}
+
+// PricingRule adjusts the price of every seat of the schedules it matches.
+// A condition left empty matches any schedule, and the matching rules are
+// applied one after the other in order of priority.
+type PricingRule struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+	// DayType is the kind of day of the show.
+	DayType *DayType `json:"dayType,omitempty"`
+	// StartTime and EndTime (HH:MM) are the time slot the show starts in, the
+	// slot wraps around midnight when it ends before it starts.
+	StartTime *string `json:"startTime,omitempty"`
+	EndTime   *string `json:"endTime,omitempty"`
+	Format    *Format `json:"format,omitempty"`
+	// MinOccupancy is the percentage of seats that must be sold, for surges.
+	MinOccupancy *float64 `json:"minOccupancy,omitempty"`
+	// Percentage or Amount, whichever AdjustmentType says, is added to the
+	// price, a negative one lowers it. The other one is zero.
+	AdjustmentType PriceAdjustment `json:"adjustmentType"`
+	Percentage     float64         `json:"percentage"`
+	Amount         Money           `json:"amount"`
+	Active         bool            `json:"active"`
+}
+
+type PricingRuleResponse struct {
+	Response
+	Rule PricingRule `json:"data"`
+}
+type PricingRulesResponse struct {
+	Response
+	Rules []PricingRule `json:"data"`
+}
+
+// PublicHoliday is a date priced as a holiday, whatever day of the week it is.
+type PublicHoliday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+type PublicHolidayResponse struct {
+	Response
+	Holiday PublicHoliday `json:"data"`
+}
+type PublicHolidaysResponse struct {
+	Response
+	Holidays []PublicHoliday `json:"data"`
+}
+
+// PricingRuleEffect is the price each seat type would have with only the
+// rule applied, and whether it applies to the schedule right now.
+type PricingRuleEffect struct {
+	Rule    PricingRule        `json:"rule"`
+	Applies bool               `json:"applies"`
+	Prices  map[SeatType]Money `json:"prices"`
+}
+
+type PricingPreview struct {
+	ScheduleID int                 `json:"scheduleId"`
+	DayType    DayType             `json:"dayType"`
+	Format     Format              `json:"format,omitempty"`
+	Occupancy  float64             `json:"occupancy"`
+	BasePrices map[SeatType]Money  `json:"basePrices"`
+	Rules      []PricingRuleEffect `json:"rules"`
+	// Prices is what the seats cost with every applying rule.
+	Prices map[SeatType]Money `json:"prices"`
+}
+
+type PricingPreviewResponse struct {
+	Response
+	Preview PricingPreview `json:"data"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+type PromotionType string
+
+const (
+	PercentageOff PromotionType = "percentage"
+	FixedOff      PromotionType = "fixed"
+	// BuyOneGetOne makes the cheaper seat of every pair free.
+	BuyOneGetOne PromotionType = "bogo"
+)
+
+func (t PromotionType) Valid() bool {
+	switch t {
+	case PercentageOff, FixedOff, BuyOneGetOne:
+		return true
+	}
+	return false
+}
+
+type Promotion struct {
+	ID          int           `json:"id"`
+	Code        string        `json:"code"`
+	Description string        `json:"description"`
+	Type        PromotionType `json:"type"`
+	// Percentage is taken off by a percentage promotion and Amount by a
+	// fixed one, which only applies to schedules priced in its Currency.
+	// Both are zero for bogo.
+	Percentage float64  `json:"percentage"`
+	Amount     Money    `json:"amount"`
+	Currency   Currency `json:"currency,omitempty"`
+	MinSeats   int      `json:"minSeats"`
+	// MovieIDs, BranchIDs and Days restrict the schedules the code applies to, empty means any.
+	MovieIDs  []int    `json:"movieIds"`
+	BranchIDs []int    `json:"branchIds"`
+	Days      []string `json:"days"`
+	// UsageLimit and CustomerLimit cap the number of paid or pending uses, nil means unlimited.
+	UsageLimit    *int       `json:"usageLimit"`
+	CustomerLimit *int       `json:"customerLimit"`
+	ValidFrom     *time.Time `json:"validFrom"`
+	ValidUntil    *time.Time `json:"validUntil"`
+	Active        bool       `json:"active"`
+	UsageCount    int        `json:"usageCount"`
+}
+
+type PromotionResponse struct {
+	Response
+	Promotion Promotion `json:"data"`
+}
+
+type PromotionsResponse struct {
+	Response
+	Promotions []Promotion `json:"data"`
+}
+
+type PromotionPreviewRequest struct {
+	Code       string `json:"code"`
+	ScheduleID int    `json:"scheduleId"`
+	SeatIDs    []int  `json:"seatIds"`
+}
+
+type PromotionPreview struct {
+	Code     string `json:"code"`
+	Subtotal Money  `json:"subtotal"`
+	Discount Money  `json:"discount"`
+	Total    Money  `json:"total"`
+	// Breakdown adds the service fee and the entertainment tax to the total.
+	Breakdown *PriceBreakdown `json:"breakdown,omitempty"`
+}
+
+type PromotionPreviewResponse struct {
+	Response
+	Preview PromotionPreview `json:"data"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type Refund struct {
+	ID         int       `json:"id,omitempty"`
+	TicketID   int       `json:"ticketId"`
+	PaymentID  int       `json:"paymentId"`
+	Amount     Money     `json:"amount"`
+	Currency   Currency  `json:"currency"`
+	Percentage int       `json:"percentage"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type RefundResponse struct {
+	Response
+	Refund Refund `json:"data"`
+}
@@ -3,8 +3,13 @@ package models
 import "time"
 
 type Schedule struct {
-	ID       int           `json:"id"`
-	Price    float64       `json:"price"`
+	ID     int                `json:"id"`
+	Price  Money              `json:"price"`
+	Prices map[SeatType]Money `json:"prices,omitempty"`
+	// Currency is the currency of the prices, DefaultCurrency unless given.
+	Currency Currency `json:"currency,omitempty"`
+	// Format is how the movie is shown, 2D unless given.
+	Format   Format        `json:"format,omitempty"`
 	Showtime time.Time     `json:"showtime"`
 	Movie    *Movie        `json:"movie,omitempty"`
 	Branch   BranchTheatre `json:"branch"`
@@ -12,12 +17,27 @@ type Schedule struct {
 }
 
 type ScheduleTicket struct {
-	ID       int            `json:"id"`
-	Price    *float64       `json:"price,omitempty"`
-	Showtime *time.Time     `json:"showtime,omitempty"`
-	Movie    *Movie         `json:"movie,omitempty"`
-	Branch   *BranchTheatre `json:"branch,omitempty"`
-	Seat     *Seat          `json:"seat,omitempty"`
+	ID        int            `json:"id"`
+	Price     *Money         `json:"price,omitempty"`
+	Currency  Currency       `json:"currency,omitempty"`
+	Showtime  *time.Time     `json:"showtime,omitempty"`
+	Movie     *Movie         `json:"movie,omitempty"`
+	Branch    *BranchTheatre `json:"branch,omitempty"`
+	Seat      *Seat          `json:"seat,omitempty"`
+	HoldToken string         `json:"holdToken,omitempty"`
+	PromoCode string         `json:"promoCode,omitempty"`
+	Points    int            `json:"points,omitempty"`
+	GiftCard  string         `json:"giftCardCode,omitempty"`
+	// AgeConfirmed must be set to book a movie classified 13+ or above.
+	AgeConfirmed bool `json:"ageConfirmed,omitempty"`
+	// Accessible tells the customer needs a wheelchair space.
+	Accessible bool `json:"accessible,omitempty"`
+}
+
+// ScheduleConflictResponse lists the schedules a new show time would overlap.
+type ScheduleConflictResponse struct {
+	Response
+	Conflicts []int `json:"conflicts"`
 }
 
 type SchedulesResponse struct {
@@ -28,3 +48,34 @@ type ScheduleResponse struct {
 	Response
 	Schedule Schedule `json:"data"`
 }
+
+// ScheduleCopy clones the schedules of a branch, or of one of its theatres,
+// shown between FromDate and ToDate so the first day lands on TargetDate.
+type ScheduleCopy struct {
+	TheatreID  *int   `json:"theatreId,omitempty"`
+	FromDate   string `json:"fromDate"`
+	ToDate     string `json:"toDate"`
+	TargetDate string `json:"targetDate"`
+}
+
+// CopiedSchedule is a schedule of a copy with its clone, or the reason it
+// wasn't cloned.
+type CopiedSchedule struct {
+	SourceID   int       `json:"sourceId"`
+	ScheduleID int       `json:"scheduleId,omitempty"`
+	MovieID    int       `json:"movieId"`
+	TheatreID  int       `json:"theatreId"`
+	Showtime   time.Time `json:"showtime"`
+	Reason     string    `json:"reason,omitempty"`
+	Conflicts  []int     `json:"conflicts,omitempty"`
+}
+
+type ScheduleCopyResult struct {
+	Copied  []CopiedSchedule `json:"copied"`
+	Skipped []CopiedSchedule `json:"skipped"`
+}
+
+type ScheduleCopyResponse struct {
+	Response
+	Result ScheduleCopyResult `json:"data"`
+}
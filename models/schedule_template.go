@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ScheduleTemplate is a weekly programme of a movie in a theatre: a show at
+// every start time on every day of the week, between the two dates.
+type ScheduleTemplate struct {
+	ID         int                `json:"id"`
+	MovieID    int                `json:"movieId"`
+	TheatreID  int                `json:"theatreId"`
+	Days       []string           `json:"days"`
+	StartTimes []string           `json:"startTimes"`
+	StartDate  string             `json:"startDate"`
+	EndDate    string             `json:"endDate"`
+	Price      Money              `json:"price"`
+	Prices     map[SeatType]Money `json:"prices,omitempty"`
+	Format     Format             `json:"format,omitempty"`
+	CreatedAt  time.Time          `json:"createdAt"`
+}
+
+type ScheduleTemplateResponse struct {
+	Response
+	Template ScheduleTemplate `json:"data"`
+}
+type ScheduleTemplatesResponse struct {
+	Response
+	Templates []ScheduleTemplate `json:"data"`
+}
+
+// GeneratedSchedule is a show of a template, with the schedule it became or
+// the schedules it overlaps.
+type GeneratedSchedule struct {
+	ScheduleID int       `json:"scheduleId,omitempty"`
+	Showtime   time.Time `json:"showtime"`
+	Conflicts  []int     `json:"conflicts,omitempty"`
+}
+
+type ScheduleGeneration struct {
+	TemplateID int                 `json:"templateId"`
+	DryRun     bool                `json:"dryRun"`
+	Schedules  []GeneratedSchedule `json:"schedules"`
+}
+
+type ScheduleGenerationResponse struct {
+	Response
+	Generation ScheduleGeneration `json:"data"`
+}
@@ -0,0 +1,29 @@
+package models
+
+type MovieSearchResponse struct {
+	Response
+	Movies []Movie `json:"data"`
+	// Corrections are the words the search was also matched with, for each
+	// word that looked misspelled.
+	Corrections map[string][]string `json:"corrections,omitempty"`
+}
+
+type SuggestionKind string
+
+const (
+	MovieSuggestion  SuggestionKind = "movie"
+	GenreSuggestion  SuggestionKind = "genre"
+	PersonSuggestion SuggestionKind = "person"
+)
+
+// Suggestion completes what a customer is typing in the search box.
+type Suggestion struct {
+	Kind    SuggestionKind `json:"kind"`
+	Text    string         `json:"text"`
+	MovieID int            `json:"movieId,omitempty"`
+}
+
+type SuggestionsResponse struct {
+	Response
+	Suggestions []Suggestion `json:"data"`
+}
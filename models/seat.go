@@ -1,13 +1,36 @@
 package models
 
 type Seat struct {
-	ID           int    `json:"id"`
-	Row          string `json:"row,omitempty"`
-	Number       string `json:"number,omitempty"`
-	Availability *bool  `json:"availability,omitempty"`
+	ID           int      `json:"id"`
+	Row          string   `json:"row,omitempty"`
+	Number       string   `json:"number,omitempty"`
+	Position     int      `json:"position,omitempty"`
+	Type         SeatType `json:"type,omitempty"`
+	Price        *Money   `json:"price,omitempty"`
+	Blocked      bool     `json:"blocked,omitempty"`
+	Availability *bool    `json:"availability,omitempty"`
 }
 
 type SeatRow struct {
-	Row   string `json:"row"`
-	Count int    `json:"count"`
+	Row   string   `json:"row"`
+	Count int      `json:"count"`
+	Type  SeatType `json:"type,omitempty"`
+}
+
+type SeatType string
+
+const (
+	Regular    SeatType = "regular"
+	VIP        SeatType = "vip"
+	Sweetbox   SeatType = "sweetbox"
+	Wheelchair SeatType = "wheelchair"
+	Companion  SeatType = "companion"
+)
+
+func (t SeatType) Valid() bool {
+	switch t {
+	case Regular, VIP, Sweetbox, Wheelchair, Companion:
+		return true
+	}
+	return false
 }
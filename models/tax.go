@@ -0,0 +1,31 @@
+package models
+
+// TaxJurisdiction is the region a branch pays entertainment tax (pajak
+// hiburan) in, with the convenience fee charged there per ticket.
+type TaxJurisdiction struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	TaxRate    float64 `json:"taxRate"`
+	ServiceFee Money   `json:"serviceFee"`
+}
+
+type TaxJurisdictionResponse struct {
+	Response
+	Jurisdiction TaxJurisdiction `json:"data"`
+}
+type TaxJurisdictionsResponse struct {
+	Response
+	Jurisdictions []TaxJurisdiction `json:"data"`
+}
+
+// PriceBreakdown is how the total of a booking is made up. The entertainment
+// tax is levied on the ticket price after the discount, not on the fee.
+type PriceBreakdown struct {
+	BasePrice    Money   `json:"basePrice"`
+	Discount     Money   `json:"discount"`
+	ServiceFee   Money   `json:"serviceFee"`
+	Tax          Money   `json:"tax"`
+	TaxRate      float64 `json:"taxRate"`
+	Jurisdiction string  `json:"jurisdiction"`
+	Total        Money   `json:"total"`
+}
@@ -3,6 +3,10 @@ package models
 type Theatre struct {
 	ID   int    `json:"id,omitempty"`
 	Name string `json:"name"`
+	// CleanupMinutes is the time the theatre needs between two shows.
+	CleanupMinutes *int `json:"cleanupMinutes,omitempty"`
+	// Formats are what the theatre can project, 2D only unless given.
+	Formats []Format `json:"formats,omitempty"`
 }
 
 type TheatresResponse struct {
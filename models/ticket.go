@@ -1,10 +1,13 @@
 package models
 
+import "time"
+
 type Ticket struct {
-	ID       int            `json:"id"`
-	Seat     Seat           `json:"seat"`
-	Schedule ScheduleTicket `json:"schedule"`
-	Payment  Payment        `json:"payment"`
+	ID          int            `json:"id"`
+	Seat        Seat           `json:"seat"`
+	Schedule    ScheduleTicket `json:"schedule"`
+	Payment     Payment        `json:"payment"`
+	CancelledAt *time.Time     `json:"cancelledAt,omitempty"`
 }
 
 type TicketResponse struct {
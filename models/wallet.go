@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+type WalletTransactionType string
+
+const (
+	WalletTopUp   WalletTransactionType = "topup"
+	WalletPayment WalletTransactionType = "payment"
+	WalletRefund  WalletTransactionType = "refund"
+)
+
+// WalletEntry is one line of the customer's side of a wallet transaction.
+// Credits are positive, debits negative.
+type WalletEntry struct {
+	ID            int                   `json:"id"`
+	TransactionID int                   `json:"transactionId"`
+	Type          WalletTransactionType `json:"type"`
+	PaymentID     *int                  `json:"paymentId,omitempty"`
+	Amount        Money                 `json:"amount"`
+	Description   string                `json:"description"`
+	CreatedAt     time.Time             `json:"createdAt"`
+}
+
+type Wallet struct {
+	Balance  Money         `json:"balance"`
+	Currency Currency      `json:"currency"`
+	Entries  []WalletEntry `json:"entries"`
+	Paging   Paging        `json:"paging"`
+}
+
+type WalletResponse struct {
+	Response
+	Wallet Wallet `json:"data"`
+}
+
+type TopUpRequest struct {
+	Amount Money `json:"amount"`
+}
+
+type TopUp struct {
+	ID      int     `json:"id"`
+	Amount  Money   `json:"amount"`
+	Payment Payment `json:"payment"`
+}
+
+type TopUpResponse struct {
+	Response
+	TopUp TopUp `json:"data"`
+}
@@ -1,9 +1,11 @@
 package routes
 
 import (
+	"log"
 	"net/http"
 	"tix-id/controller"
 	"tix-id/middleware"
+	"tix-id/tool"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin" // swagger embed files
@@ -33,6 +35,13 @@ func SetupRouter() *gin.Engine {
 
 	router.Use(corsPolicy())
 
+	// Uploaded images kept on the local disk are served by this server.
+	if storage, err := tool.NewStorage(); err != nil {
+		log.Println(err)
+	} else if local, ok := storage.(*tool.LocalStorage); ok && local.ServedPath() != "" {
+		router.Static(local.ServedPath(), local.Dir)
+	}
+
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Welcome to TIX-ID",
@@ -51,15 +60,32 @@ func SetupRouter() *gin.Engine {
 				customerId.Use(middleware.AuthMiddleware("customer"))
 
 				{
-					customerId.POST("/tickets", controller.CreateTicket)
+					customerId.POST("/tickets", middleware.Idempotency(), controller.CreateTicket)
 					customerId.GET("/tickets", controller.GetTickets)
 					customerId.GET("/tickets/:ticketId", controller.GetTicket)
-					customerId.POST("/tickets/:ticketId/payment", controller.ConfirmPayment)
+					customerId.DELETE("/tickets/:ticketId", controller.CancelTicket)
+					customerId.POST("/tickets/:ticketId/payment", middleware.Idempotency(), controller.ConfirmPayment)
+					customerId.POST("/orders", middleware.Idempotency(), controller.CreateOrder)
+					customerId.GET("/orders/:orderId", controller.GetOrder)
+					customerId.POST("/orders/:orderId/payment", middleware.Idempotency(), controller.ConfirmOrderPayment)
+					customerId.POST("/holds", controller.CreateSeatHold)
+					customerId.PUT("/holds/:holdToken", controller.ExtendSeatHold)
+					customerId.DELETE("/holds/:holdToken", controller.ReleaseSeatHold)
+					customerId.POST("/promotions/preview", controller.PreviewPromotion)
+					customerId.GET("/points", controller.GetPoints)
+					customerId.GET("/wallet", controller.GetWallet)
+					customerId.POST("/wallet/topups", middleware.Idempotency(), controller.TopUpWallet)
+					customerId.POST("/giftcards", middleware.Idempotency(), controller.PurchaseGiftCard)
 					customerId.GET("/profile", controller.GetCustomer)
 					customerId.PUT("/profile", controller.UpdateCustomer)
 				}
 			}
 
+			payments := v1.Group("/payments")
+			{
+				payments.POST("/webhook", controller.PaymentWebhook)
+			}
+
 			admin := v1.Group("/admin")
 			{
 				admin.POST("/auth/login", controller.LoginAdmin)
@@ -69,6 +95,11 @@ func SetupRouter() *gin.Engine {
 			{
 				movie.GET("/", controller.GetMovies)
 				movie.GET("/search", controller.SearchMovies)
+				movie.GET("/suggest", controller.SuggestMovies)
+				movie.GET("/genres", controller.GetGenres)
+				movie.GET("/now-showing", controller.GetNowShowing)
+				movie.GET("/coming-soon", controller.GetComingSoon)
+				movie.GET("/presale", controller.GetPresale)
 				movie.POST("/", controller.CreateMovie)
 				movieId := movie.Group("/:movieId")
 				{
@@ -79,11 +110,75 @@ func SetupRouter() *gin.Engine {
 					movieId.DELETE("/", middleware.AuthMiddleware("admin"), controller.DeleteMovie)
 					movieId.PUT("/schedules/:scheduleId", middleware.AuthMiddleware("admin"), controller.UpdateMovieSchedule)
 					movieId.DELETE("/schedules/:scheduleId", middleware.AuthMiddleware("admin"), controller.DeleteSchedule)
+					movieId.GET("/schedules/:scheduleId/pricing", middleware.AuthMiddleware("admin"), controller.PreviewSchedulePricing)
 					movieId.POST("/schedules/:scheduleId/seats", middleware.AuthMiddleware("admin"), controller.AddScheduleSeats)
+					movieId.PUT("/schedules/:scheduleId/seats/:seatId", middleware.AuthMiddleware("admin"), controller.UpdateScheduleSeat)
+					movieId.POST("/media/:kind", middleware.AuthMiddleware("admin"), controller.UploadMovieMedia)
+					movieId.DELETE("/media/:kind", middleware.AuthMiddleware("admin"), controller.DeleteMovieMedia)
 					movieId.GET("/", controller.GetMovieById)
 				}
 			}
 
+			promotions := v1.Group("/promotions")
+			promotions.Use(middleware.AuthMiddleware("admin"))
+			{
+				promotions.GET("/", controller.GetPromotions)
+				promotions.POST("/", controller.CreatePromotion)
+				promotions.GET("/:promotionId", controller.GetPromotion)
+				promotions.PUT("/:promotionId", controller.UpdatePromotion)
+				promotions.DELETE("/:promotionId", controller.DeletePromotion)
+			}
+
+			pricingRules := v1.Group("/pricing-rules")
+			pricingRules.Use(middleware.AuthMiddleware("admin"))
+			{
+				pricingRules.GET("/", controller.GetPricingRules)
+				pricingRules.POST("/", controller.CreatePricingRule)
+				pricingRules.PUT("/:ruleId", controller.UpdatePricingRule)
+				pricingRules.DELETE("/:ruleId", controller.DeletePricingRule)
+			}
+
+			holidays := v1.Group("/holidays")
+			holidays.Use(middleware.AuthMiddleware("admin"))
+			{
+				holidays.GET("/", controller.GetPublicHolidays)
+				holidays.POST("/", controller.CreatePublicHoliday)
+				holidays.DELETE("/:date", controller.DeletePublicHoliday)
+			}
+
+			imports := v1.Group("/imports")
+			imports.Use(middleware.AuthMiddleware("admin"))
+			{
+				imports.POST("/:kind", controller.ImportData)
+			}
+
+			templates := v1.Group("/schedule-templates")
+			templates.Use(middleware.AuthMiddleware("admin"))
+			{
+				templates.GET("/", controller.GetScheduleTemplates)
+				templates.POST("/", controller.CreateScheduleTemplate)
+				templates.GET("/:templateId", controller.GetScheduleTemplate)
+				templates.DELETE("/:templateId", controller.DeleteScheduleTemplate)
+				templates.POST("/:templateId/schedules", controller.GenerateSchedules)
+			}
+
+			giftcards := v1.Group("/giftcards")
+			giftcards.Use(middleware.AuthMiddleware("admin"))
+			{
+				giftcards.GET("/", controller.GetGiftCards)
+				giftcards.POST("/batches", controller.IssueGiftCards)
+				giftcards.GET("/:giftCardId", controller.GetGiftCard)
+				giftcards.DELETE("/:giftCardId", controller.DeactivateGiftCard)
+			}
+
+			jurisdictions := v1.Group("/tax-jurisdictions")
+			jurisdictions.Use(middleware.AuthMiddleware("admin"))
+			{
+				jurisdictions.GET("/", controller.GetTaxJurisdictions)
+				jurisdictions.POST("/", controller.CreateTaxJurisdiction)
+				jurisdictions.PUT("/:jurisdictionId", controller.UpdateTaxJurisdiction)
+			}
+
 			branches := v1.Group("/branches")
 			branches.Use(middleware.AuthMiddleware("admin"))
 			{
@@ -97,12 +192,21 @@ func SetupRouter() *gin.Engine {
 					branchId.POST("/theatres", controller.CreateTheatre)
 					branchId.PUT("/theatres/:theatreId", controller.UpdateTheatre)
 					branchId.DELETE("/theatres/:theatreId", controller.DeleteTheatre)
+					branchId.GET("/theatres/:theatreId/layout", controller.GetTheatreLayout)
+					branchId.PUT("/theatres/:theatreId/layout", controller.UpdateTheatreLayout)
+					branchId.POST("/schedules/copy", controller.CopySchedules)
 				}
 			}
 
 		}
 	}
 
+	// the checkout of the simulator pays any charge, so it only exists when
+	// the simulator is the configured provider
+	if tool.SimulatorEnabled() {
+		router.GET("/simulator/checkout/:chargeId", controller.SimulatorCheckout)
+	}
+
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	return router
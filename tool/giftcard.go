@@ -0,0 +1,86 @@
+package tool
+
+import (
+	"crypto/rand"
+	"database/sql"
+
+	"math/big"
+	"strings"
+	"time"
+	"tix-id/models"
+)
+
+// giftCardAlphabet leaves out characters that are easy to misread, like 0/O and 1/I.
+const giftCardAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// NewGiftCardCode returns a random code formatted as XXXX-XXXX-XXXX-XXXX.
+func NewGiftCardCode() (string, error) {
+	var groups []string
+	for g := 0; g < 4; g++ {
+		group := make([]byte, 4)
+		for i := range group {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(giftCardAlphabet))))
+			if err != nil {
+				return "", err
+			}
+			group[i] = giftCardAlphabet[n.Int64()]
+		}
+		groups = append(groups, string(group))
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// NormalizeGiftCardCode makes codes typed by customers comparable, so
+// "abcd efgh-..." finds ABCD-EFGH-....
+func NormalizeGiftCardCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(code))
+	var groups []string
+	for len(code) > 4 {
+		groups = append(groups, code[:4])
+		code = code[4:]
+	}
+	return strings.Join(append(groups, code), "-")
+}
+
+// RestoreGiftCards puts the given share (0 to 1) of what the payment took
+// from gift cards back on them.
+func RestoreGiftCards(tx *sql.Tx, paymentId int, share float64) error {
+	rows, err := tx.Query("select gift_card_id, coalesce(-sum(if(amount < 0, amount, 0)), 0), coalesce(-sum(amount), 0) from gift_card_redemption where payment_id = ? group by gift_card_id", paymentId)
+	if err != nil {
+		return err
+	}
+	type usage struct {
+		giftCardId  int
+		used, owing models.Money
+	}
+	var usages []usage
+	for rows.Next() {
+		var u usage
+		if err := rows.Scan(&u.giftCardId, &u.used, &u.owing); err != nil {
+			rows.Close()
+			return err
+		}
+		usages = append(usages, u)
+	}
+	rows.Close()
+
+	for _, u := range usages {
+		amount := u.used.Percent(share * 100).Min(u.owing)
+		if amount <= 0 {
+			continue
+		}
+		if _, err := tx.Exec("insert into gift_card_redemption (gift_card_id, payment_id, amount) values (?, ?, ?)", u.giftCardId, paymentId, amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestorePaymentCredits gives back the points and gift card balance spent on
+// a payment that failed or was cancelled before it was paid.
+func RestorePaymentCredits(tx *sql.Tx, paymentId int, now time.Time) error {
+	if err := RestoreRedeemedPoints(tx, paymentId, LoadPointsPolicy(), now); err != nil {
+		return err
+	}
+	return RestoreGiftCards(tx, paymentId, 1)
+}
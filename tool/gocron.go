@@ -1,7 +1,10 @@
 package tool
 
 import (
+	"database/sql"
 	"log"
+	"os"
+	"strconv"
 	"time"
 	"tix-id/config"
 	"tix-id/models"
@@ -9,7 +12,22 @@ import (
 	"github.com/claudiu/gocron"
 )
 
-func CronTicketExpiry() {
+// PaymentExpiry returns how long a payment may stay pending, configured by
+// PAYMENT_EXPIRY (in seconds). It defaults to the seat hold duration, the
+// time a customer is given to check out.
+func PaymentExpiry() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("PAYMENT_EXPIRY"))
+	if err != nil || seconds <= 0 {
+		return SeatHoldTTL()
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// CronTicketExpiry fails the payments that stayed pending for longer than
+// PaymentExpiry, which releases their seats. A payment with a charge is only
+// failed when the provider declined it; a charge that went through is given
+// to settle instead, and one the provider is still processing is left alone.
+func CronTicketExpiry(settle func(db *sql.DB, provider PaymentProvider, paymentId int, charge models.Charge) error) {
 	s := gocron.NewScheduler()
 
 	// Connect to database
@@ -17,40 +35,83 @@ func CronTicketExpiry() {
 
 	// Ensure the database connection is closed when the function returns
 	defer db.Close()
+
+	provider, err := NewPaymentProvider()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	expiry := PaymentExpiry()
 	s.Every(10).Seconds().Do(func() {
-		//get all payments
-		rows, err := db.Query("SELECT * FROM payment where payment_status = 'pending'")
+		// created_at is set by the database, so its clock decides the age
+		rows, err := db.Query("SELECT id, charge_id FROM payment WHERE payment_status = 'pending' AND created_at < now() - INTERVAL ? SECOND", int(expiry.Seconds()))
 		if err != nil {
 			log.Println(err)
 			return
 		}
-
-		//loop through all payments
+		type expired struct {
+			paymentId int
+			chargeId  sql.NullString
+		}
+		var payments []expired
 		for rows.Next() {
-			var payment models.Payment
-			var created_at time.Time
-			//get payment data
-			err := rows.Scan(&payment.ID, &payment.Amount, &payment.Status, &created_at)
-			if err != nil {
+			var payment expired
+			if err := rows.Scan(&payment.paymentId, &payment.chargeId); err != nil {
 				log.Println(err)
+				rows.Close()
 				return
 			}
-			//if status pending after 1 mins, change to expired
-			t1 := created_at.Truncate(time.Second)
-			t2 := time.Now().In(time.FixedZone("WIB", 7*60*60)).Add(-7 * time.Hour)
-			log.Println("CRON Checking")
-			if t2.Sub(t1).Minutes() > 1 {
-				log.Println("FAILED Transaction ")
-				log.Println(t1)
-				log.Println(t2)
-				log.Println(t2.Sub(t1).Minutes())
-				_, err := db.Exec("UPDATE payment SET payment_status = 'failed' WHERE id = ?", payment.ID)
+			payments = append(payments, payment)
+		}
+		rows.Close()
+
+		for _, payment := range payments {
+			if payment.chargeId.Valid {
+				charge, err := provider.QueryStatus(payment.chargeId.String)
 				if err != nil {
 					log.Println(err)
-					return
+					continue
 				}
+				switch charge.Status {
+				case models.ChargePending:
+					continue
+				case models.ChargeSucceeded:
+					if err := settle(db, provider, payment.paymentId, charge); err != nil {
+						log.Println(err)
+					}
+					continue
+				}
+			}
+			log.Printf("payment %d expired", payment.paymentId)
+			if err := FailPayment(db, payment.paymentId); err != nil {
+				log.Println(err)
 			}
 		}
 	})
 	<-s.Start()
 }
+
+// FailPayment fails a pending payment, releases the seats of its tickets and
+// gives back the points and gift card balance spent on it.
+func FailPayment(db *sql.DB, paymentId int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE payment SET payment_status = 'failed' WHERE id = ? AND payment_status = 'pending'", paymentId)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected == 0 {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE ticket SET active = NULL WHERE payment_id = ?", paymentId); err != nil {
+		return err
+	}
+	if err := RestorePaymentCredits(tx, paymentId, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
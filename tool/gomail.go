@@ -1,6 +1,7 @@
 package tool
 
 import (
+	"html"
 	"os"
 	"strconv"
 	"tix-id/models"
@@ -22,9 +23,7 @@ func SendEmail(content string, receiverMail string, subject string) {
 	}
 }
 
-func GeneratePaymentEmail(customer models.Customer, payment models.Payment, scheduleTicket models.ScheduleTicket) string {
-
-	content := `<!DOCTYPE html>
+const emailHead = `<!DOCTYPE html>
 	<html lang="en">
 	<head>
 		<meta charset="UTF-8">
@@ -91,15 +90,78 @@ func GeneratePaymentEmail(customer models.Customer, payment models.Payment, sche
 	</head>
 	<body>
 		<div class="email-container">
-			<div class="email-header">
-				<img src="your-logo.png" alt="Your App Logo">
+			<div class="email-header">`
+
+// emailHeader starts an email, with the logo at EMAIL_LOGO_URL when one is
+// set. The logo must be an absolute URL, mail clients can't load relative
+// ones.
+func emailHeader() string {
+	header := emailHead
+	if logo := os.Getenv("EMAIL_LOGO_URL"); logo != "" {
+		header += `
+				<img src="` + html.EscapeString(logo) + `" alt="TIX-ID">`
+	}
+	return header + `
 			</div>
 			<div class="email-content">`
+}
+
+const emailFooter = `						<div class="email-footer">
+							<p>Need help? Contact at: payment@tix-id.com</p>
+						</div>
+					</div>
+				</body>
+				</html>`
+
+// paymentMethod names the provider a payment was made with.
+func paymentMethod(provider string) string {
+	switch provider {
+	case "":
+		return "TIX-ID"
+	case SimulatorProvider:
+		return "Payment Simulator"
+	case WalletProvider:
+		return "TIX-ID Wallet"
+	}
+	return provider
+}
+
+// discountLine shows the price breakdown and the promo, points and gift card
+// parts of the payment, if it has any.
+func discountLine(payment models.Payment) string {
+	line := ""
+	if payment.Breakdown != nil {
+		line += `<li><strong>PRICE      ` + payment.Breakdown.BasePrice.Format(payment.Currency) + `</li>
+				`
+	}
+	if payment.Discount > 0 {
+		line += `<li><strong>DISCOUNT   ` + payment.PromoCode + ` -` + payment.Discount.Format(payment.Currency) + `</li>
+				`
+	}
+	if payment.Breakdown != nil {
+		line += `<li><strong>ADMIN FEE  ` + payment.Breakdown.ServiceFee.Format(payment.Currency) + `</li>
+				<li><strong>TAX        ` + strconv.FormatFloat(payment.Breakdown.TaxRate, 'f', -1, 64) + `% ` + payment.Breakdown.Jurisdiction + ` ` + payment.Breakdown.Tax.Format(payment.Currency) + `</li>
+				`
+	}
+	if payment.PointsDiscount > 0 {
+		line += `<li><strong>POINTS     ` + strconv.Itoa(payment.PointsUsed) + ` pts -` + payment.PointsDiscount.Format(payment.Currency) + `</li>
+				`
+	}
+	if payment.GiftCardAmount > 0 {
+		line += `<li><strong>GIFT CARD  -` + payment.GiftCardAmount.Format(payment.Currency) + `</li>
+				`
+	}
+	return line
+}
+
+func GeneratePaymentEmail(customer models.Customer, payment models.Payment, scheduleTicket models.ScheduleTicket) string {
+
+	content := emailHeader()
 	content += `<h1>TIX-ID</h1>
 			<p>Hi, ` + customer.Name + `,</p>
 			<p>Thank you for using TIX-ID, we hope you enjoyed our service. </p>
 			`
-	content += `<ul><li><strong>Amount Paid: </strong> ` + strconv.Itoa(int(payment.Amount)) + `</li><br>
+	content += `<ul><li><strong>Amount Paid: </strong> ` + payment.Amount.Format(payment.Currency) + `</li><br>
 				<strong>--------------------ORDER DETAILS--------------------</strong> <br>
 				<li><strong>Ticket ID:</strong> ` + strconv.Itoa(scheduleTicket.ID) + `</li>
 				<li><strong>` + scheduleTicket.Movie.Title + `</li>
@@ -107,15 +169,89 @@ func GeneratePaymentEmail(customer models.Customer, payment models.Payment, sche
 				<strong>-------------
 				<li><strong>SHOWTIME   ` + scheduleTicket.Showtime.String() + `</li>
 				<li><strong>SEAT       ` + scheduleTicket.Seat.Row + scheduleTicket.Seat.Number + `</li>
-				<li><strong>COST       ` + strconv.Itoa(int(payment.Amount)) + `</li>
-				<li><strong>Paid with X-Pay</li></ul>
+				` + discountLine(payment) + `<li><strong>COST       ` + payment.Amount.Format(payment.Currency) + `</li>
+				<li><strong>Paid with ` + paymentMethod(payment.Provider) + `</li></ul>
 						<p>Sold by PT Tiket Indonesia Programmers (NPWP: 02.331.777-9.054.000 - Address: Gedun ITHB Lt. 2, Jl. Dipatiukur No. 80 - 84, Bandung, Jawa Barat. Admin Fee and Discount, if any, are provided by ANONYMOUS.</p>
-						<div class="email-footer">
-							<p>Need help? Contact at: payment@tix-id.com</p>
-						</div>
-					</div>
-				</body>
-				</html>`
+`
+	content += emailFooter
+
+	return content
+}
+
+func GenerateOrderPaymentEmail(customer models.Customer, order models.Order) string {
+
+	content := emailHeader()
+	content += `<h1>TIX-ID</h1>
+			<p>Hi, ` + customer.Name + `,</p>
+			<p>Thank you for using TIX-ID, we hope you enjoyed our service. </p>
+			`
+	content += `<ul><li><strong>Amount Paid: </strong> ` + order.Payment.Amount.Format(order.Payment.Currency) + `</li><br>
+				<strong>--------------------ORDER DETAILS--------------------</strong> <br>
+				<li><strong>Order ID:</strong> ` + strconv.Itoa(order.ID) + `</li>
+				<li><strong>` + order.Schedule.Movie.Title + `</li>
+				<li><strong>` + order.Schedule.Branch.Name + `</li>
+				<strong>-------------
+				<li><strong>SHOWTIME   ` + order.Schedule.Showtime.String() + `</li>`
+	for _, ticket := range order.Tickets {
+		content += `
+				<li><strong>SEAT       ` + ticket.Seat.Row + ticket.Seat.Number + ` (Ticket ID: ` + strconv.Itoa(ticket.ID) + `)</li>`
+	}
+	content += `
+				` + discountLine(order.Payment) + `<li><strong>COST       ` + order.Payment.Amount.Format(order.Payment.Currency) + `</li>
+				<li><strong>Paid with ` + paymentMethod(order.Payment.Provider) + `</li></ul>
+						<p>Sold by PT Tiket Indonesia Programmers (NPWP: 02.331.777-9.054.000 - Address: Gedun ITHB Lt. 2, Jl. Dipatiukur No. 80 - 84, Bandung, Jawa Barat. Admin Fee and Discount, if any, are provided by ANONYMOUS.</p>
+`
+	content += emailFooter
+
+	return content
+}
+
+func GenerateRefundEmail(customer models.Customer, ticket models.Ticket, refund models.Refund) string {
+
+	content := emailHeader()
+	content += `<h1>TIX-ID</h1>
+			<p>Hi, ` + customer.Name + `,</p>
+			<p>Your ticket has been cancelled and the seat has been released. </p>
+			`
+	content += `<ul><li><strong>Amount Refunded: </strong> ` + refund.Amount.Format(refund.Currency) + ` (` + strconv.Itoa(refund.Percentage) + `%)</li><br>
+				<strong>--------------------REFUND DETAILS--------------------</strong> <br>
+				<li><strong>Ticket ID:</strong> ` + strconv.Itoa(ticket.ID) + `</li>
+				<li><strong>` + ticket.Schedule.Movie.Title + `</li>
+				<li><strong>` + ticket.Schedule.Branch.Name + `</li>
+				<strong>-------------
+				<li><strong>SHOWTIME   ` + ticket.Schedule.Showtime.String() + `</li>
+				<li><strong>SEAT       ` + ticket.Seat.Row + ticket.Seat.Number + `</li>
+				<li><strong>REFUND     ` + refund.Amount.Format(refund.Currency) + `</li>
+				<li><strong>Refunded to the original payment method</li></ul>
+						<p>The refund is processed by PT Tiket Indonesia Programmers and may take a few days to appear on your statement.</p>
+`
+	content += emailFooter
+
+	return content
+}
+
+func GenerateGiftCardEmail(sender models.Customer, card models.GiftCard) string {
+
+	content := emailHeader()
+	content += `<h1>TIX-ID</h1>
+			<p>Hi, ` + html.EscapeString(card.RecipientName) + `,</p>
+			<p>` + html.EscapeString(sender.Name) + ` sent you a TIX-ID gift card. Enjoy the movies! </p>
+			`
+	if card.Message != "" {
+		content += `<p><em>"` + html.EscapeString(card.Message) + `"</em></p>
+			`
+	}
+	content += `<ul><li><strong>Gift Card Value: </strong> ` + card.Amount.Format(card.Currency) + `</li><br>
+				<strong>--------------------GIFT CARD--------------------</strong> <br>
+				<li><strong>CODE       ` + card.Code + `</li>`
+	if card.ExpiresAt != nil {
+		content += `
+				<li><strong>VALID UNTIL ` + card.ExpiresAt.Format("02 Jan 2006") + `</li>`
+	}
+	content += `</ul>
+						<p>Enter the code when you book your tickets. It can be used over several bookings until its value is used up. Keep the code secret, anyone who has it can use it.</p>
+`
+	content += emailFooter
 
 	return content
 }
@@ -0,0 +1,114 @@
+package tool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+const (
+	// MaxImageSide caps the width and height of an upload, so a small file
+	// can't decode into a huge bitmap.
+	MaxImageSide = 8000
+	jpegQuality  = 85
+)
+
+var ErrUnsupportedImage = errors.New("the image must be a JPEG or PNG")
+
+// ImageFormats are the content types of the images that can be uploaded.
+var ImageFormats = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+}
+
+// DecodeImage checks the content of an upload is an image of a supported
+// type that isn't too large, then decodes it. The content type is sniffed
+// from the data rather than trusted from the client.
+func DecodeImage(data []byte) (image.Image, string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := ImageFormats[contentType]; !ok {
+		return nil, "", ErrUnsupportedImage
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	if config.Width > MaxImageSide || config.Height > MaxImageSide {
+		return nil, "", fmt.Errorf("the image can be at most %dx%d pixels", MaxImageSide, MaxImageSide)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	return img, contentType, nil
+}
+
+// FlattenImage draws the image onto white, as JPEGs have no transparency,
+// into a bitmap ResizeImage can read directly. Flatten an image once and
+// resize the result to each size.
+func FlattenImage(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Over)
+	return flat
+}
+
+// ResizeImage scales a flattened image down to the width, keeping its aspect
+// ratio, by averaging the pixels each new pixel covers. An image narrower
+// than the width is returned as it is.
+func ResizeImage(src *image.RGBA, width int) *image.RGBA {
+	if width <= 0 || width >= src.Bounds().Dx() {
+		return src
+	}
+
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += int(row[sx*4])
+					g += int(row[sx*4+1])
+					b += int(row[sx*4+2])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
+
+// EncodeJPEG encodes a resized image for the web.
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,77 @@
+package tool
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"tix-id/models"
+)
+
+const (
+	SimulatorProvider = "simulator"
+	// WalletProvider marks payments paid from the customer wallet instead of a gateway.
+	WalletProvider = "wallet"
+)
+
+var (
+	ErrChargeNotFound      = errors.New("the charge is not found")
+	ErrChargeDeclined      = errors.New("the payment was declined")
+	ErrProviderTimeout     = errors.New("the payment provider did not respond in time")
+	ErrChargeNotRefundable = errors.New("the charge cannot be refunded")
+	ErrInvalidSignature    = errors.New("the webhook signature is invalid")
+	ErrUnknownProvider     = errors.New("the payment provider is not supported")
+	ErrNoProvider          = errors.New("PAYMENT_PROVIDER is not set")
+)
+
+// PaymentProvider is a payment gateway. A charge is created first, which
+// gives the customer a redirect URL to pay at, and is captured once the
+// customer pays. The gateway reports the outcome to the callback URL of the
+// charge as a signed webhook.
+type PaymentProvider interface {
+	Name() string
+	CreateCharge(request models.ChargeRequest) (models.Charge, error)
+	Capture(chargeId string) (models.Charge, error)
+	Refund(chargeId string, amount models.Money) (models.Charge, error)
+	QueryStatus(chargeId string) (models.Charge, error)
+	VerifyWebhook(payload []byte, signature string) error
+}
+
+// NewPaymentProvider returns the provider configured by PAYMENT_PROVIDER.
+// The simulator, which completes payments nobody made, is only used when
+// PAYMENT_PROVIDER=simulator is set, never by default.
+func NewPaymentProvider() (PaymentProvider, error) {
+	switch os.Getenv("PAYMENT_PROVIDER") {
+	case "":
+		return nil, ErrNoProvider
+	case SimulatorProvider:
+		return simulator, nil
+	}
+	return nil, ErrUnknownProvider
+}
+
+// SimulatorEnabled tells if PAYMENT_PROVIDER selects the payment simulator.
+func SimulatorEnabled() bool {
+	return os.Getenv("PAYMENT_PROVIDER") == SimulatorProvider
+}
+
+// SignWebhook returns the hex encoded HMAC-SHA256 of the payload.
+func SignWebhook(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifySignature(secret string, payload []byte, signature string) error {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || secret == "" {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package tool
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"tix-id/models"
+)
+
+const (
+	WebhookSignatureHeader = "X-Tix-Signature"
+
+	SimulateSuccess = "success"
+	SimulateDecline = "decline"
+	SimulateTimeout = "timeout"
+
+	defaultSimulatorDelay = 2 * time.Second
+)
+
+// Simulator is an in-memory payment gateway for local development. It
+// behaves like a real one: a charge has a redirect URL, captures succeed,
+// get declined or time out according to PAYMENT_SIMULATOR_OUTCOME, and every
+// change is reported asynchronously to the callback URL of the charge as a
+// webhook signed with PAYMENT_WEBHOOK_SECRET. Charges are lost on restart.
+type Simulator struct {
+	mu      sync.Mutex
+	charges map[string]*simulatedCharge
+}
+
+type simulatedCharge struct {
+	charge      models.Charge
+	callbackURL string
+	settling    bool
+}
+
+var simulator = &Simulator{charges: map[string]*simulatedCharge{}}
+
+// simulatorOutcome returns how captures end, success by default.
+func simulatorOutcome() string {
+	switch outcome := os.Getenv("PAYMENT_SIMULATOR_OUTCOME"); outcome {
+	case SimulateDecline, SimulateTimeout:
+		return outcome
+	}
+	return SimulateSuccess
+}
+
+// simulatorDelay returns how long the simulator takes to send a webhook or to
+// settle a timed out capture, configured by PAYMENT_SIMULATOR_DELAY in seconds.
+func simulatorDelay() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("PAYMENT_SIMULATOR_DELAY"))
+	if err != nil || seconds < 0 {
+		return defaultSimulatorDelay
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (s *Simulator) Name() string {
+	return SimulatorProvider
+}
+
+func (s *Simulator) CreateCharge(request models.ChargeRequest) (models.Charge, error) {
+	token, err := newHoldToken()
+	if err != nil {
+		return models.Charge{}, err
+	}
+	baseURL := os.Getenv("PAYMENT_SIMULATOR_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	charge := models.Charge{
+		ID:        "ch_sim_" + token,
+		PaymentID: request.PaymentID,
+		Amount:    request.Amount,
+		Currency:  request.Currency,
+		Status:    models.ChargePending,
+		CreatedAt: time.Now(),
+	}
+	charge.RedirectURL = baseURL + "/simulator/checkout/" + charge.ID
+
+	s.mu.Lock()
+	s.charges[charge.ID] = &simulatedCharge{charge: charge, callbackURL: request.CallbackURL}
+	s.mu.Unlock()
+	return charge, nil
+}
+
+// Capture takes the money of a pending charge. Capturing a settled charge
+// again just returns it. On a timeout the charge stays pending and succeeds
+// later, which is only reported by the webhook.
+func (s *Simulator) Capture(chargeId string) (models.Charge, error) {
+	s.mu.Lock()
+	state, ok := s.charges[chargeId]
+	if !ok {
+		s.mu.Unlock()
+		return models.Charge{}, ErrChargeNotFound
+	}
+	if state.charge.Status != models.ChargePending {
+		charge := state.charge
+		s.mu.Unlock()
+		return charge, nil
+	}
+
+	switch simulatorOutcome() {
+	case SimulateTimeout:
+		if !state.settling {
+			state.settling = true
+			time.AfterFunc(simulatorDelay(), func() {
+				s.settle(chargeId, models.ChargeSucceeded)
+			})
+		}
+		charge := state.charge
+		s.mu.Unlock()
+		return charge, ErrProviderTimeout
+	case SimulateDecline:
+		state.charge.Status = models.ChargeDeclined
+	default:
+		state.charge.Status = models.ChargeSucceeded
+	}
+	charge := state.charge
+	s.mu.Unlock()
+
+	s.notify(state.callbackURL, "charge."+string(charge.Status), charge, charge.Amount)
+	return charge, nil
+}
+
+func (s *Simulator) settle(chargeId string, status models.ChargeStatus) {
+	s.mu.Lock()
+	state := s.charges[chargeId]
+	if state.charge.Status != models.ChargePending {
+		s.mu.Unlock()
+		return
+	}
+	state.charge.Status = status
+	charge := state.charge
+	s.mu.Unlock()
+
+	s.notify(state.callbackURL, "charge."+string(status), charge, charge.Amount)
+}
+
+// Refund gives back part or all of a captured charge.
+func (s *Simulator) Refund(chargeId string, amount models.Money) (models.Charge, error) {
+	s.mu.Lock()
+	state, ok := s.charges[chargeId]
+	if !ok {
+		s.mu.Unlock()
+		return models.Charge{}, ErrChargeNotFound
+	}
+	remaining := state.charge.Amount - state.charge.RefundedAmount
+	if state.charge.Status != models.ChargeSucceeded || amount <= 0 || amount > remaining {
+		charge := state.charge
+		s.mu.Unlock()
+		return charge, ErrChargeNotRefundable
+	}
+	state.charge.RefundedAmount += amount
+	if amount == remaining {
+		state.charge.Status = models.ChargeRefunded
+	}
+	charge := state.charge
+	s.mu.Unlock()
+
+	s.notify(state.callbackURL, "charge.refunded", charge, amount)
+	return charge, nil
+}
+
+func (s *Simulator) QueryStatus(chargeId string) (models.Charge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.charges[chargeId]
+	if !ok {
+		return models.Charge{}, ErrChargeNotFound
+	}
+	return state.charge, nil
+}
+
+func (s *Simulator) VerifyWebhook(payload []byte, signature string) error {
+	return verifySignature(os.Getenv("PAYMENT_WEBHOOK_SECRET"), payload, signature)
+}
+
+// notify posts the webhook in the background after the simulator delay.
+func (s *Simulator) notify(callbackURL, eventType string, charge models.Charge, amount models.Money) {
+	if callbackURL == "" {
+		return
+	}
+	token, err := newHoldToken()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	event := models.PaymentEvent{
+		ID:        "evt_sim_" + token,
+		Type:      eventType,
+		ChargeID:  charge.ID,
+		PaymentID: charge.PaymentID,
+		Status:    charge.Status,
+		Amount:    amount,
+		Currency:  charge.Currency,
+		CreatedAt: time.Now(),
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	time.AfterFunc(simulatorDelay(), func() {
+		req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(payload))
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(os.Getenv("PAYMENT_WEBHOOK_SECRET"), payload))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		res.Body.Close()
+		if res.StatusCode >= 300 {
+			log.Printf("webhook %s for %s was answered with %d", event.ID, charge.ID, res.StatusCode)
+		}
+	})
+}
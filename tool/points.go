@@ -0,0 +1,235 @@
+package tool
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+	"tix-id/config"
+	"tix-id/models"
+
+	"github.com/claudiu/gocron"
+)
+
+var ErrNotEnoughPoints = errors.New("not enough points")
+
+type PointsPolicy struct {
+	// AmountPerPoint is how much a customer pays to earn one point.
+	AmountPerPoint models.Money
+	// PointValue is the discount one point is worth when redeemed.
+	PointValue models.Money
+	// ExpiryDays is how long earned points can be used.
+	ExpiryDays int
+}
+
+// LoadPointsPolicy reads POINTS_AMOUNT_PER_POINT, POINTS_VALUE and
+// POINTS_EXPIRY_DAYS. By default a point is earned per 1000 paid, is worth
+// 10 and expires after a year.
+func LoadPointsPolicy() PointsPolicy {
+	policy := PointsPolicy{AmountPerPoint: models.NewMoney(1000), PointValue: models.NewMoney(10), ExpiryDays: 365}
+	if amount, err := models.ParseMoney(os.Getenv("POINTS_AMOUNT_PER_POINT")); err == nil && amount > 0 {
+		policy.AmountPerPoint = amount
+	}
+	if value, err := models.ParseMoney(os.Getenv("POINTS_VALUE")); err == nil && value > 0 {
+		policy.PointValue = value
+	}
+	if days, err := strconv.Atoi(os.Getenv("POINTS_EXPIRY_DAYS")); err == nil && days > 0 {
+		policy.ExpiryDays = days
+	}
+	return policy
+}
+
+// Earned returns the points earned by paying the amount.
+func (p PointsPolicy) Earned(amount models.Money) int {
+	if amount <= 0 {
+		return 0
+	}
+	return int(amount / p.AmountPerPoint)
+}
+
+// Value returns the discount the points are worth.
+func (p PointsPolicy) Value(points int) models.Money {
+	return p.PointValue.Mul(points)
+}
+
+func (p PointsPolicy) expiresAt(now time.Time) time.Time {
+	return now.AddDate(0, 0, p.ExpiryDays)
+}
+
+// rowQueryer is satisfied by both *sql.DB and *sql.Tx.
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// PointBalance returns the points the customer can still use.
+func PointBalance(q rowQueryer, customerId int, now time.Time) (int, error) {
+	var balance int
+	err := q.QueryRow("select coalesce(sum(remaining), 0) from point_ledger where customer_id = ? and remaining > 0 and (expires_at is null or expires_at > ?)", customerId, now).Scan(&balance)
+	return balance, err
+}
+
+// creditPoints adds a credit line. Its remaining points are used up by
+// later debits, oldest expiry first.
+func creditPoints(tx *sql.Tx, customerId, paymentId int, kind models.PointEntryType, points int, description string, expiresAt time.Time) error {
+	_, err := tx.Exec("insert into point_ledger (customer_id, payment_id, type, points, remaining, description, expires_at) values (?, ?, ?, ?, ?, ?, ?)", customerId, paymentId, kind, points, points, description, expiresAt)
+	return err
+}
+
+// debitPoints takes up to the given points from the customer's credits, the
+// credits of the payment first and then the ones expiring soonest, and
+// records the debit. It returns the points actually taken.
+func debitPoints(tx *sql.Tx, customerId, paymentId int, kind models.PointEntryType, points int, description string, now time.Time) (int, error) {
+	rows, err := tx.Query("select id, remaining from point_ledger where customer_id = ? and remaining > 0 and (expires_at is null or expires_at > ?) order by (payment_id <=> ?) desc, expires_at, id for update", customerId, now, paymentId)
+	if err != nil {
+		return 0, err
+	}
+	type credit struct{ id, remaining int }
+	var credits []credit
+	for rows.Next() {
+		var c credit
+		if err := rows.Scan(&c.id, &c.remaining); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		credits = append(credits, c)
+	}
+	rows.Close()
+
+	taken := 0
+	for _, c := range credits {
+		if taken == points {
+			break
+		}
+		use := c.remaining
+		if use > points-taken {
+			use = points - taken
+		}
+		if _, err := tx.Exec("update point_ledger set remaining = remaining - ? where id = ?", use, c.id); err != nil {
+			return 0, err
+		}
+		taken += use
+	}
+	if taken == 0 {
+		return 0, nil
+	}
+	_, err = tx.Exec("insert into point_ledger (customer_id, payment_id, type, points, remaining, description) values (?, ?, ?, ?, 0, ?)", customerId, paymentId, kind, -taken, description)
+	return taken, err
+}
+
+// RedeemPoints spends the points of the customer on the payment. The
+// customer row is locked so two checkouts can't spend the same points.
+func RedeemPoints(tx *sql.Tx, customerId, paymentId, points int, now time.Time) error {
+	if _, err := tx.Exec("select id from customer where id = ? for update", customerId); err != nil {
+		return err
+	}
+	balance, err := PointBalance(tx, customerId, now)
+	if err != nil {
+		return err
+	}
+	if balance < points {
+		return ErrNotEnoughPoints
+	}
+	_, err = debitPoints(tx, customerId, paymentId, models.RedeemPoints, points, fmt.Sprintf("Redeemed on payment #%d", paymentId), now)
+	return err
+}
+
+// EarnPoints credits the points of a completed payment to the customer who made it.
+func EarnPoints(tx *sql.Tx, paymentId int, policy PointsPolicy, now time.Time) error {
+	var customerId int
+	var amount models.Money
+	err := tx.QueryRow("select tc.customer_id, p.amount from payment p join ticket tc on tc.payment_id = p.id where p.id = ? limit 1", paymentId).Scan(&customerId, &amount)
+	if err != nil {
+		return err
+	}
+	points := policy.Earned(amount)
+	if points <= 0 {
+		return nil
+	}
+	return creditPoints(tx, customerId, paymentId, models.EarnPoints, points, fmt.Sprintf("Earned on payment #%d", paymentId), policy.expiresAt(now))
+}
+
+// ReverseEarnedPoints takes back the given share (0 to 1) of the points
+// earned on the payment, when its tickets are refunded. Points the customer
+// has already spent are not taken back.
+func ReverseEarnedPoints(tx *sql.Tx, paymentId int, share float64, now time.Time) error {
+	var customerId sql.NullInt64
+	var earned, reversed int
+	err := tx.QueryRow("select max(customer_id), coalesce(sum(if(type = 'earn', points, 0)), 0), coalesce(-sum(if(type = 'reverse', points, 0)), 0) from point_ledger where payment_id = ?", paymentId).Scan(&customerId, &earned, &reversed)
+	if err != nil || !customerId.Valid {
+		return err
+	}
+	points := int(math.Round(float64(earned) * share))
+	if points > earned-reversed {
+		points = earned - reversed
+	}
+	if points <= 0 {
+		return nil
+	}
+	_, err = debitPoints(tx, int(customerId.Int64), paymentId, models.ReversePoints, points, fmt.Sprintf("Reversed for refund of payment #%d", paymentId), now)
+	return err
+}
+
+// RestoreRedeemedPoints gives back the points spent on a payment that failed
+// or was cancelled before it was paid.
+func RestoreRedeemedPoints(tx *sql.Tx, paymentId int, policy PointsPolicy, now time.Time) error {
+	var customerId sql.NullInt64
+	var redeemed int
+	err := tx.QueryRow("select max(customer_id), coalesce(-sum(points), 0) from point_ledger where payment_id = ? and type in ('redeem', 'restore')", paymentId).Scan(&customerId, &redeemed)
+	if err != nil || !customerId.Valid || redeemed <= 0 {
+		return err
+	}
+	return creditPoints(tx, int(customerId.Int64), paymentId, models.RestorePoints, redeemed, fmt.Sprintf("Restored from payment #%d", paymentId), policy.expiresAt(now))
+}
+
+// ExpirePoints writes off the remaining points of every credit that expired.
+func ExpirePoints(db *sql.DB, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("select id, customer_id, remaining from point_ledger where remaining > 0 and expires_at <= ? for update", now)
+	if err != nil {
+		return err
+	}
+	type credit struct{ id, customerId, remaining int }
+	var credits []credit
+	for rows.Next() {
+		var c credit
+		if err := rows.Scan(&c.id, &c.customerId, &c.remaining); err != nil {
+			rows.Close()
+			return err
+		}
+		credits = append(credits, c)
+	}
+	rows.Close()
+
+	for _, c := range credits {
+		if _, err := tx.Exec("update point_ledger set remaining = 0 where id = ?", c.id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("insert into point_ledger (customer_id, type, points, remaining, description) values (?, 'expire', ?, 0, ?)", c.customerId, -c.remaining, fmt.Sprintf("Expired from entry #%d", c.id)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func CronPointExpiry() {
+	s := gocron.NewScheduler()
+
+	db := config.ConnectDB()
+	defer db.Close()
+
+	s.Every(1).Hour().Do(func() {
+		if err := ExpirePoints(db, time.Now()); err != nil {
+			log.Println(err)
+		}
+	})
+	<-s.Start()
+}
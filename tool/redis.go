@@ -27,6 +27,10 @@ func GetRedisValue(client *redis.Client, key string) (string, error) {
 	}
 }
 
+func IncrRedisValue(client *redis.Client, key string) error {
+	return client.Incr(key).Err()
+}
+
 func NewRedisClient() *redis.Client {
 	client := redis.NewClient(&redis.Options{
 		Addr: os.Getenv("REDIS_ADDR"),
@@ -0,0 +1,39 @@
+package tool
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+type RefundPolicy struct {
+	// FullRefundHours is how long before the show a ticket still gets a full refund.
+	FullRefundHours int
+	// PartialPercent is refunded after that, until the show starts.
+	PartialPercent int
+}
+
+// LoadRefundPolicy reads REFUND_FULL_HOURS and REFUND_PARTIAL_PERCENT, with
+// a full refund until 24 hours before the show and 50% after that by default.
+func LoadRefundPolicy() RefundPolicy {
+	policy := RefundPolicy{FullRefundHours: 24, PartialPercent: 50}
+	if hours, err := strconv.Atoi(os.Getenv("REFUND_FULL_HOURS")); err == nil && hours >= 0 {
+		policy.FullRefundHours = hours
+	}
+	if percent, err := strconv.Atoi(os.Getenv("REFUND_PARTIAL_PERCENT")); err == nil && percent >= 0 && percent <= 100 {
+		policy.PartialPercent = percent
+	}
+	return policy
+}
+
+// Percentage returns how much of the ticket price is refunded when the
+// ticket is cancelled at the given time. Nothing is refunded once the show has started.
+func (p RefundPolicy) Percentage(showTime, now time.Time) int {
+	if !now.Before(showTime) {
+		return 0
+	}
+	if now.Add(time.Duration(p.FullRefundHours) * time.Hour).After(showTime) {
+		return p.PartialPercent
+	}
+	return 100
+}
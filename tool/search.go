@@ -0,0 +1,106 @@
+package tool
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxSearchTerms caps the words of a search, the rest are ignored.
+const maxSearchTerms = 10
+
+// SearchWords splits a text into lower case words of letters and digits,
+// which also drops anything MySQL would read as a full-text operator.
+func SearchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchTerms are the words of a search, at most maxSearchTerms of them.
+func SearchTerms(s string) []string {
+	terms := SearchWords(s)
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+// maxTypos is how many typos a word can have and still be matched: none in
+// short words, where any change gives another word, and more in long ones.
+func maxTypos(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	}
+	return 2
+}
+
+// EditDistance counts the letters to insert, delete or change, or the
+// neighbouring letters to swap, to turn a into b.
+func EditDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	rows := make([][]int, len(s)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(t)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d := min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d = min(d, rows[i-2][j-2]+1)
+			}
+			rows[i][j] = d
+		}
+	}
+	return rows[len(s)][len(t)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// CorrectTerm finds the words of the vocabulary the term is most likely a
+// misspelling of. A term that is in the vocabulary, or too short to tell,
+// has no corrections.
+func CorrectTerm(term string, vocabulary map[string]bool) []string {
+	typos := maxTypos(term)
+	if typos == 0 || vocabulary[term] {
+		return nil
+	}
+	best := typos + 1
+	var corrections []string
+	length := len([]rune(term))
+	for word := range vocabulary {
+		if diff := len([]rune(word)) - length; diff > typos || -diff > typos {
+			continue
+		}
+		switch d := EditDistance(term, word); {
+		case d < best:
+			best, corrections = d, []string{word}
+		case d == best:
+			corrections = append(corrections, word)
+		}
+	}
+	sort.Strings(corrections)
+	if len(corrections) > 3 {
+		corrections = corrections[:3]
+	}
+	return corrections
+}
@@ -0,0 +1,226 @@
+package tool
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"tix-id/models"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	defaultSeatHoldTTL       = 10 * time.Minute
+	defaultWheelchairRelease = 2 * time.Hour
+)
+
+var (
+	ErrSeatHeld        = errors.New("one or more seats are held by another customer")
+	ErrHoldNotFound    = errors.New("the hold is not found or already expired")
+	ErrHoldExtended    = errors.New("the hold has already been extended")
+	ErrHoldNotOwned    = errors.New("the hold belongs to another customer")
+	ErrHoldSeatMissing = errors.New("the hold doesn't cover the requested seat")
+	ErrHoldSeatsLost   = errors.New("one or more seats of the hold are no longer held")
+)
+
+// releaseIfOwner deletes a seat key only when it still points to the given
+// hold token, so an expired hold never releases a seat someone else holds now.
+var releaseIfOwner = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// extendIfOwner sets the expiry (ARGV[2], in milliseconds) of every seat key
+// only when all of them still point to the given hold token, and returns 0
+// without touching any when one doesn't.
+var extendIfOwner = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	if redis.call("GET", key) ~= ARGV[1] then
+		return 0
+	end
+end
+for _, key in ipairs(KEYS) do
+	redis.call("PEXPIRE", key, ARGV[2])
+end
+return 1`)
+
+// SeatHoldTTL returns the hold duration configured by SEAT_HOLD_TTL (in seconds).
+func SeatHoldTTL() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("SEAT_HOLD_TTL"))
+	if err != nil || seconds <= 0 {
+		return defaultSeatHoldTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// WheelchairRelease returns how long before the show wheelchair seats can be
+// held and booked by anyone, configured by WHEELCHAIR_RELEASE_HOURS, 2 hours
+// by default. Until then they are kept for customers who need the space.
+func WheelchairRelease() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("WHEELCHAIR_RELEASE_HOURS"))
+	if err != nil || hours < 0 {
+		return defaultWheelchairRelease
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func holdKey(token string) string {
+	return "seathold:" + token
+}
+
+func seatHoldKey(scheduleId, seatId int) string {
+	return fmt.Sprintf("seathold:seat:%d:%d", scheduleId, seatId)
+}
+
+func newHoldToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HoldSeats reserves every seat for the customer or none of them, at the
+// given prices. The keys expire on their own, so the seats are released as
+// soon as the TTL ends.
+func HoldSeats(client *redis.Client, customerId, scheduleId int, seatIds []int, prices map[int]models.Money) (models.SeatHold, error) {
+	token, err := newHoldToken()
+	if err != nil {
+		return models.SeatHold{}, err
+	}
+	ttl := SeatHoldTTL()
+
+	var acquired []int
+	for _, seatId := range seatIds {
+		ok, err := client.SetNX(seatHoldKey(scheduleId, seatId), token, ttl).Result()
+		if err != nil || !ok {
+			for _, id := range acquired {
+				releaseIfOwner.Run(client, []string{seatHoldKey(scheduleId, id)}, token)
+			}
+			if err != nil {
+				return models.SeatHold{}, err
+			}
+			return models.SeatHold{}, ErrSeatHeld
+		}
+		acquired = append(acquired, seatId)
+	}
+
+	hold := models.SeatHold{
+		Token:      token,
+		CustomerID: customerId,
+		ScheduleID: scheduleId,
+		SeatIDs:    seatIds,
+		Prices:     prices,
+		ExpiresAt:  time.Now().Add(ttl),
+	}
+	if err := saveHold(client, hold, ttl); err != nil {
+		ReleaseHold(client, hold)
+		return models.SeatHold{}, err
+	}
+	return hold, nil
+}
+
+// GetHold loads a hold by its token.
+func GetHold(client *redis.Client, token string) (models.SeatHold, error) {
+	var hold models.SeatHold
+	value, err := client.Get(holdKey(token)).Result()
+	if err == redis.Nil {
+		return hold, ErrHoldNotFound
+	} else if err != nil {
+		return hold, err
+	}
+	if err := json.Unmarshal([]byte(value), &hold); err != nil {
+		return hold, err
+	}
+	return hold, nil
+}
+
+// ExtendHold pushes the expiry of a hold one more TTL forward. A hold can
+// only be extended once, and only while it still holds every one of its seats.
+func ExtendHold(client *redis.Client, customerId int, token string) (models.SeatHold, error) {
+	hold, err := GetHold(client, token)
+	if err != nil {
+		return hold, err
+	}
+	if hold.CustomerID != customerId {
+		return hold, ErrHoldNotOwned
+	}
+	if hold.Extended {
+		return hold, ErrHoldExtended
+	}
+
+	ttl := time.Until(hold.ExpiresAt) + SeatHoldTTL()
+	var keys []string
+	for _, seatId := range hold.SeatIDs {
+		keys = append(keys, seatHoldKey(hold.ScheduleID, seatId))
+	}
+	extended, err := extendIfOwner.Run(client, keys, hold.Token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return hold, err
+	}
+	if extended == 0 {
+		return hold, ErrHoldSeatsLost
+	}
+	hold.Extended = true
+	hold.ExpiresAt = time.Now().Add(ttl)
+	if err := saveHold(client, hold, ttl); err != nil {
+		return hold, err
+	}
+	return hold, nil
+}
+
+// ReleaseHold frees all seats of a hold and removes the hold itself.
+func ReleaseHold(client *redis.Client, hold models.SeatHold) error {
+	for _, seatId := range hold.SeatIDs {
+		if err := releaseIfOwner.Run(client, []string{seatHoldKey(hold.ScheduleID, seatId)}, hold.Token).Err(); err != nil && err != redis.Nil {
+			return err
+		}
+	}
+	return client.Del(holdKey(hold.Token)).Err()
+}
+
+// ReleaseHeldSeat frees a single seat of a hold, e.g. once it has been booked.
+func ReleaseHeldSeat(client *redis.Client, token string, scheduleId, seatId int) error {
+	err := releaseIfOwner.Run(client, []string{seatHoldKey(scheduleId, seatId)}, token).Err()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	return nil
+}
+
+// SeatHolders returns the hold token of each held seat of a schedule. Seats
+// that are not held are missing from the map.
+func SeatHolders(client *redis.Client, scheduleId int, seatIds []int) (map[int]string, error) {
+	holders := map[int]string{}
+	if len(seatIds) == 0 {
+		return holders, nil
+	}
+	keys := make([]string, len(seatIds))
+	for i, seatId := range seatIds {
+		keys[i] = seatHoldKey(scheduleId, seatId)
+	}
+	values, err := client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		if token, ok := value.(string); ok {
+			holders[seatIds[i]] = token
+		}
+	}
+	return holders, nil
+}
+
+func saveHold(client *redis.Client, hold models.SeatHold, ttl time.Duration) error {
+	value, err := json.Marshal(hold)
+	if err != nil {
+		return err
+	}
+	return SetRedisValue(client, holdKey(hold.Token), string(value), ttl)
+}
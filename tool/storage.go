@@ -0,0 +1,130 @@
+package tool
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	LocalStorageBackend = "local"
+	S3StorageBackend    = "s3"
+
+	defaultMediaDir    = "media"
+	defaultMediaPrefix = "/media"
+)
+
+var (
+	ErrUnknownStorage = errors.New("the storage backend is not supported")
+	ErrInvalidKey     = errors.New("invalid storage key")
+)
+
+// Storage keeps media files such as movie posters under slash separated
+// keys, and tells the public URL each one is served at.
+type Storage interface {
+	Name() string
+	Put(key, contentType string, data []byte) error
+	Delete(key string) error
+	URL(key string) string
+}
+
+// NewStorage returns the storage configured by STORAGE_BACKEND. Files are
+// kept on the local disk when nothing is configured.
+func NewStorage() (Storage, error) {
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "", LocalStorageBackend:
+		return NewLocalStorage(), nil
+	case S3StorageBackend:
+		return NewS3Storage()
+	}
+	return nil, ErrUnknownStorage
+}
+
+// cleanKey rejects keys that could escape the storage root.
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != key || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// LocalStorage keeps the files in STORAGE_LOCAL_DIR and serves them under
+// STORAGE_PUBLIC_URL, by default the media directory at /media of this
+// server.
+type LocalStorage struct {
+	Dir     string
+	BaseURL string
+}
+
+func NewLocalStorage() *LocalStorage {
+	storage := &LocalStorage{Dir: os.Getenv("STORAGE_LOCAL_DIR"), BaseURL: os.Getenv("STORAGE_PUBLIC_URL")}
+	if storage.Dir == "" {
+		storage.Dir = defaultMediaDir
+	}
+	if storage.BaseURL == "" {
+		storage.BaseURL = defaultMediaPrefix
+	}
+	return storage
+}
+
+func (s *LocalStorage) Name() string {
+	return LocalStorageBackend
+}
+
+// Put writes the file to a temporary name first, so a file is never served
+// half written.
+func (s *LocalStorage) Put(key, contentType string, data []byte) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + key
+}
+
+// ServedPath is the path of this server the files are served at, or "" when
+// STORAGE_PUBLIC_URL points somewhere else, e.g. a CDN in front of the
+// directory.
+func (s *LocalStorage) ServedPath() string {
+	if !strings.HasPrefix(s.BaseURL, "/") || strings.HasPrefix(s.BaseURL, "//") {
+		return ""
+	}
+	return strings.TrimSuffix(s.BaseURL, "/")
+}
@@ -0,0 +1,204 @@
+package tool
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultS3Region = "us-east-1"
+
+// S3Storage keeps the files in a bucket of any S3 compatible service, such
+// as AWS S3 or a local MinIO. Requests are signed with AWS Signature
+// Version 4. It is configured by:
+//
+//	S3_ENDPOINT    e.g. https://s3.ap-southeast-3.amazonaws.com or http://localhost:9000
+//	S3_REGION      us-east-1 unless given
+//	S3_BUCKET
+//	S3_ACCESS_KEY
+//	S3_SECRET_KEY
+//	S3_PATH_STYLE  false to address the bucket as a subdomain, true unless given
+//	S3_PUBLIC_URL  where the bucket is served from, the bucket URL unless given
+//
+// The bucket must let anyone read the files, the URLs aren't signed.
+type S3Storage struct {
+	Endpoint  *url.URL
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	PublicURL string
+	client    *http.Client
+	now       func() time.Time
+}
+
+func NewS3Storage() (*S3Storage, error) {
+	endpoint, err := url.Parse(os.Getenv("S3_ENDPOINT"))
+	if err != nil || endpoint.Host == "" {
+		return nil, errors.New("S3_ENDPOINT must be a URL")
+	}
+	storage := &S3Storage{
+		Endpoint:  endpoint,
+		Region:    os.Getenv("S3_REGION"),
+		Bucket:    os.Getenv("S3_BUCKET"),
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+		PathStyle: true,
+		PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		client:    &http.Client{Timeout: 30 * time.Second},
+		now:       time.Now,
+	}
+	if storage.Bucket == "" || storage.AccessKey == "" || storage.SecretKey == "" {
+		return nil, errors.New("S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY are required")
+	}
+	if storage.Region == "" {
+		storage.Region = defaultS3Region
+	}
+	if pathStyle, err := strconv.ParseBool(os.Getenv("S3_PATH_STYLE")); err == nil {
+		storage.PathStyle = pathStyle
+	}
+	if storage.PublicURL == "" {
+		storage.PublicURL = storage.bucketURL().String()
+	}
+	return storage, nil
+}
+
+func (s *S3Storage) Name() string {
+	return S3StorageBackend
+}
+
+func (s *S3Storage) Put(key, contentType string, data []byte) error {
+	return s.do(http.MethodPut, key, contentType, data)
+}
+
+// Delete removes the file. Deleting a file that isn't there succeeds, as S3 does.
+func (s *S3Storage) Delete(key string) error {
+	return s.do(http.MethodDelete, key, "", nil)
+}
+
+func (s *S3Storage) URL(key string) string {
+	return strings.TrimSuffix(s.PublicURL, "/") + "/" + key
+}
+
+func (s *S3Storage) bucketURL() *url.URL {
+	bucket := *s.Endpoint
+	if s.PathStyle {
+		bucket.Path = strings.TrimSuffix(bucket.Path, "/") + "/" + s.Bucket
+	} else {
+		bucket.Host = s.Bucket + "." + bucket.Host
+	}
+	return &bucket
+}
+
+func (s *S3Storage) do(method, key, contentType string, data []byte) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	target := s.bucketURL()
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + key
+	request, err := http.NewRequest(method, target.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	s.sign(request, data)
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("s3 %s %s: %s %s", method, key, response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 headers to the request.
+func (s *S3Storage) sign(request *http.Request, payload []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(request.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		awsURIEncode(request.URL.Path, false),
+		request.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEncode encodes every byte but the unreserved characters, and the
+// slashes of a path unless encodeSlash.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}